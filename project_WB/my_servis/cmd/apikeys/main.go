@@ -0,0 +1,54 @@
+// Команда apikeys выпускает и отзывает API-ключи в таблице api_keys (auth.keys_table: true).
+//
+//	CONFIG_PATH=config/local.yaml apikeys create -name reports -scopes orders:read,orders:export
+//	CONFIG_PATH=config/local.yaml apikeys revoke -name reports
+//
+// Значение выпущенного ключа печатается один раз; в таблице хранится только его хеш.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	config "main.go/internal"
+	"main.go/internal/auth"
+	database "main.go/internal/storage/database"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalf("usage: apikeys create|revoke -name NAME [-scopes SCOPES]")
+	}
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	name := flags.String("name", "", "key name")
+	scopes := flags.String("scopes", auth.ScopeOrdersRead, "comma-separated scopes: orders:read, orders:export, pii:read, admin")
+	flags.Parse(os.Args[2:])
+	if *name == "" {
+		log.Fatalf("-name is required")
+	}
+
+	cfg := config.MustLoad()
+	cluster := database.Connect(cfg.Database)
+	defer cluster.Close()
+	db := cluster.Primary()
+	auth.CreateTables(db)
+
+	switch os.Args[1] {
+	case "create":
+		key, err := auth.CreateKey(*name, strings.Split(*scopes, ","), db)
+		if err != nil {
+			log.Fatalf("Error creating API key: %v", err)
+		}
+		fmt.Println(key)
+	case "revoke":
+		if err := auth.RevokeKey(*name, db); err != nil {
+			log.Fatalf("Error revoking API key: %v", err)
+		}
+		log.Printf("API key %s revoked", *name)
+	default:
+		log.Fatalf("unknown command %q", os.Args[1])
+	}
+}
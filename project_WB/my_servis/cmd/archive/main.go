@@ -0,0 +1,68 @@
+// Команда archive восстанавливает заказы, выведенные из базы политикой хранения.
+//
+//	CONFIG_PATH=config/local.yaml archive restore -month 2024-01
+//	CONFIG_PATH=config/local.yaml archive release -month 2024-01
+//
+// restore подключает обратно отключенные секции месяца или загружает заказы из архива NDJSON
+// в каталоге partitions.archive_dir и защищает месяц от политики хранения; release снимает защиту.
+// Восстановленные заказы попадают в кэш сервиса после его перезапуска.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	config "main.go/internal"
+	"main.go/internal/pii"
+	database "main.go/internal/storage/database"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	monthFlag := cmd.String("month", "", "month to restore or release, YYYY-MM")
+	dir := cmd.String("dir", "", "archive directory (default partitions.archive_dir from config)")
+	cmd.Parse(os.Args[2:])
+
+	month, err := database.ParseMonth(*monthFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg := config.MustLoad()
+	if err := pii.Setup(cfg.PII); err != nil {
+		log.Fatalf("Error loading PII keys: %v", err)
+	}
+	cluster := database.Connect(cfg.Database)
+	defer cluster.Close()
+	db := cluster.Primary()
+
+	switch os.Args[1] {
+	case "restore":
+		if *dir == "" {
+			*dir = cfg.Database.Partitions.ArchiveDir
+		}
+		n, err := database.RestoreArchive(context.Background(), *dir, month, db)
+		if err != nil {
+			log.Fatalf("Error restoring %s: %v", *monthFlag, err)
+		}
+		log.Printf("Restored %d orders for %s", n, *monthFlag)
+	case "release":
+		if err := database.ReleaseHold(context.Background(), month, db); err != nil {
+			log.Fatalf("Error releasing %s: %v", *monthFlag, err)
+		}
+		log.Printf("Retention policy applies to %s again", *monthFlag)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: archive restore|release -month YYYY-MM [-dir DIR]")
+	os.Exit(2)
+}
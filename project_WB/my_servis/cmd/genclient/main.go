@@ -0,0 +1,37 @@
+// Команда genclient генерирует Go-клиент сервиса заказов (модуль orderclient) по спецификации
+// internal/openapi/openapi.json. Обычно запускается через go generate:
+//
+//	go generate ./internal/openapi
+//
+// Без -out код печатается в стандартный вывод.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"main.go/internal/openapi"
+)
+
+func main() {
+	out := flag.String("out", "", "output file, stdout if empty")
+	pkg := flag.String("package", "orderclient", "package name of the generated code")
+	flag.Parse()
+
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Fatalf("failed to load spec: %v", err)
+	}
+	src, err := openapi.GenerateClient(spec, *pkg)
+	if err != nil {
+		log.Fatalf("failed to generate client: %v", err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatalf("failed to write %s: %v", *out, err)
+	}
+}
@@ -1,18 +1,31 @@
 package main
 
 import (
+	"context"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 
-	_ "github.com/lib/pq"
 	config "main.go/internal"
+	"main.go/internal/analytics"
+	"main.go/internal/auth"
+	"main.go/internal/codec"
+	"main.go/internal/deadletter"
+	"main.go/internal/grpcserver"
 	"main.go/internal/handlers"
+	"main.go/internal/httpcache"
 	"main.go/internal/interfacevivoda"
 	"main.go/internal/natsstream"
+	"main.go/internal/openapi"
+	"main.go/internal/outbox"
+	"main.go/internal/pii"
+	"main.go/internal/ratelimit"
 	"main.go/internal/storage/cache"
 	database "main.go/internal/storage/database"
 	"main.go/internal/utils"
+	"main.go/internal/web"
+	"main.go/internal/webhooks"
 )
 
 const (
@@ -43,28 +56,157 @@ func main() {
 	// Настройка логгера
 	log := setupLogger(cfg.Env)
 
-	// Подключение к базе данных PostgreSQL
-	db := database.Connect(cfg.Database)
-	defer db.Close()
+	// Загрузка ключей шифрования персональных данных и API-ключей с доступом к ним без маскирования
+	if err := pii.Setup(cfg.PII); err != nil {
+		log.Error("Ошибка загрузки ключей шифрования персональных данных", slog.String("ошибка", err.Error()))
+		os.Exit(1)
+	}
+	if !pii.Enabled() {
+		log.Warn("Файл ключей не задан, персональные данные получателей хранятся без шифрования")
+	}
+
+	// Подключение к основному серверу PostgreSQL и репликам; запись идет через db,
+	// аналитика и прогрев кэша читают с реплик через cluster.Replica
+	cluster := database.Connect(cfg.Database)
+	defer cluster.Close()
+	db := cluster.Primary()
+
+	// Подключение к шардам; основная база данных служит шардом по умолчанию,
+	// а статистика, вебхуки и исходящие уведомления ведутся только в ней
+	shards := database.ConnectShards(cfg.Sharding, cfg.Database, cluster)
+	defer shards.Close()
+
+	// Создание секций на будущие месяцы и применение политики хранения старых заказов в каждом шарде
+	for _, name := range shards.Names() {
+		go database.RunRetention(cfg.Database.Partitions, shards.DB(name))
+	}
 
-	// Инициализация кэша
-	cache.InitCache()
+	// Создание сводных таблиц аналитики; они заполняются из очереди событий заказов
+	analytics.CreateTables(db)
 
-	// Кэширование всех данных о заказах из базы данных
-	allOrders, err := database.CacheAllOrdersFromDB(db)
+	// Создание таблиц вебхуков и запуск доставки из исходящей очереди
+	webhooks.CreateTables(db)
+	go webhooks.RunDispatcher(db)
+
+	// Проверка API-ключей и JWT клиентов HTTP API; обращения к заказам пишутся в журнал аудита
+	authz, err := auth.New(cfg.Auth, db)
+	if err != nil {
+		log.Error("Ошибка настройки аутентификации", slog.String("ошибка", err.Error()))
+		os.Exit(1)
+	}
+	if !authz.Enabled() {
+		log.Warn("Проверка доступа к HTTP API отключена, все запросы выполняются без аутентификации")
+	}
+	auth.SetAuditLogger(log)
+
+	// Ограничение частоты запросов клиентов и учет дневных квот; таблица учета создается и при
+	// отключенном ограничении, чтобы отчет /api/v1/usage возвращал накопленную ранее статистику
+	limiter := ratelimit.New(cfg.RateLimit)
+	ratelimit.CreateTables(db)
+	if limiter.Enabled() {
+		go limiter.Run(utils.ParseDuration(cfg.RateLimit.FlushInterval), db)
+	}
+	// Заголовки Cache-Control маршрутов и сжатие ответов
+	cachePolicy := httpcache.New(cfg.HTTPServer)
+
+	// route проверяет права клиента на маршрут, ограничивает частоту его запросов
+	// и применяет к ответам политику кэширования и сжатие
+	route := func(scope, name string, h http.Handler) http.Handler {
+		return authz.Require(scope, limiter.Limit(name, cachePolicy.Wrap(name, h)))
+	}
+
+	// Инициализация кэша с разделом на каждый шард
+	cache.InitPartitions(shards.Name)
+
+	// Заполнение JSON-документов заказов, сохраненных до появления столбца document
+	if err := database.BackfillDocuments(context.Background(), db); err != nil {
+		log.Error("Ошибка заполнения документов заказов", slog.String("ошибка", err.Error()))
+	}
+
+	// Кэширование всех данных о заказах из всех шардов
+	allOrders, err := shards.CacheAll(context.Background())
 	if err != nil {
 		log.Error("Ошибка кэширования заказов из базы данных", slog.String("ошибка", err.Error()))
 	}
-	cache.SetCache(allOrders)
+	for _, orders := range allOrders {
+		cache.SetCache(orders)
+	}
+
+	// Первичное заполнение сводных таблиц аналитики заказами, сохраненными до их появления
+	if err := natsstream.BackfillStats(context.Background(), shards, db); err != nil {
+		log.Error("Ошибка заполнения сводных таблиц", slog.String("ошибка", err.Error()))
+	}
 
 	// Подключение к NATS и JetStream
 	js := natsstream.Connect(cfg.Nats)
 
+	// Очередь сообщений, которые не удалось разобрать
+	deadletter.CreateTables(db)
+
 	// Подписка на канал, где приходят JSON сообщения
-	natsstream.Subscribe(js, "Json-orders", db)
+	codec.SetStrictJSON(cfg.Nats.StrictDecode)
+	natsstream.Subscribe(js, "Json-orders", cfg.Nats.BatchSize, utils.ParseDuration(cfg.Nats.BatchTimeout), shards, db)
+
+	// Публикация событий order.accepted из исходящей очереди каждого шарда
+	// и постановка вебхуков о сохраненных заказах каждого шарда в очередь основной базы данных
+	for _, name := range shards.Names() {
+		go outbox.RunRelay(shards.DB(name), js, cfg.Nats.OutboxSubject)
+		go natsstream.RunOrderEvents(shards.DB(name), db)
+	}
 
 	// Запуск HTTP-сервера для получения данных по id из кэша
-	http.HandleFunc("/order", handlers.GetOrderFromCache)
+	http.Handle("/order", route(auth.ScopeOrdersRead, "order", http.HandlerFunc(handlers.GetOrderFromCache)))
+
+	// Аналитические эндпоинты на основе сводных таблиц
+	http.Handle("GET /api/v1/stats/orders", route(auth.ScopeOrdersRead, "stats", handlers.StatsOrders(cluster)))
+	http.Handle("GET /api/v1/stats/basket", route(auth.ScopeOrdersRead, "stats", handlers.StatsBasket(cluster)))
+	http.Handle("GET /api/v1/stats/top/brands", route(auth.ScopeOrdersRead, "stats", handlers.StatsTopBrands(cluster)))
+	http.Handle("GET /api/v1/stats/top/products", route(auth.ScopeOrdersRead, "stats", handlers.StatsTopProducts(cluster)))
+	http.Handle("GET /api/v1/stats/payments", route(auth.ScopeOrdersRead, "stats", handlers.StatsPayments(cluster)))
+	http.Handle("GET /api/v1/stats/delivery", route(auth.ScopeOrdersRead, "stats", handlers.StatsDelivery(cluster)))
+
+	// Веб-интерфейс для просмотра и поиска заказов
+	http.Handle("GET /api/v1/orders", route(auth.ScopeOrdersRead, "orders", http.HandlerFunc(handlers.ListOrders)))
+	http.Handle("GET /api/v1/counters", route(auth.ScopeOrdersRead, "counters", http.HandlerFunc(handlers.GetCounters)))
+
+	// Выборка заказов по произвольному выражению JSON path над документом заказа
+	http.Handle("GET /api/v1/orders/query", route(auth.ScopeOrdersRead, "query", handlers.QueryOrders(shards)))
+	http.Handle("GET /ui/", http.StripPrefix("/ui/", web.Handler()))
+	http.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+
+	// Поиск заказов по получателю, адресу и товарам с ранжированием по релевантности
+	http.Handle("GET /api/v1/orders/search", route(auth.ScopeOrdersRead, "search", handlers.SearchOrders(shards)))
+
+	// Получение нескольких заказов по идентификаторам или трек-номерам одним запросом
+	http.Handle("POST /api/v1/orders:batchGet", route(auth.ScopeOrdersRead, "batch", handlers.BatchGetOrders(shards)))
+
+	// Поток событий о новых заказах (SSE и WebSocket)
+	http.Handle("GET /api/v1/stream/orders", route(auth.ScopeOrdersRead, "stream", http.HandlerFunc(handlers.StreamOrders)))
+
+	// Управление подписками на вебхуки и журнал доставки
+	http.Handle("POST /api/v1/webhooks", route(auth.ScopeAdmin, "webhooks", handlers.CreateWebhook(db)))
+	http.Handle("GET /api/v1/webhooks", route(auth.ScopeAdmin, "webhooks", handlers.ListWebhooks(db)))
+	http.Handle("DELETE /api/v1/webhooks/{id}", route(auth.ScopeAdmin, "webhooks", handlers.DeleteWebhook(db)))
+	http.Handle("GET /api/v1/webhooks/{id}/deliveries", route(auth.ScopeAdmin, "webhooks", handlers.ListWebhookDeliveries(db)))
+
+	// Удаление и выгрузка данных клиента по запросу субъекта персональных данных
+	http.Handle("POST /api/v1/privacy/erasure", route(auth.ScopeAdmin, "privacy", handlers.EraseSubject(shards, db)))
+	http.Handle("GET /api/v1/privacy/export", route(auth.ScopeOrdersExport, "privacy", handlers.ExportSubject(shards, db)))
+
+	// Отчет об использовании API клиентами и расходе дневных квот
+	http.Handle("GET /api/v1/usage", route(auth.ScopeAdmin, "usage", handlers.UsageReport(limiter, db)))
+
+	// Административный API для консоли orderctl: состояние сервиса, отложенные сообщения и кэш
+	http.Handle("GET /api/v1/admin/status", route(auth.ScopeAdmin, "admin", handlers.GetStatus(shards, db)))
+	http.Handle("GET /api/v1/admin/outbox", route(auth.ScopeAdmin, "admin", http.HandlerFunc(handlers.GetOutboxMetrics)))
+	http.Handle("GET /api/v1/admin/dlq", route(auth.ScopeAdmin, "admin", handlers.ListDeadLetters(db)))
+	http.Handle("POST /api/v1/admin/dlq/{id}/replay", route(auth.ScopeAdmin, "admin", handlers.ReplayDeadLetter(js, db)))
+	http.Handle("DELETE /api/v1/admin/dlq/{id}", route(auth.ScopeAdmin, "admin", handlers.DeleteDeadLetter(db)))
+	http.Handle("POST /api/v1/admin/cache:evict", route(auth.ScopeAdmin, "admin", handlers.EvictCache(shards)))
+
+	// Спецификация OpenAPI и страница ее просмотра доступны без аутентификации
+	http.Handle("GET /openapi.json", openapi.Handler())
+	http.Handle("GET /docs/", openapi.DocsHandler())
 
 	server := &http.Server{
 		Addr:         cfg.HTTPServer.Address,
@@ -75,8 +217,29 @@ func main() {
 
 	log.Info("HTTP сервер запущен на", slog.String("адрес", cfg.HTTPServer.Address))
 
-	// Запуск интерфейса вывода
-	go interfacevivoda.Vivod()
+	// Запуск gRPC-сервера для внутренних сервисов
+	if cfg.GRPCServer.Address != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCServer.Address)
+		if err != nil {
+			log.Error("Ошибка запуска gRPC сервера", slog.String("ошибка", err.Error()))
+			os.Exit(1)
+		}
+		grpcServer := grpcserver.New(shards, authz)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Error("Ошибка работы gRPC сервера", slog.String("ошибка", err.Error()))
+			}
+		}()
+		log.Info("gRPC сервер запущен на", slog.String("адрес", cfg.GRPCServer.Address))
+	}
+
+	// Запуск интерфейса вывода; без терминала (в контейнере, под systemd) он не нужен,
+	// для администрирования служит консоль orderctl
+	if interfacevivoda.Interactive() {
+		go interfacevivoda.Vivod()
+	} else {
+		log.Info("Стандартный ввод не подключен к терминалу, интерфейс вывода отключен")
+	}
 
 	err = server.ListenAndServe()
 	if err != nil {
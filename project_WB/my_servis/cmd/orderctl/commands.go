@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Selandro/my_servis_order/project_WB/orderclient"
+	"main.go/internal/search"
+)
+
+const (
+	pageSize      = 20  // pageSize размер страницы списков заказов.
+	maxReplayAll  = 500 // maxReplayAll сколько отложенных сообщений публикуется командой replay all.
+	maxSeenOrders = 200 // maxSeenOrders сколько идентификаторов заказов запоминается для дополнения.
+)
+
+// command команда консоли.
+type command struct {
+	name  string
+	usage string
+	help  string
+	// run выполняет команду; raw - строка после имени команды без разбора на аргументы.
+	run func(ctx context.Context, sh *shell, args []string, raw string) (view, error)
+	// complete возвращает варианты дополнения аргумента, следующего за args.
+	complete func(sh *shell, args []string) []string
+}
+
+// commands команды консоли в порядке вывода справки.
+var commands = []command{
+	{
+		name: "get", usage: "get ORDER_UID", help: "show an order",
+		run:      runGet,
+		complete: func(sh *shell, args []string) []string { return argAt(args, 0, sh.seen) },
+	},
+	{
+		name: "find", usage: "find [-page N] QUERY", help: "search orders, e.g. find brand:Vivienne city:Moscow amount>1000",
+		run:      runFind,
+		complete: func(sh *shell, args []string) []string { return searchFields },
+	},
+	{
+		name: "list", usage: "list [PAGE]", help: "list cached orders, newest first",
+		run: runList,
+	},
+	{
+		name: "stats", usage: "stats [orders|basket|brands|products|payments|delivery] [FROM [TO]]", help: "analytics for a period (YYYY-MM-DD)",
+		run:      runStats,
+		complete: func(sh *shell, args []string) []string { return argAt(args, 0, statsReports) },
+	},
+	{
+		name: "status", usage: "status", help: "service status and shard health",
+		run: runStatus,
+	},
+	{
+		name: "dlq", usage: "dlq [list [LIMIT] | drop ID...]", help: "messages that could not be decoded",
+		run:      runDLQ,
+		complete: func(sh *shell, args []string) []string { return argAt(args, 0, []string{"list", "drop"}) },
+	},
+	{
+		name: "replay", usage: "replay ID...|all", help: "publish dead letters to NATS again",
+		run:      runReplay,
+		complete: func(sh *shell, args []string) []string { return argAt(args, 0, []string{"all"}) },
+	},
+	{
+		name: "cache", usage: "cache evict ORDER_UID...", help: "evict orders from the cache and reload them from the database",
+		run: runCache,
+		complete: func(sh *shell, args []string) []string {
+			if len(args) == 0 {
+				return []string{"evict"}
+			}
+			return sh.seen
+		},
+	},
+}
+
+// statsReports отчеты команды stats.
+var statsReports = []string{"orders", "basket", "brands", "products", "payments", "delivery"}
+
+// searchFields поля поискового запроса для дополнения, в том числе с отрицанием.
+var searchFields = func() []string {
+	var fields []string
+	for name := range search.Fields {
+		fields = append(fields, name+":", "-"+name+":")
+	}
+	sort.Strings(fields)
+	return fields
+}()
+
+// argAt возвращает варианты только для аргумента с номером n.
+func argAt(args []string, n int, candidates []string) []string {
+	if len(args) != n {
+		return nil
+	}
+	return candidates
+}
+
+// findCommand ищет команду по имени.
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// errUsage возвращается при неверных аргументах команды; консоль дополняет его подсказкой по использованию.
+var errUsage = errors.New("invalid arguments")
+
+// runGet выводит заказ по идентификатору.
+func runGet(ctx context.Context, sh *shell, args []string, raw string) (view, error) {
+	if len(args) != 1 {
+		return view{}, errUsage
+	}
+	order, err := sh.client.GetOrder(ctx, orderclient.GetOrderParams{ID: args[0]})
+	if err != nil {
+		return view{}, err
+	}
+	sh.remember(order.OrderUID)
+
+	p := order.Payment
+	d := order.Delivery
+	v := pairs(order,
+		[]string{"order_uid", order.OrderUID},
+		[]string{"track_number", order.TrackNumber},
+		[]string{"entry", order.Entry},
+		[]string{"customer_id", order.CustomerID},
+		[]string{"delivery_service", order.DeliveryService},
+		[]string{"date_created", order.DateCreated.Format(time.RFC3339)},
+		[]string{"delivery.name", d.Name},
+		[]string{"delivery.phone", d.Phone},
+		[]string{"delivery.email", d.Email},
+		[]string{"delivery.address", strings.Join(nonEmpty(d.Zip, d.Region, d.City, d.Address), ", ")},
+		[]string{"payment.transaction", p.Transaction},
+		[]string{"payment.amount", p.Money(p.Amount).String()},
+		[]string{"payment.provider", strings.Join(nonEmpty(p.Provider, p.Bank), ", ")},
+		[]string{"payment.payment_dt", p.PaymentDT.Format(time.RFC3339)},
+	)
+	for i, item := range order.Items {
+		v.rows = append(v.rows, []string{
+			fmt.Sprintf("items[%d]", i),
+			fmt.Sprintf("%s %s, nm %d, %s, status %d", item.Brand, item.Name, item.NMID, p.Money(item.TotalPrice), item.Status),
+		})
+	}
+	return v, nil
+}
+
+// nonEmpty возвращает непустые значения.
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// runFind ищет заказы через поиск сервиса. Запрос передается как есть, с кавычками.
+func runFind(ctx context.Context, sh *shell, args []string, raw string) (view, error) {
+	page := 1
+	if len(args) >= 2 && args[0] == "-page" {
+		var err error
+		if page, err = strconv.Atoi(args[1]); err != nil || page < 1 {
+			return view{}, errUsage
+		}
+		raw = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(raw, "-page")), args[1]))
+	}
+	if raw == "" {
+		return view{}, errUsage
+	}
+	result, err := sh.client.SearchOrders(ctx, orderclient.SearchOrdersParams{Q: raw, Page: page, Size: pageSize, View: "summary"})
+	if err != nil {
+		return view{}, err
+	}
+	return sh.orderPage(result), nil
+}
+
+// runList выводит страницу заказов из кэша.
+func runList(ctx context.Context, sh *shell, args []string, raw string) (view, error) {
+	page := 1
+	if len(args) > 1 {
+		return view{}, errUsage
+	}
+	if len(args) == 1 {
+		var err error
+		if page, err = strconv.Atoi(args[0]); err != nil || page < 1 {
+			return view{}, errUsage
+		}
+	}
+	result, err := sh.client.ListOrders(ctx, orderclient.ListOrdersParams{Page: page, Size: pageSize, View: "summary"})
+	if err != nil {
+		return view{}, err
+	}
+	return sh.orderPage(result), nil
+}
+
+// orderPage возвращает представление страницы заказов и запоминает их идентификаторы для дополнения.
+func (sh *shell) orderPage(page *orderclient.OrderPage) view {
+	v := view{value: page, header: []string{"ORDER_UID", "TRACK_NUMBER", "CUSTOMER", "SERVICE", "AMOUNT", "CREATED"}}
+	for _, order := range page.Orders {
+		sh.remember(order.OrderUID)
+		v.rows = append(v.rows, []string{
+			order.OrderUID,
+			order.TrackNumber,
+			order.CustomerID,
+			order.DeliveryService,
+			order.Payment.Money(order.Payment.Amount).String(),
+			order.DateCreated.Format(time.DateTime),
+		})
+	}
+	pages := max(1, (page.Total+page.Size-1)/max(page.Size, 1))
+	v.footer = fmt.Sprintf("page %d of %d, %d orders", page.Page, pages, page.Total)
+	return v
+}
+
+// runStats выводит отчет аналитики за период.
+func runStats(ctx context.Context, sh *shell, args []string, raw string) (view, error) {
+	report := "orders"
+	if len(args) > 0 && args[0] != "" && !unicode.IsDigit(rune(args[0][0])) {
+		report, args = args[0], args[1:]
+	}
+	if len(args) > 2 {
+		return view{}, errUsage
+	}
+	var from, to string
+	if len(args) > 0 {
+		from = args[0]
+	}
+	if len(args) > 1 {
+		to = args[1]
+	}
+
+	switch report {
+	case "orders":
+		buckets, err := sh.client.StatsOrders(ctx, orderclient.StatsOrdersParams{From: from, To: to})
+		if err != nil {
+			return view{}, err
+		}
+		v := view{value: buckets, header: []string{"BUCKET", "CURRENCY", "ORDERS", "REVENUE", "ITEMS"}}
+		for _, b := range buckets {
+			v.rows = append(v.rows, []string{b.Bucket.Format(time.DateTime), b.Currency, itoa(b.Orders), itoa(b.Revenue), itoa(b.Items)})
+		}
+		return v, nil
+	case "basket":
+		baskets, err := sh.client.StatsBasket(ctx, orderclient.StatsBasketParams{From: from, To: to})
+		if err != nil {
+			return view{}, err
+		}
+		v := view{value: baskets, header: []string{"CURRENCY", "ORDERS", "REVENUE", "AVG_AMOUNT", "AVG_ITEMS"}}
+		for _, b := range baskets {
+			v.rows = append(v.rows, []string{b.Currency, itoa(b.Orders), itoa(b.Revenue),
+				strconv.FormatFloat(b.AvgAmount, 'f', 2, 64), strconv.FormatFloat(b.AvgItems, 'f', 2, 64)})
+		}
+		return v, nil
+	case "brands", "products":
+		var top []orderclient.TopEntry
+		var err error
+		if report == "brands" {
+			top, err = sh.client.StatsTopBrands(ctx, orderclient.StatsTopBrandsParams{From: from, To: to})
+		} else {
+			top, err = sh.client.StatsTopProducts(ctx, orderclient.StatsTopProductsParams{From: from, To: to})
+		}
+		if err != nil {
+			return view{}, err
+		}
+		v := view{value: top, header: []string{"KEY", "CURRENCY", "ITEMS", "REVENUE"}}
+		for _, e := range top {
+			v.rows = append(v.rows, []string{e.Key, e.Currency, itoa(e.Items), itoa(e.Revenue)})
+		}
+		return v, nil
+	case "payments", "delivery":
+		var breakdown []orderclient.Breakdown
+		var err error
+		if report == "payments" {
+			breakdown, err = sh.client.StatsPayments(ctx, orderclient.StatsPaymentsParams{From: from, To: to})
+		} else {
+			breakdown, err = sh.client.StatsDelivery(ctx, orderclient.StatsDeliveryParams{From: from, To: to})
+		}
+		if err != nil {
+			return view{}, err
+		}
+		v := view{value: breakdown, header: []string{"KEY", "CURRENCY", "ORDERS", "REVENUE"}}
+		for _, b := range breakdown {
+			v.rows = append(v.rows, []string{b.Key, b.Currency, itoa(b.Orders), itoa(b.Revenue)})
+		}
+		return v, nil
+	}
+	return view{}, errUsage
+}
+
+// itoa форматирует целое число.
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+// runStatus выводит состояние сервиса.
+func runStatus(ctx context.Context, sh *shell, args []string, raw string) (view, error) {
+	status, err := sh.client.GetStatus(ctx)
+	if err != nil {
+		return view{}, err
+	}
+	v := pairs(status,
+		[]string{"started_at", status.StartedAt.Format(time.RFC3339)},
+		[]string{"uptime", time.Since(status.StartedAt).Round(time.Second).String()},
+		[]string{"ingested", itoa(status.Ingested)},
+		[]string{"cached", strconv.Itoa(status.Cached)},
+		[]string{"dead_letters", strconv.Itoa(status.DeadLetters)},
+	)
+	for _, shard := range status.Shards {
+		state := fmt.Sprintf("ok, %d cached", shard.Cached)
+		if !shard.Healthy {
+			state = "unavailable: " + shard.Error
+		}
+		v.rows = append(v.rows, []string{"shard " + shard.Name, state})
+	}
+	return v, nil
+}
+
+// runDLQ выводит или удаляет отложенные сообщения.
+func runDLQ(ctx context.Context, sh *shell, args []string, raw string) (view, error) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		params := orderclient.ListDeadLettersParams{}
+		if len(args) > 2 {
+			return view{}, errUsage
+		}
+		if len(args) == 2 {
+			limit, err := strconv.Atoi(args[1])
+			if err != nil || limit < 1 {
+				return view{}, errUsage
+			}
+			params.Limit = limit
+		}
+		letters, err := sh.client.ListDeadLetters(ctx, params)
+		if err != nil {
+			return view{}, err
+		}
+		v := view{value: letters, header: []string{"ID", "SUBJECT", "CONTENT_TYPE", "SIZE", "RECEIVED", "ERROR"}}
+		for _, l := range letters {
+			v.rows = append(v.rows, []string{
+				itoa(l.ID), l.Subject, l.ContentType, strconv.Itoa(l.Size), l.CreatedAt.Format(time.DateTime), l.Error,
+			})
+		}
+		return v, nil
+	case "drop":
+		ids, err := parseIDs(args[1:])
+		if err != nil || len(ids) == 0 {
+			return view{}, errUsage
+		}
+		return eachLetter(ids, "dropped", func(id int64) error {
+			return sh.client.DeleteDeadLetter(ctx, orderclient.DeleteDeadLetterParams{ID: id})
+		})
+	}
+	return view{}, errUsage
+}
+
+// runReplay публикует отложенные сообщения заново.
+func runReplay(ctx context.Context, sh *shell, args []string, raw string) (view, error) {
+	var ids []int64
+	if len(args) == 1 && args[0] == "all" {
+		letters, err := sh.client.ListDeadLetters(ctx, orderclient.ListDeadLettersParams{Limit: maxReplayAll})
+		if err != nil {
+			return view{}, err
+		}
+		for _, l := range letters {
+			ids = append(ids, l.ID)
+		}
+	} else {
+		var err error
+		if ids, err = parseIDs(args); err != nil || len(ids) == 0 {
+			return view{}, errUsage
+		}
+	}
+	return eachLetter(ids, "replayed", func(id int64) error {
+		return sh.client.ReplayDeadLetter(ctx, orderclient.ReplayDeadLetterParams{ID: id})
+	})
+}
+
+// letterResult результат действия над отложенным сообщением.
+type letterResult struct {
+	ID     int64  `json:"id"`
+	Result string `json:"result"`
+}
+
+// eachLetter выполняет действие над каждым сообщением и возвращает результат по каждому;
+// ошибка по одному сообщению не останавливает обработку остальных.
+func eachLetter(ids []int64, done string, fn func(id int64) error) (view, error) {
+	results := []letterResult{}
+	v := view{header: []string{"ID", "RESULT"}}
+	failed := 0
+	for _, id := range ids {
+		r := letterResult{ID: id, Result: done}
+		if err := fn(id); err != nil {
+			r.Result = "error: " + err.Error()
+			failed++
+		}
+		results = append(results, r)
+		v.rows = append(v.rows, []string{itoa(r.ID), r.Result})
+	}
+	v.value = results
+	if failed > 0 {
+		v.footer = fmt.Sprintf("%d of %d failed", failed, len(ids))
+	}
+	return v, nil
+}
+
+// parseIDs разбирает номера отложенных сообщений.
+func parseIDs(args []string) ([]int64, error) {
+	ids := make([]int64, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// runCache вытесняет заказы из кэша сервиса.
+func runCache(ctx context.Context, sh *shell, args []string, raw string) (view, error) {
+	if len(args) < 2 || args[0] != "evict" {
+		return view{}, errUsage
+	}
+	result, err := sh.client.EvictCache(ctx, orderclient.EvictRequest{IDs: args[1:]})
+	if err != nil {
+		return view{}, err
+	}
+	v := view{value: result, header: []string{"ORDER_UID", "EVICTED", "RELOADED"}}
+	for _, id := range args[1:] {
+		v.rows = append(v.rows, []string{id, yesNo(slices.Contains(result.Evicted, id)), yesNo(slices.Contains(result.Reloaded, id))})
+	}
+	return v, nil
+}
+
+// yesNo форматирует логическое значение для таблицы.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
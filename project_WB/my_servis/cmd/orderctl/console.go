@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	prompt       = "orderctl> " // prompt приглашение консоли.
+	historyFile  = ".orderctl_history"
+	historyLimit = 500 // historyLimit сколько последних команд хранится в истории.
+)
+
+// interactive запускает консоль в терминале: строка редактируется, Tab дополняет команды
+// и аргументы, стрелки листают историю, которая сохраняется между запусками.
+// Консоль завершается командой exit, Ctrl-D или Ctrl-C.
+func (sh *shell) interactive() error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	path := historyPath()
+	history := loadHistory(path)
+	t := newTerminal(os.Stdin, os.Stdout, history)
+	if width, height, err := term.GetSize(fd); err == nil {
+		t.SetSize(width, height)
+	}
+	c := &completer{candidates: sh.candidates}
+	t.AutoCompleteCallback = c.complete
+
+	fmt.Fprintln(t, "Type help for the list of commands, Tab to complete, exit to leave.")
+	for {
+		line, err := t.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		appendHistory(path, line)
+
+		err = sh.exec(t, line)
+		if errors.Is(err, errExit) {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintln(t, "error:", err)
+		}
+	}
+}
+
+// termIO ввод-вывод терминала с подменяемым выводом.
+type termIO struct {
+	io.Reader
+	out io.Writer
+}
+
+func (t *termIO) Write(p []byte) (int, error) {
+	return t.out.Write(p)
+}
+
+// newTerminal создает терминал и заполняет его историю. term.Terminal не позволяет задать историю
+// напрямую, поэтому сохраненные команды сначала «вводятся» в него построчно, а их эхо отбрасывается.
+func newTerminal(in io.Reader, out io.Writer, history []string) *term.Terminal {
+	var typed bytes.Buffer
+	for _, line := range history {
+		typed.WriteString(line)
+		typed.WriteByte('\r')
+	}
+	rw := &termIO{Reader: io.MultiReader(&typed, in), out: io.Discard}
+	t := term.NewTerminal(rw, prompt)
+	for range history {
+		t.ReadLine()
+	}
+	rw.out = out
+	return t
+}
+
+// historyPath возвращает путь к файлу истории в домашнем каталоге или пустую строку.
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFile)
+}
+
+// loadHistory читает последние historyLimit команд из файла истории и сокращает файл до них.
+// Строки с управляющими символами пропускаются: при заполнении истории они сработали бы как клавиши.
+func loadHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.ContainsFunc(line, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	f.Close()
+	if len(lines) > historyLimit {
+		lines = lines[len(lines)-historyLimit:]
+		os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	}
+	return lines
+}
+
+// appendHistory дописывает команду в файл истории.
+func appendHistory(path, line string) {
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// completer дополняет слово перед курсором по Tab. Единственный вариант подставляется целиком,
+// из нескольких дописывается их общее начало, а если его нет - повторные Tab перебирают варианты.
+type completer struct {
+	candidates func(args []string) []string
+
+	cycle []string // cycle перебираемые варианты
+	next  int      // next номер следующего варианта
+	start int      // start начало дополняемого слова
+	line  string   // line строка после последней подстановки
+	pos   int      // pos позиция курсора после последней подстановки
+}
+
+// complete реализует term.Terminal.AutoCompleteCallback.
+func (c *completer) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		c.cycle = nil
+		return "", 0, false
+	}
+	if len(c.cycle) > 0 && line == c.line && pos == c.pos {
+		return c.replace(line, c.start, pos, c.cycle[c.next%len(c.cycle)], false)
+	}
+	c.cycle = nil
+
+	head := line[:pos]
+	start := strings.LastIndexAny(head, " \t") + 1
+	prefix := head[start:]
+	var matches []string
+	for _, candidate := range c.candidates(strings.Fields(head[:start])) {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return "", 0, false
+	case len(matches) == 1:
+		return c.replace(line, start, pos, matches[0], true)
+	}
+	if common := commonPrefix(matches); len(common) > len(prefix) {
+		return c.replace(line, start, pos, common, false)
+	}
+	c.cycle, c.next, c.start = matches, 0, start
+	return c.replace(line, start, pos, matches[0], false)
+}
+
+// replace заменяет слово от start до pos на word; после законченного слова добавляется пробел,
+// кроме полей поиска, за которыми сразу следует значение.
+func (c *completer) replace(line string, start, pos int, word string, final bool) (string, int, bool) {
+	if final && !strings.HasSuffix(word, ":") {
+		word += " "
+	}
+	newLine := line[:start] + word + line[pos:]
+	newPos := start + len(word)
+	if c.cycle != nil {
+		c.next++
+		c.line, c.pos = newLine, newPos
+	}
+	return newLine, newPos, true
+}
+
+// commonPrefix возвращает общее начало строк.
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
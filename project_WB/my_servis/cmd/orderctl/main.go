@@ -0,0 +1,77 @@
+// Команда orderctl - консоль администратора сервиса заказов. Она работает через HTTP API
+// сервиса (маршруты /api/v1/admin/* и API заказов) и не зависит от его процесса и стандартного ввода.
+//
+//	orderctl -addr http://localhost:8080 -key KEY              интерактивная консоль
+//	orderctl -o json get b563feb7b2b84b6test                   одна команда
+//	orderctl -o yaml < commands.txt                            команды из файла, по одной на строку
+//
+// Адрес, API-ключ и токен по умолчанию берутся из переменных ORDERCTL_ADDR, ORDERCTL_API_KEY
+// и ORDERCTL_TOKEN; для команд status, dlq, replay и cache нужна область доступа admin.
+// В интерактивной консоли Tab дополняет команды и аргументы, а история хранится в ~/.orderctl_history.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Selandro/my_servis_order/project_WB/orderclient"
+	"golang.org/x/term"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("ORDERCTL_ADDR", "http://localhost:8080"), "service address")
+	key := flag.String("key", os.Getenv("ORDERCTL_API_KEY"), "API key")
+	token := flag.String("token", os.Getenv("ORDERCTL_TOKEN"), "JWT bearer token, used instead of -key")
+	output := flag.String("o", formatTable, "output format: "+strings.Join(formats, ", "))
+	timeout := flag.Duration("timeout", 10*time.Second, "request timeout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: orderctl [flags] [command [args]]\n\nflags:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\ncommands:\n")
+		(&shell{}).help(flag.CommandLine.Output())
+	}
+	flag.Parse()
+	if !validFormat(*output) {
+		log.Fatalf("unknown output format %q", *output)
+	}
+
+	opts := []orderclient.Option{orderclient.WithUserAgent("orderctl/" + orderclient.Version)}
+	if *token != "" {
+		opts = append(opts, orderclient.WithBearerToken(*token))
+	} else if *key != "" {
+		opts = append(opts, orderclient.WithAPIKey(*key))
+	}
+	sh := &shell{client: orderclient.New(*addr, opts...), format: *output, timeout: *timeout}
+
+	switch {
+	case flag.NArg() > 0:
+		args := flag.Args()
+		if err := sh.run(os.Stdout, args, strings.Join(args[1:], " ")); err != nil && err != errExit {
+			log.Fatalf("%s: %v", args[0], err)
+		}
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		if err := sh.interactive(); err != nil {
+			log.Fatalf("Console error: %v", err)
+		}
+	default:
+		failed, err := sh.script(os.Stdin, os.Stdout, os.Stderr)
+		if err != nil {
+			log.Fatalf("Error reading commands: %v", err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// envOr возвращает значение переменной окружения или значение по умолчанию.
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
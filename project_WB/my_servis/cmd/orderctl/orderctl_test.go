@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Selandro/my_servis_order/project_WB/orderclient"
+)
+
+func TestScript(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/admin/status", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(orderclient.Status{
+			StartedAt: time.Now().Add(-time.Hour), Ingested: 3, Cached: 2, DeadLetters: 1,
+			Shards: []orderclient.ShardStatus{{Name: "default", Cached: 2, Healthy: true}},
+		})
+	})
+	mux.HandleFunc("GET /api/v1/orders/search", func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("q"); q != `name:"Test Testov" amount>1000` {
+			http.Error(w, "unexpected query "+q, http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"orders":[{"order_uid":"b563feb7b2b84b6test","payment":{"amount":1817,"currency":"USD"}}],"page":1,"size":20,"total":1}`)
+	})
+	mux.HandleFunc("POST /api/v1/admin/dlq/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "7" {
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	sh := &shell{client: orderclient.New(srv.URL), format: formatTable, timeout: time.Second}
+	script := strings.Join([]string{
+		"# comment",
+		"status",
+		`find name:"Test Testov" amount>1000`,
+		"output yaml",
+		"replay 7 8",
+		"get",
+		"unknown",
+	}, "\n")
+	var out, errs bytes.Buffer
+	failed, err := sh.script(strings.NewReader(script), &out, &errs)
+	if err != nil || failed != 2 {
+		t.Fatalf("script failed %d commands, err %v; stderr:\n%s", failed, err, &errs)
+	}
+	for _, want := range []string{
+		"shard default  ok, 2 cached",
+		"b563feb7b2b84b6test",
+		"18.17 USD",
+		"page 1 of 1, 1 orders",
+		"- id: 7\n  result: replayed\n",
+		"- id: 8\n  result: 'error: orderclient: 404 Not Found: Dead letter not found'\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, &out)
+		}
+	}
+	if !strings.Contains(errs.String(), "line 6: invalid arguments, usage: get ORDER_UID") {
+		t.Errorf("stderr = %s", &errs)
+	}
+	if !reflect.DeepEqual(sh.seen, []string{"b563feb7b2b84b6test"}) {
+		t.Errorf("seen = %v", sh.seen)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	sh := &shell{seen: []string{"b563feb7b2b84b6test", "c8f1e2a0d93b4c1test"}}
+	c := &completer{candidates: sh.candidates}
+	tab := func(line string) string {
+		newLine, _, ok := c.complete(line, len(line), '\t')
+		if !ok {
+			return line
+		}
+		return newLine
+	}
+	cases := []struct{ line, want string }{
+		{"sta", "stat"},
+		{"statu", "status "},
+		{"stats ba", "stats basket "},
+		{"find city:Moscow -bra", "find city:Moscow -brand:"},
+		{"cache evict c8", "cache evict c8f1e2a0d93b4c1test "},
+		{"output y", "output yaml "},
+		{"list 2 ", "list 2 "},
+	}
+	for _, tc := range cases {
+		c.complete("", 0, 'x')
+		if got := tab(tc.line); got != tc.want {
+			t.Errorf("complete(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+
+	// Без общего начала повторные Tab перебирают варианты
+	c.complete("", 0, 'x')
+	line := tab("get ")
+	if line != "get b563feb7b2b84b6test" || tab(line) != "get c8f1e2a0d93b4c1test" {
+		t.Errorf("cycling completion = %q", line)
+	}
+}
+
+func TestTerminalHistory(t *testing.T) {
+	var out bytes.Buffer
+	term := newTerminal(strings.NewReader("\x1b[A\x1b[A\r"), &out, []string{"status", "dlq list"})
+	line, err := term.ReadLine()
+	if err != nil || line != "status" {
+		t.Fatalf("ReadLine = %q, %v, want the second to last history entry", line, err)
+	}
+	if n := strings.Count(out.String(), prompt); n != 1 {
+		t.Errorf("prompt written %d times, history was echoed while loading: %q", n, out.String())
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`find  name:"Test Testov" 'a b'`)
+	if err != nil || !reflect.DeepEqual(args, []string{"find", "name:Test Testov", "a b"}) {
+		t.Errorf("splitArgs = %q, %v", args, err)
+	}
+	if _, err := splitArgs(`get "x`); err == nil {
+		t.Error("unterminated quote accepted")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Форматы вывода результатов команд.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// formats допустимые форматы вывода.
+var formats = []string{formatTable, formatJSON, formatYAML}
+
+// validFormat проверяет, что формат вывода известен.
+func validFormat(format string) bool {
+	return slices.Contains(formats, format)
+}
+
+// view результат команды: значение для вывода в JSON и YAML и его представление в виде таблицы.
+type view struct {
+	value  any
+	header []string
+	rows   [][]string
+	footer string // footer строка под таблицей, например номер страницы
+}
+
+// pairs возвращает представление значения таблицей из двух столбцов: поле и значение.
+func pairs(value any, rows ...[]string) view {
+	return view{value: value, header: []string{"FIELD", "VALUE"}, rows: rows}
+}
+
+// write выводит результат команды в выбранном формате.
+func (v view) write(w io.Writer, format string) error {
+	switch format {
+	case formatJSON:
+		data, err := json.MarshalIndent(v.value, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case formatYAML:
+		data, err := toYAML(v.value)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	if len(v.rows) == 0 {
+		fmt.Fprintln(w, "(no results)")
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(v.header, "\t"))
+		for _, row := range v.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if v.footer != "" {
+		fmt.Fprintln(w, v.footer)
+	}
+	return nil
+}
+
+// toYAML кодирует значение в YAML через его JSON-представление: так имена и порядок полей
+// совпадают с ответами API, а не с именами полей Go.
+func toYAML(value any) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blockStyle убирает стиль JSON (фигурные скобки и кавычки) из разобранного документа,
+// чтобы он выводился обычным блочным YAML; кавычки остаются там, где без них изменится тип значения.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Selandro/my_servis_order/project_WB/orderclient"
+)
+
+// errExit возвращается командой exit.
+var errExit = errors.New("exit")
+
+// builtins команды самой консоли, которые не обращаются к сервису.
+var builtins = []string{"help", "output", "exit", "quit"}
+
+// shell выполняет команды консоли.
+type shell struct {
+	client  *orderclient.Client
+	format  string
+	timeout time.Duration
+	seen    []string // seen идентификаторы заказов из последних ответов, для дополнения
+}
+
+// exec выполняет одну строку консоли и выводит результат в w.
+func (sh *shell) exec(w io.Writer, line string) error {
+	args, err := splitArgs(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return sh.run(w, args, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), args[0])))
+}
+
+// run выполняет команду args[0] с аргументами args[1:]; raw - исходная строка аргументов
+// для команд, которые разбирают ее сами.
+func (sh *shell) run(w io.Writer, args []string, raw string) error {
+	name, args := args[0], args[1:]
+	switch name {
+	case "help":
+		return sh.help(w)
+	case "output":
+		if len(args) == 0 {
+			fmt.Fprintln(w, sh.format)
+			return nil
+		}
+		if len(args) > 1 || !validFormat(args[0]) {
+			return fmt.Errorf("%w, usage: output [%s]", errUsage, strings.Join(formats, "|"))
+		}
+		sh.format = args[0]
+		return nil
+	case "exit", "quit":
+		return errExit
+	}
+
+	c, ok := findCommand(name)
+	if !ok {
+		return fmt.Errorf("unknown command %q, type help for the list of commands", name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sh.timeout)
+	defer cancel()
+	v, err := c.run(ctx, sh, args, raw)
+	if errors.Is(err, errUsage) {
+		return fmt.Errorf("%w, usage: %s", err, c.usage)
+	}
+	if err != nil {
+		return err
+	}
+	return v.write(w, sh.format)
+}
+
+// help выводит список команд.
+func (sh *shell) help(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "%s\t%s\n", c.usage, c.help)
+	}
+	fmt.Fprintf(tw, "output [%s]\tshow or change the output format\n", strings.Join(formats, "|"))
+	fmt.Fprintf(tw, "exit\tleave the console\n")
+	return tw.Flush()
+}
+
+// remember запоминает идентификатор заказа для дополнения в командах get и cache evict.
+func (sh *shell) remember(orderUID string) {
+	if orderUID == "" {
+		return
+	}
+	sh.seen = slices.DeleteFunc(sh.seen, func(s string) bool { return s == orderUID })
+	sh.seen = append(sh.seen, orderUID)
+	if len(sh.seen) > maxSeenOrders {
+		sh.seen = sh.seen[len(sh.seen)-maxSeenOrders:]
+	}
+}
+
+// candidates возвращает варианты дополнения слова, следующего за args.
+func (sh *shell) candidates(args []string) []string {
+	if len(args) == 0 {
+		names := slices.Clone(builtins)
+		for _, c := range commands {
+			names = append(names, c.name)
+		}
+		return names
+	}
+	if args[0] == "output" {
+		return argAt(args, 1, formats)
+	}
+	c, ok := findCommand(args[0])
+	if !ok || c.complete == nil {
+		return nil
+	}
+	return c.complete(sh, args[1:])
+}
+
+// script выполняет команды из r по одной на строку; пустые строки и строки с # пропускаются.
+// Ошибки выводятся в errw с номером строки, выполнение продолжается. Возвращает количество ошибок.
+func (sh *shell) script(r io.Reader, w, errw io.Writer) (int, error) {
+	scanner := bufio.NewScanner(r)
+	failed := 0
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		err := sh.exec(w, line)
+		if errors.Is(err, errExit) {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(errw, "line %d: %v\n", n, err)
+			failed++
+		}
+	}
+	return failed, scanner.Err()
+}
+
+// splitArgs разбивает строку на аргументы по пробелам; значения в одинарных или двойных кавычках
+// не разбиваются, а кавычки убираются.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
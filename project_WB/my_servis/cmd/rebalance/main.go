@@ -0,0 +1,50 @@
+// Команда rebalance переносит заказы между шардами после изменения sharding.shards в конфигурации:
+// каждый заказ, ключ которого теперь относится к другому шарду, копируется в новый шард
+// и удаляется из старого.
+//
+//	CONFIG_PATH=config/local.yaml rebalance -dry-run
+//	CONFIG_PATH=config/local.yaml rebalance
+//
+// Сервис нужно перезапустить после переноса, чтобы разделы кэша соответствовали новым шардам.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"sort"
+
+	config "main.go/internal"
+	"main.go/internal/pii"
+	database "main.go/internal/storage/database"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only count orders that would be moved")
+	flag.Parse()
+
+	cfg := config.MustLoad()
+	if err := pii.Setup(cfg.PII); err != nil {
+		log.Fatalf("Error loading PII keys: %v", err)
+	}
+	cluster := database.Connect(cfg.Database)
+	defer cluster.Close()
+	shards := database.ConnectShards(cfg.Sharding, cfg.Database, cluster)
+	defer shards.Close()
+
+	moved, err := shards.Rebalance(context.Background(), *dryRun)
+	routes := make([]string, 0, len(moved))
+	for route := range moved {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		log.Printf("%s: %d orders", route, moved[route])
+	}
+	if err != nil {
+		log.Fatalf("Error rebalancing shards: %v", err)
+	}
+	if len(moved) == 0 {
+		log.Printf("All orders are on their shards")
+	}
+}
@@ -0,0 +1,41 @@
+// Команда rotatekeys перешифровывает персональные данные получателей во всех шардах основным ключом
+// из файла ключей pii.key_file и шифрует данные, сохраненные до включения шифрования.
+//
+//	CONFIG_PATH=config/local.yaml rotatekeys
+//
+// Порядок ротации: добавить новый ключ в файл и сделать его основным, перезапустить сервис,
+// запустить rotatekeys. Старый ключ можно удалить из файла, когда ротация завершена
+// и исходящие очереди событий и вебхуков опустели.
+package main
+
+import (
+	"context"
+	"log"
+
+	config "main.go/internal"
+	"main.go/internal/pii"
+	database "main.go/internal/storage/database"
+)
+
+func main() {
+	cfg := config.MustLoad()
+	if err := pii.Setup(cfg.PII); err != nil {
+		log.Fatalf("Error loading PII keys: %v", err)
+	}
+	if !pii.Enabled() {
+		log.Fatalf("pii.key_file is not set")
+	}
+
+	cluster := database.Connect(cfg.Database)
+	defer cluster.Close()
+	shards := database.ConnectShards(cfg.Sharding, cfg.Database, cluster)
+	defer shards.Close()
+
+	for _, name := range shards.Names() {
+		deliveries, documents, err := database.RotatePII(context.Background(), shards.DB(name))
+		log.Printf("%s: %d deliveries, %d documents re-encrypted", name, deliveries, documents)
+		if err != nil {
+			log.Fatalf("Error rotating keys on shard %s: %v", name, err)
+		}
+	}
+}
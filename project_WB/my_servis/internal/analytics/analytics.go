@@ -0,0 +1,497 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+// TimeRange задает интервал [From, To) для аналитических запросов. Нулевые границы не ограничивают выборку.
+// Непустая валюта Currency ограничивает выборку заказами с оплатой в этой валюте.
+type TimeRange struct {
+	From     time.Time
+	To       time.Time
+	Currency string
+}
+
+// OrdersBucket содержит количество заказов и выручку в одной валюте за один интервал времени.
+type OrdersBucket struct {
+	Bucket   time.Time `json:"bucket"`
+	Currency string    `json:"currency"`
+	Orders   int64     `json:"orders"`
+	Revenue  int64     `json:"revenue"`
+	Items    int64     `json:"items"`
+}
+
+// Basket содержит средний размер корзины за период по заказам в одной валюте.
+type Basket struct {
+	Currency  string  `json:"currency"`
+	Orders    int64   `json:"orders"`
+	Revenue   int64   `json:"revenue"`
+	AvgAmount float64 `json:"avg_amount"`
+	AvgItems  float64 `json:"avg_items"`
+}
+
+// TopEntry представляет строку рейтинга брендов или товаров по заказам в одной валюте.
+type TopEntry struct {
+	Key      string `json:"key"`
+	Currency string `json:"currency"`
+	Items    int64  `json:"items"`
+	Revenue  int64  `json:"revenue"`
+}
+
+// Breakdown представляет строку разбивки заказов в одной валюте по признаку.
+type Breakdown struct {
+	Key      string `json:"key"`
+	Currency string `json:"currency"`
+	Orders   int64  `json:"orders"`
+	Revenue  int64  `json:"revenue"`
+}
+
+// Допустимые интервалы группировки по времени.
+var intervals = map[string]string{
+	"hour": "hour",
+	"day":  "day",
+}
+
+// Допустимые признаки разбивки платежей.
+var paymentGroups = map[string]string{
+	"provider": "provider",
+	"bank":     "bank",
+	"currency": "currency",
+}
+
+// Допустимые признаки разбивки доставки.
+var deliveryGroups = map[string]string{
+	"delivery_service": "delivery_service",
+	"region":           "region",
+}
+
+// dropLegacyTables удаляет сводные таблицы, созданные до перехода на время с часовым поясом
+// и разбивку выручки по валютам. Сводные данные производны от заказов, поэтому таблицы
+// создаются заново и заполняются функцией Backfill.
+const dropLegacyTables = `
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns
+		           WHERE table_name = 'stats_orders_hourly' AND column_name = 'bucket' AND data_type = 'timestamp without time zone') THEN
+			DROP TABLE IF EXISTS stats_orders_hourly, stats_brands_hourly, stats_products_hourly, stats_payments_hourly, stats_delivery_hourly;
+		END IF;
+	END $$;`
+
+// CreateTables создает сводные таблицы аналитики, если они еще не существуют.
+// Все таблицы агрегируются по часу создания заказа в UTC, поэтому любые более крупные интервалы
+// получаются суммированием. Выручка в разных валютах не складывается: каждая строка относится к одной валюте.
+func CreateTables(db *sql.DB) {
+	queries := []string{dropLegacyTables, `
+	CREATE TABLE IF NOT EXISTS stats_orders_hourly (
+		bucket TIMESTAMPTZ,
+		currency VARCHAR(255),
+		orders BIGINT NOT NULL DEFAULT 0,
+		revenue BIGINT NOT NULL DEFAULT 0,
+		items BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (bucket, currency)
+	);`, `
+	CREATE TABLE IF NOT EXISTS stats_brands_hourly (
+		bucket TIMESTAMPTZ,
+		brand VARCHAR(255),
+		currency VARCHAR(255),
+		items BIGINT NOT NULL DEFAULT 0,
+		revenue BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (bucket, brand, currency)
+	);`, `
+	CREATE TABLE IF NOT EXISTS stats_products_hourly (
+		bucket TIMESTAMPTZ,
+		nm_id INT,
+		currency VARCHAR(255),
+		items BIGINT NOT NULL DEFAULT 0,
+		revenue BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (bucket, nm_id, currency)
+	);`, `
+	CREATE TABLE IF NOT EXISTS stats_payments_hourly (
+		bucket TIMESTAMPTZ,
+		provider VARCHAR(255),
+		bank VARCHAR(255),
+		currency VARCHAR(255),
+		orders BIGINT NOT NULL DEFAULT 0,
+		revenue BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (bucket, provider, bank, currency)
+	);`, `
+	CREATE TABLE IF NOT EXISTS stats_delivery_hourly (
+		bucket TIMESTAMPTZ,
+		delivery_service VARCHAR(255),
+		region VARCHAR(255),
+		currency VARCHAR(255),
+		orders BIGINT NOT NULL DEFAULT 0,
+		revenue BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (bucket, delivery_service, region, currency)
+	);`}
+
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			log.Fatalf("Error creating analytics tables: %v", err)
+		}
+	}
+}
+
+// totals счетчики одной строки сводной таблицы.
+type totals struct {
+	orders  int64
+	items   int64
+	revenue int64
+}
+
+// Ключи строк сводных таблиц.
+type (
+	ordersKey struct {
+		bucket   time.Time
+		currency string
+	}
+	brandKey struct {
+		bucket          time.Time
+		brand, currency string
+	}
+	productKey struct {
+		bucket   time.Time
+		nmID     int
+		currency string
+	}
+	paymentKey struct {
+		bucket                   time.Time
+		provider, bank, currency string
+	}
+	deliveryKey struct {
+		bucket                    time.Time
+		service, region, currency string
+	}
+)
+
+// summary приращения сводных таблиц для набора заказов.
+type summary struct {
+	orders   map[ordersKey]*totals
+	brands   map[brandKey]*totals
+	products map[productKey]*totals
+	payments map[paymentKey]*totals
+	delivery map[deliveryKey]*totals
+}
+
+func newSummary() *summary {
+	return &summary{
+		orders:   make(map[ordersKey]*totals),
+		brands:   make(map[brandKey]*totals),
+		products: make(map[productKey]*totals),
+		payments: make(map[paymentKey]*totals),
+		delivery: make(map[deliveryKey]*totals),
+	}
+}
+
+// bucketOf возвращает час создания заказа в UTC, к которому относятся его строки сводных таблиц.
+func bucketOf(order model.Order) time.Time {
+	return order.DateCreated.UTC().Truncate(time.Hour)
+}
+
+// add учитывает заказ в приращениях. Выручка заказа и его товаров относится к валюте платежа.
+func (s *summary) add(order model.Order) {
+	bucket := bucketOf(order)
+	currency := string(order.Payment.Currency)
+	amount := int64(order.Payment.Amount)
+
+	addTo(s.orders, ordersKey{bucket, currency}, totals{orders: 1, items: int64(len(order.Items)), revenue: amount})
+	for _, item := range order.Items {
+		addTo(s.brands, brandKey{bucket, item.Brand, currency}, totals{items: 1, revenue: int64(item.TotalPrice)})
+		addTo(s.products, productKey{bucket, item.NMID, currency}, totals{items: 1, revenue: int64(item.TotalPrice)})
+	}
+	addTo(s.payments, paymentKey{bucket, order.Payment.Provider, order.Payment.Bank, currency}, totals{orders: 1, revenue: amount})
+	addTo(s.delivery, deliveryKey{bucket, order.DeliveryService, order.Delivery.Region, currency}, totals{orders: 1, revenue: amount})
+}
+
+// addTo прибавляет t к строке key.
+func addTo[K comparable](rows map[K]*totals, key K, t totals) {
+	row, ok := rows[key]
+	if !ok {
+		row = &totals{}
+		rows[key] = row
+	}
+	row.orders += t.orders
+	row.items += t.items
+	row.revenue += t.revenue
+}
+
+// write прибавляет приращения к сводным таблицам в транзакции tx.
+func (s *summary) write(ctx context.Context, tx *sql.Tx) error {
+	for k, t := range s.orders {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO stats_orders_hourly (bucket, currency, orders, revenue, items)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (bucket, currency) DO UPDATE SET
+				orders = stats_orders_hourly.orders + EXCLUDED.orders,
+				revenue = stats_orders_hourly.revenue + EXCLUDED.revenue,
+				items = stats_orders_hourly.items + EXCLUDED.items`,
+			k.bucket, k.currency, t.orders, t.revenue, t.items)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления статистики заказов: %v", err)
+		}
+	}
+	for k, t := range s.brands {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO stats_brands_hourly (bucket, brand, currency, items, revenue)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (bucket, brand, currency) DO UPDATE SET
+				items = stats_brands_hourly.items + EXCLUDED.items,
+				revenue = stats_brands_hourly.revenue + EXCLUDED.revenue`,
+			k.bucket, k.brand, k.currency, t.items, t.revenue)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления статистики брендов: %v", err)
+		}
+	}
+	for k, t := range s.products {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO stats_products_hourly (bucket, nm_id, currency, items, revenue)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (bucket, nm_id, currency) DO UPDATE SET
+				items = stats_products_hourly.items + EXCLUDED.items,
+				revenue = stats_products_hourly.revenue + EXCLUDED.revenue`,
+			k.bucket, k.nmID, k.currency, t.items, t.revenue)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления статистики товаров: %v", err)
+		}
+	}
+	for k, t := range s.payments {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO stats_payments_hourly (bucket, provider, bank, currency, orders, revenue)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (bucket, provider, bank, currency) DO UPDATE SET
+				orders = stats_payments_hourly.orders + EXCLUDED.orders,
+				revenue = stats_payments_hourly.revenue + EXCLUDED.revenue`,
+			k.bucket, k.provider, k.bank, k.currency, t.orders, t.revenue)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления статистики платежей: %v", err)
+		}
+	}
+	for k, t := range s.delivery {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO stats_delivery_hourly (bucket, delivery_service, region, currency, orders, revenue)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (bucket, delivery_service, region, currency) DO UPDATE SET
+				orders = stats_delivery_hourly.orders + EXCLUDED.orders,
+				revenue = stats_delivery_hourly.revenue + EXCLUDED.revenue`,
+			k.bucket, k.service, k.region, k.currency, t.orders, t.revenue)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления статистики доставки: %v", err)
+		}
+	}
+	return nil
+}
+
+// RecordOrder добавляет новый заказ в сводные таблицы в транзакции tx.
+// Вызывается при применении события о сохранении заказа, один раз на заказ.
+func RecordOrder(ctx context.Context, order model.Order, tx *sql.Tx) error {
+	s := newSummary()
+	s.add(order)
+	return s.write(ctx, tx)
+}
+
+// Empty сообщает, что сводные таблицы пусты, например при первом запуске на уже заполненной базе
+// или после пересоздания таблиц.
+func Empty(ctx context.Context, db *sql.DB) (bool, error) {
+	var empty bool
+	if err := db.QueryRowContext(ctx, "SELECT NOT EXISTS(SELECT 1 FROM stats_orders_hourly)").Scan(&empty); err != nil {
+		return false, fmt.Errorf("ошибка проверки сводных таблиц: %v", err)
+	}
+	return empty, nil
+}
+
+// Backfill заполняет сводные таблицы по заказам orders одной транзакцией.
+func Backfill(ctx context.Context, orders []model.Order, db *sql.DB) error {
+	s := newSummary()
+	for _, order := range orders {
+		s.add(order)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции аналитики: %v", err)
+	}
+	defer tx.Rollback()
+	if err := s.write(ctx, tx); err != nil {
+		return fmt.Errorf("ошибка пересчета сводных таблиц: %v", err)
+	}
+	return tx.Commit()
+}
+
+// OrdersByInterval возвращает количество заказов и выручку по валютам с группировкой по часу или дню UTC.
+func OrdersByInterval(db *sql.DB, tr TimeRange, interval string) ([]OrdersBucket, error) {
+	trunc, ok := intervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный интервал: %s", interval)
+	}
+	where, args := tr.where()
+	query := fmt.Sprintf(`
+		SELECT date_trunc('%s', bucket, 'UTC') AS b, currency, SUM(orders), SUM(revenue), SUM(items)
+		FROM stats_orders_hourly
+		%s
+		GROUP BY b, currency
+		ORDER BY b, currency`, trunc, where)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order stats: %v", err)
+	}
+	defer rows.Close()
+
+	result := []OrdersBucket{}
+	for rows.Next() {
+		var b OrdersBucket
+		if err := rows.Scan(&b.Bucket, &b.Currency, &b.Orders, &b.Revenue, &b.Items); err != nil {
+			return nil, fmt.Errorf("error scanning order stats row: %v", err)
+		}
+		b.Bucket = b.Bucket.UTC()
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
+
+// BasketSize возвращает средний чек и среднее количество товаров в заказе за период, по валютам.
+func BasketSize(db *sql.DB, tr TimeRange) ([]Basket, error) {
+	where, args := tr.where()
+	query := fmt.Sprintf(`
+		SELECT currency, SUM(orders), SUM(revenue), SUM(items)
+		FROM stats_orders_hourly
+		%s
+		GROUP BY currency
+		ORDER BY currency`, where)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching basket stats: %v", err)
+	}
+	defer rows.Close()
+
+	result := []Basket{}
+	for rows.Next() {
+		var b Basket
+		var items int64
+		if err := rows.Scan(&b.Currency, &b.Orders, &b.Revenue, &items); err != nil {
+			return nil, fmt.Errorf("error scanning basket stats row: %v", err)
+		}
+		result = append(result, basket(b, items))
+	}
+	return result, rows.Err()
+}
+
+// basket вычисляет средние значения корзины по сумме заказов, выручки и товаров.
+func basket(b Basket, items int64) Basket {
+	if b.Orders > 0 {
+		b.AvgAmount = float64(b.Revenue) / float64(b.Orders)
+		b.AvgItems = float64(items) / float64(b.Orders)
+	}
+	return b
+}
+
+// TopBrands возвращает бренды с наибольшим количеством проданных товаров.
+func TopBrands(db *sql.DB, tr TimeRange, limit int) ([]TopEntry, error) {
+	return top(db, "stats_brands_hourly", "brand", tr, limit)
+}
+
+// TopProducts возвращает товары (nm_id) с наибольшим количеством продаж.
+func TopProducts(db *sql.DB, tr TimeRange, limit int) ([]TopEntry, error) {
+	return top(db, "stats_products_hourly", "nm_id", tr, limit)
+}
+
+// RevenueByPayment возвращает выручку с разбивкой по провайдеру, банку или валюте.
+func RevenueByPayment(db *sql.DB, tr TimeRange, group string) ([]Breakdown, error) {
+	column, ok := paymentGroups[group]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная группировка платежей: %s", group)
+	}
+	return breakdown(db, "stats_payments_hourly", column, tr)
+}
+
+// DeliveryBreakdown возвращает заказы и выручку с разбивкой по службе доставки или региону.
+func DeliveryBreakdown(db *sql.DB, tr TimeRange, group string) ([]Breakdown, error) {
+	column, ok := deliveryGroups[group]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная группировка доставки: %s", group)
+	}
+	return breakdown(db, "stats_delivery_hourly", column, tr)
+}
+
+// top строит рейтинг по указанной сводной таблице; ключи с заказами в разных валютах
+// занимают в рейтинге отдельные строки.
+func top(db *sql.DB, table, column string, tr TimeRange, limit int) ([]TopEntry, error) {
+	where, args := tr.where()
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		SELECT %s::text, currency, SUM(items) AS total_items, SUM(revenue)
+		FROM %s
+		%s
+		GROUP BY %s, currency
+		ORDER BY total_items DESC, 1, currency
+		LIMIT $%d`, column, table, where, column, len(args))
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching top %s: %v", column, err)
+	}
+	defer rows.Close()
+
+	result := []TopEntry{}
+	for rows.Next() {
+		var e TopEntry
+		if err := rows.Scan(&e.Key, &e.Currency, &e.Items, &e.Revenue); err != nil {
+			return nil, fmt.Errorf("error scanning top %s row: %v", column, err)
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+// breakdown строит разбивку по указанной колонке сводной таблицы и валюте.
+func breakdown(db *sql.DB, table, column string, tr TimeRange) ([]Breakdown, error) {
+	where, args := tr.where()
+	query := fmt.Sprintf(`
+		SELECT %s, currency, SUM(orders), SUM(revenue) AS total_revenue
+		FROM %s
+		%s
+		GROUP BY %s, currency
+		ORDER BY currency, total_revenue DESC, 1`, column, table, where, column)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s breakdown: %v", column, err)
+	}
+	defer rows.Close()
+
+	result := []Breakdown{}
+	for rows.Next() {
+		var b Breakdown
+		if err := rows.Scan(&b.Key, &b.Currency, &b.Orders, &b.Revenue); err != nil {
+			return nil, fmt.Errorf("error scanning %s breakdown row: %v", column, err)
+		}
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
+
+// where формирует условие WHERE по колонкам bucket и currency для заданной выборки.
+func (tr TimeRange) where() (string, []any) {
+	var conds []string
+	var args []any
+	if !tr.From.IsZero() {
+		args = append(args, tr.From)
+		conds = append(conds, fmt.Sprintf("bucket >= $%d", len(args)))
+	}
+	if !tr.To.IsZero() {
+		args = append(args, tr.To)
+		conds = append(conds, fmt.Sprintf("bucket < $%d", len(args)))
+	}
+	if tr.Currency != "" {
+		args = append(args, tr.Currency)
+		conds = append(conds, fmt.Sprintf("currency = $%d", len(args)))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,131 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+func testOrder(uid, currency string, created time.Time, amount int64, items ...model.Item) model.Order {
+	return model.Order{
+		OrderUID:        uid,
+		DeliveryService: "meest",
+		DateCreated:     created,
+		Delivery:        model.Delivery{Region: "Kraiot"},
+		Payment:         model.Payment{Currency: model.Currency(currency), Provider: "wbpay", Bank: "alpha", Amount: model.Amount(amount)},
+		Items:           items,
+	}
+}
+
+func TestSummaryAddBucketsInUTC(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	s := newSummary()
+	// Оба заказа созданы в 09:00-10:00 UTC, хотя время первого записано в другом поясе
+	s.add(testOrder("a", "RUB", time.Date(2026, 3, 1, 12, 15, 0, 0, msk), 100))
+	s.add(testOrder("b", "RUB", time.Date(2026, 3, 1, 9, 59, 59, 0, time.UTC), 200))
+	s.add(testOrder("c", "RUB", time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), 300))
+
+	hour := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	want := map[ordersKey]totals{
+		{hour, "RUB"}:                {orders: 2, revenue: 300},
+		{hour.Add(time.Hour), "RUB"}: {orders: 1, revenue: 300},
+	}
+	if got := deref(s.orders); !reflect.DeepEqual(got, want) {
+		t.Errorf("orders = %+v, want %+v", got, want)
+	}
+	for k := range s.orders {
+		if k.bucket.Location() != time.UTC {
+			t.Errorf("bucket %v is not in UTC", k.bucket)
+		}
+	}
+}
+
+func TestSummaryAddSeparatesCurrencies(t *testing.T) {
+	created := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	hour := created.Truncate(time.Hour)
+	s := newSummary()
+	s.add(testOrder("a", "RUB", created, 1000,
+		model.Item{NMID: 1, Brand: "Vivienne Sabo", TotalPrice: 400},
+		model.Item{NMID: 2, Brand: "Vivienne Sabo", TotalPrice: 600}))
+	s.add(testOrder("b", "USD", created, 15,
+		model.Item{NMID: 1, Brand: "Vivienne Sabo", TotalPrice: 15}))
+
+	wantOrders := map[ordersKey]totals{
+		{hour, "RUB"}: {orders: 1, items: 2, revenue: 1000},
+		{hour, "USD"}: {orders: 1, items: 1, revenue: 15},
+	}
+	if got := deref(s.orders); !reflect.DeepEqual(got, wantOrders) {
+		t.Errorf("orders = %+v, want %+v", got, wantOrders)
+	}
+	wantBrands := map[brandKey]totals{
+		{hour, "Vivienne Sabo", "RUB"}: {items: 2, revenue: 1000},
+		{hour, "Vivienne Sabo", "USD"}: {items: 1, revenue: 15},
+	}
+	if got := deref(s.brands); !reflect.DeepEqual(got, wantBrands) {
+		t.Errorf("brands = %+v, want %+v", got, wantBrands)
+	}
+	wantProducts := map[productKey]totals{
+		{hour, 1, "RUB"}: {items: 1, revenue: 400},
+		{hour, 2, "RUB"}: {items: 1, revenue: 600},
+		{hour, 1, "USD"}: {items: 1, revenue: 15},
+	}
+	if got := deref(s.products); !reflect.DeepEqual(got, wantProducts) {
+		t.Errorf("products = %+v, want %+v", got, wantProducts)
+	}
+	wantPayments := map[paymentKey]totals{
+		{hour, "wbpay", "alpha", "RUB"}: {orders: 1, revenue: 1000},
+		{hour, "wbpay", "alpha", "USD"}: {orders: 1, revenue: 15},
+	}
+	if got := deref(s.payments); !reflect.DeepEqual(got, wantPayments) {
+		t.Errorf("payments = %+v, want %+v", got, wantPayments)
+	}
+	wantDelivery := map[deliveryKey]totals{
+		{hour, "meest", "Kraiot", "RUB"}: {orders: 1, revenue: 1000},
+		{hour, "meest", "Kraiot", "USD"}: {orders: 1, revenue: 15},
+	}
+	if got := deref(s.delivery); !reflect.DeepEqual(got, wantDelivery) {
+		t.Errorf("delivery = %+v, want %+v", got, wantDelivery)
+	}
+}
+
+func TestBasketAverages(t *testing.T) {
+	got := basket(Basket{Currency: "RUB", Orders: 4, Revenue: 1000}, 6)
+	want := Basket{Currency: "RUB", Orders: 4, Revenue: 1000, AvgAmount: 250, AvgItems: 1.5}
+	if got != want {
+		t.Errorf("basket = %+v, want %+v", got, want)
+	}
+	if got := basket(Basket{Currency: "USD"}, 0); got.AvgAmount != 0 || got.AvgItems != 0 {
+		t.Errorf("empty basket = %+v, want zero averages", got)
+	}
+}
+
+func TestTimeRangeWhere(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	tests := []struct {
+		tr       TimeRange
+		want     string
+		wantArgs []any
+	}{
+		{TimeRange{}, "", nil},
+		{TimeRange{Currency: "USD"}, "WHERE currency = $1", []any{"USD"}},
+		{TimeRange{From: from, To: to, Currency: "RUB"}, "WHERE bucket >= $1 AND bucket < $2 AND currency = $3", []any{from, to, "RUB"}},
+	}
+	for _, tt := range tests {
+		got, args := tt.tr.where()
+		if got != tt.want || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("where(%+v) = %q %v, want %q %v", tt.tr, got, args, tt.want, tt.wantArgs)
+		}
+	}
+}
+
+// deref копирует строки приращений для сравнения.
+func deref[K comparable](rows map[K]*totals) map[K]totals {
+	result := make(map[K]totals, len(rows))
+	for k, t := range rows {
+		result[k] = *t
+	}
+	return result
+}
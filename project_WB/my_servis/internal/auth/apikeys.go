@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	config "main.go/internal"
+)
+
+// typeMap используется для чтения массивов Postgres через database/sql.
+var typeMap = pgtype.NewMap()
+
+// apiKey возвращает API-ключ из заголовка X-API-Key.
+func apiKey(r *http.Request) string {
+	return r.Header.Get(APIKeyHeader)
+}
+
+// HashKey возвращает SHA-256 ключа в шестнадцатеричном виде. Ключи сравниваются по хешу,
+// поэтому время проверки не зависит от того, сколько символов ключа совпало.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// StaticKeys API-ключи из конфигурации.
+type StaticKeys struct {
+	byHash map[string]*Principal
+}
+
+// NewStaticKeys проверяет ключи из конфигурации и строит таблицу поиска по хешу.
+func NewStaticKeys(keys []config.APIKeyConfig) (*StaticKeys, error) {
+	s := &StaticKeys{byHash: make(map[string]*Principal, len(keys))}
+	for _, k := range keys {
+		if k.Name == "" || k.Key == "" {
+			return nil, errors.New("у API-ключа должны быть заданы name и key")
+		}
+		if err := ValidateScopes(k.Scopes); err != nil {
+			return nil, fmt.Errorf("API-ключ %s: %v", k.Name, err)
+		}
+		hash := HashKey(k.Key)
+		if _, dup := s.byHash[hash]; dup {
+			return nil, fmt.Errorf("API-ключ %s повторяет другой ключ", k.Name)
+		}
+		s.byHash[hash] = &Principal{ID: k.Name, Method: "api_key", Scopes: k.Scopes}
+	}
+	return s, nil
+}
+
+// Authenticate опознает клиента по API-ключу из конфигурации.
+func (s *StaticKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := apiKey(r)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	if p, ok := s.byHash[HashKey(key)]; ok {
+		return p, nil
+	}
+	// Ключ может найтись в таблице api_keys
+	return nil, ErrNoCredentials
+}
+
+// ValidateScopes проверяет, что все права известны.
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeOrdersRead, ScopeOrdersExport, ScopePIIRead, ScopeAdmin:
+		default:
+			return fmt.Errorf("неизвестное право %q", scope)
+		}
+	}
+	return nil
+}
+
+// CreateTables создает таблицу API-ключей. В таблице хранятся только хеши ключей.
+func CreateTables(db *sql.DB) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS api_keys (
+		key_hash VARCHAR(64) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL UNIQUE,
+		scopes TEXT[] NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		revoked_at TIMESTAMPTZ
+	);`)
+	if err != nil {
+		log.Fatalf("Error creating api keys table: %v", err)
+	}
+}
+
+// TableKeys API-ключи из таблицы api_keys; ключи можно выпускать и отзывать без перезапуска сервиса.
+type TableKeys struct {
+	db *sql.DB
+}
+
+// NewTableKeys создает проверку API-ключей по таблице api_keys.
+func NewTableKeys(db *sql.DB) *TableKeys {
+	return &TableKeys{db: db}
+}
+
+// Authenticate опознает клиента по действующему API-ключу из таблицы.
+func (t *TableKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := apiKey(r)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	p := &Principal{Method: "api_key"}
+	err := t.db.QueryRowContext(r.Context(),
+		"SELECT name, scopes FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL", HashKey(key)).
+		Scan(&p.ID, typeMap.SQLScanner(&p.Scopes))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки API-ключа: %v", err)
+	}
+	return p, nil
+}
+
+// CreateKey выпускает API-ключ с именем name и правами scopes и возвращает его значение.
+// Значение ключа не сохраняется и показывается только один раз.
+func CreateKey(name string, scopes []string, db *sql.DB) (string, error) {
+	if err := ValidateScopes(scopes); err != nil {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(b)
+	_, err := db.Exec("INSERT INTO api_keys (key_hash, name, scopes) VALUES ($1, $2, $3)", HashKey(key), name, scopes)
+	if err != nil {
+		return "", fmt.Errorf("ошибка сохранения API-ключа: %v", err)
+	}
+	return key, nil
+}
+
+// RevokeKey отзывает API-ключ с именем name.
+func RevokeKey(name string, db *sql.DB) error {
+	res, err := db.Exec("UPDATE api_keys SET revoked_at = now() WHERE name = $1 AND revoked_at IS NULL", name)
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва API-ключа: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("действующий API-ключ %s не найден", name)
+	}
+	return nil
+}
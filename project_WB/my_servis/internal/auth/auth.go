@@ -0,0 +1,203 @@
+// Package auth проверяет клиентов HTTP API и их права на маршруты.
+//
+// Клиент предъявляет API-ключ (из конфигурации или таблицы api_keys) или JWT, подписанный ключом
+// из локального файла JWKS. Каждый маршрут требует право (scope); право admin включает все остальные.
+// Обращения к заказам записываются в журнал аудита.
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+
+	config "main.go/internal"
+)
+
+// Права доступа к маршрутам API.
+const (
+	ScopeOrdersRead   = "orders:read"   // ScopeOrdersRead чтение заказов, статистики и потока событий.
+	ScopeOrdersExport = "orders:export" // ScopeOrdersExport выгрузка всех данных клиента.
+	ScopePIIRead      = "pii:read"      // ScopePIIRead чтение телефона и email получателя без маскирования.
+	ScopeAdmin        = "admin"         // ScopeAdmin управление вебхуками и удаление данных клиентов; включает все права.
+)
+
+var (
+	// ErrNoCredentials возвращается, если запрос не содержит учетных данных, которые проверяет Authenticator.
+	ErrNoCredentials = errors.New("учетные данные не предъявлены")
+	// ErrInvalidCredentials возвращается для недействительного ключа или токена.
+	ErrInvalidCredentials = errors.New("недействительные учетные данные")
+)
+
+// Principal клиент, от имени которого выполняется запрос.
+type Principal struct {
+	ID     string   // ID имя API-ключа или subject токена.
+	Method string   // Method способ аутентификации: api_key, jwt или none.
+	Scopes []string // Scopes права клиента.
+}
+
+// HasScope сообщает, есть ли у клиента право scope.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// anonymous клиент запросов при отключенной проверке.
+var anonymous = &Principal{ID: "anonymous", Method: "none", Scopes: []string{ScopeAdmin}}
+
+// Authenticator определяет клиента по запросу. Если запрос не содержит учетных данных
+// его вида, возвращается ErrNoCredentials, и проверка переходит к следующему Authenticator.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain проверяет запрос по очереди несколькими способами аутентификации.
+type Chain []Authenticator
+
+// Authenticate возвращает клиента, опознанного первым подходящим способом.
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// Authorizer проверяет права клиентов на маршруты.
+type Authorizer struct {
+	authenticator Authenticator
+	enabled       bool
+}
+
+// New создает Authorizer по конфигурации: статические ключи, таблица api_keys в db и JWT.
+func New(cfg config.AuthConfig, db *sql.DB) (*Authorizer, error) {
+	var chain Chain
+	if len(cfg.APIKeys) > 0 {
+		keys, err := NewStaticKeys(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if cfg.KeysTable {
+		CreateTables(db)
+		chain = append(chain, NewTableKeys(db))
+	}
+	if cfg.JWKSFile != "" {
+		verifier, err := NewJWTVerifier(cfg.JWKSFile, cfg.Issuer, cfg.Audience)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, verifier)
+	}
+	return &Authorizer{authenticator: chain, enabled: cfg.Enabled}, nil
+}
+
+// NewAuthorizer создает Authorizer с заданным способом аутентификации.
+func NewAuthorizer(a Authenticator) *Authorizer {
+	return &Authorizer{authenticator: a, enabled: true}
+}
+
+// Enabled сообщает, включена ли проверка.
+func (a *Authorizer) Enabled() bool {
+	return a.enabled
+}
+
+// Require пропускает к next только запросы клиентов с правом scope. Без учетных данных или с
+// недействительными отвечает 401, без нужного права - 403. Клиент доступен обработчику через FromContext.
+// При отключенной проверке все запросы выполняются от имени anonymous.
+func (a *Authorizer) Require(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := anonymous
+		if a.enabled {
+			var err error
+			p, err = a.authenticator.Authenticate(r)
+			if err != nil && !errors.Is(err, ErrNoCredentials) && !errors.Is(err, ErrInvalidCredentials) {
+				// Ошибка хранилища ключей не должна выглядеть для клиента как неверный ключ
+				auditLogger().Error("ошибка проверки учетных данных", slog.String("ошибка", err.Error()))
+				http.Error(w, "Authentication unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if err != nil {
+				auditLogger().Warn("доступ запрещен", slog.String("причина", err.Error()),
+					slog.String("метод", r.Method), slog.String("путь", r.URL.Path), slog.String("адрес", r.RemoteAddr))
+				w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !p.HasScope(scope) {
+				auditLogger().Warn("недостаточно прав", slog.String("клиент", p.ID), slog.String("право", scope),
+					slog.String("метод", r.Method), slog.String("путь", r.URL.Path), slog.String("адрес", r.RemoteAddr))
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// APIKeyHeader заголовок запроса с API-ключом клиента.
+const APIKeyHeader = "X-API-Key"
+
+// principalKey ключ клиента в контексте запроса.
+type principalKey struct{}
+
+// FromContext возвращает клиента запроса, прошедшего Require.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// ReadsPII сообщает, выдаются ли клиенту запроса телефон и email получателя без маскирования.
+// Для этого нужно право pii:read; при отключенной проверке данные маскируются для всех.
+func ReadsPII(ctx context.Context) bool {
+	p, ok := FromContext(ctx)
+	return ok && p != anonymous && p.HasScope(ScopePIIRead)
+}
+
+var (
+	auditLog  *slog.Logger
+	auditLock sync.RWMutex
+)
+
+// SetAuditLogger задает журнал аудита; по умолчанию записи идут в slog.Default.
+func SetAuditLogger(l *slog.Logger) {
+	auditLock.Lock()
+	defer auditLock.Unlock()
+	auditLog = l
+}
+
+// auditLogger возвращает журнал аудита.
+func auditLogger() *slog.Logger {
+	auditLock.RLock()
+	defer auditLock.RUnlock()
+	if auditLog == nil {
+		return slog.Default()
+	}
+	return auditLog
+}
+
+// Audit записывает в журнал аудита, какой клиент выполнил действие action над заказами orderUIDs.
+// В журнал попадают только идентификаторы заказов, без персональных данных.
+func Audit(r *http.Request, action string, orderUIDs ...string) {
+	audit(r.Context(), r.RemoteAddr, action, orderUIDs)
+}
+
+// audit записывает действие клиента из контекста ctx, обратившегося с адреса addr.
+func audit(ctx context.Context, addr, action string, orderUIDs []string) {
+	p, ok := FromContext(ctx)
+	if !ok {
+		p = &Principal{ID: "unknown", Method: "none"}
+	}
+	auditLogger().Info("аудит",
+		slog.String("действие", action),
+		slog.String("клиент", p.ID),
+		slog.String("аутентификация", p.Method),
+		slog.Any("заказы", orderUIDs),
+		slog.String("адрес", addr))
+}
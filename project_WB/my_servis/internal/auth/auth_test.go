@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	config "main.go/internal"
+)
+
+// signES256 подписывает токен с claims ключом key.
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// writeJWKS сохраняет открытый ключ key в файл JWKS.
+func writeJWKS(t *testing.T, key *ecdsa.PrivateKey, kid string) string {
+	t.Helper()
+	coord := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	pub := key.Public().(*ecdsa.PublicKey)
+	x, y := make([]byte, 32), make([]byte, 32)
+	pub.X.FillBytes(x)
+	pub.Y.FillBytes(y)
+	data, _ := json.Marshal(map[string]any{"keys": []jwk{{Kty: "EC", Kid: kid, Crv: "P-256", X: coord(x), Y: coord(y)}}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWTVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewJWTVerifier(writeJWKS(t, key, "k1"), "https://issuer", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	valid := map[string]any{"sub": "reports", "iss": "https://issuer", "aud": []string{"orders"}, "exp": now + 60, "scope": "orders:read"}
+
+	tests := []struct {
+		name   string
+		token  string
+		wantOK bool
+	}{
+		{"valid", signES256(t, key, "k1", valid), true},
+		{"expired", signES256(t, key, "k1", map[string]any{"sub": "reports", "iss": "https://issuer", "aud": "orders", "exp": now - 3600}), false},
+		{"no exp", signES256(t, key, "k1", map[string]any{"sub": "reports", "iss": "https://issuer", "aud": "orders"}), false},
+		{"wrong audience", signES256(t, key, "k1", map[string]any{"sub": "reports", "iss": "https://issuer", "aud": "billing", "exp": now + 60}), false},
+		{"unknown kid", signES256(t, key, "k2", valid), false},
+		{"tampered", strings.Replace(signES256(t, key, "k1", valid), ".", ".e30", 1), false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/order", nil)
+		r.Header.Set("Authorization", "Bearer "+tt.token)
+		p, err := v.Authenticate(r)
+		if tt.wantOK {
+			if err != nil || p.ID != "reports" || !p.HasScope(ScopeOrdersRead) || p.HasScope(ScopeAdmin) {
+				t.Errorf("%s: Authenticate = %+v, %v", tt.name, p, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: token accepted", tt.name)
+		}
+	}
+
+	// Ключ EC не должен принимать токен с алгоритмом RSA
+	if err := verifySignature("RS256", key.Public(), []byte("x"), make([]byte, 64)); err == nil {
+		t.Error("RS256 accepted for EC key")
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	keys, err := NewStaticKeys([]config.APIKeyConfig{
+		{Name: "reader", Key: "reader-key", Scopes: []string{ScopeOrdersRead}},
+		{Name: "ops", Key: "ops-key", Scopes: []string{ScopeAdmin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *Principal
+	handler := NewAuthorizer(Chain{keys}).Require(ScopeOrdersExport, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	}))
+
+	for key, want := range map[string]int{"": 401, "bad-key": 401, "reader-key": 403, "ops-key": 200} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/privacy/export", nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("key %q: status %d, want %d", key, w.Code, want)
+		}
+	}
+	if got == nil || got.ID != "ops" {
+		t.Errorf("principal = %+v, want ops", got)
+	}
+}
+
+func TestReadsPII(t *testing.T) {
+	keys, err := NewStaticKeys([]config.APIKeyConfig{
+		{Name: "reader", Key: "reader-key", Scopes: []string{ScopeOrdersRead}},
+		{Name: "support", Key: "support-key", Scopes: []string{ScopeOrdersRead, ScopePIIRead}},
+		{Name: "ops", Key: "ops-key", Scopes: []string{ScopeAdmin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bool
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ReadsPII(r.Context())
+	})
+	handler := NewAuthorizer(Chain{keys}).Require(ScopeOrdersRead, inner)
+
+	for key, want := range map[string]bool{"reader-key": false, "support-key": true, "ops-key": true} {
+		r := httptest.NewRequest(http.MethodGet, "/order?id=1", nil)
+		r.Header.Set(APIKeyHeader, key)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if got != want {
+			t.Errorf("key %q: ReadsPII = %v, want %v", key, got, want)
+		}
+	}
+
+	// Без проверки доступа все запросы анонимны, и данные маскируются независимо от заголовков
+	disabled := &Authorizer{}
+	r := httptest.NewRequest(http.MethodGet, "/order?id=1", nil)
+	r.Header.Set(APIKeyHeader, "ops-key")
+	disabled.Require(ScopeOrdersRead, inner).ServeHTTP(httptest.NewRecorder(), r)
+	if got {
+		t.Error("anonymous request reads PII")
+	}
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	keys, err := NewStaticKeys([]config.APIKeyConfig{
+		{Name: "reader", Key: "reader-key", Scopes: []string{ScopeOrdersRead}},
+		{Name: "support", Key: "support-key", Scopes: []string{ScopeOrdersRead, ScopePIIRead}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopes := map[string]string{
+		"/orders.v1.OrderService/GetOrder": ScopeOrdersRead,
+		"/orders.v1.OrderService/Export":   ScopeOrdersExport,
+		"/grpc.health.v1.Health/Check":     PublicMethod,
+	}
+	interceptor := NewAuthorizer(Chain{keys}).UnaryInterceptor(scopes)
+
+	var principal *Principal
+	var readsPII bool
+	handler := func(ctx context.Context, req any) (any, error) {
+		principal, _ = FromContext(ctx)
+		readsPII = ReadsPII(ctx)
+		return nil, nil
+	}
+	tests := []struct {
+		method string
+		md     metadata.MD
+		want   codes.Code
+	}{
+		{"/orders.v1.OrderService/GetOrder", nil, codes.Unauthenticated},
+		{"/orders.v1.OrderService/GetOrder", metadata.Pairs("x-api-key", "bad-key"), codes.Unauthenticated},
+		{"/orders.v1.OrderService/Export", metadata.Pairs("x-api-key", "reader-key"), codes.PermissionDenied},
+		{"/orders.v1.OrderService/Unknown", metadata.Pairs("x-api-key", "support-key"), codes.PermissionDenied},
+		{"/grpc.health.v1.Health/Check", nil, codes.OK},
+		{"/orders.v1.OrderService/GetOrder", metadata.Pairs("x-api-key", "support-key"), codes.OK},
+	}
+	for _, tt := range tests {
+		ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%s %v: code %v, want %v", tt.method, tt.md, got, tt.want)
+		}
+	}
+	if principal == nil || principal.ID != "support" || !readsPII {
+		t.Errorf("principal = %+v, ReadsPII = %v, want support with pii:read", principal, readsPII)
+	}
+}
@@ -0,0 +1,211 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	pb "github.com/Selandro/my_servis_order/project_WB/orderspb"
+	"github.com/hamba/avro/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Типы содержимого сообщений с заказами.
+const (
+	ContentTypeJSON     = pb.ContentTypeJSON
+	ContentTypeProtobuf = pb.ContentTypeProtobuf
+	ContentTypeAvro     = pb.ContentTypeAvro
+	ContentTypeMsgpack  = pb.ContentTypeMsgpack
+)
+
+// Codec преобразует заказ в формат сообщения и обратно.
+// Все форматы отображаются на одну каноническую модель ordermodel.Order текущей версии схемы.
+type Codec interface {
+	ContentType() string
+	Marshal(order model.Order) ([]byte, error)
+	Unmarshal(data []byte, order *model.Order) error
+}
+
+// codecs зарегистрированные форматы по типу содержимого.
+var codecs = map[string]Codec{}
+
+// strictJSON включает строгий режим разбора JSON: неизвестные поля считаются ошибкой.
+var strictJSON bool
+
+// SetStrictJSON включает или выключает строгий режим разбора JSON-сообщений.
+func SetStrictJSON(strict bool) {
+	strictJSON = strict
+}
+
+// aliases альтернативные названия типов содержимого.
+var aliases = map[string]string{
+	"application/protobuf":            ContentTypeProtobuf,
+	"application/vnd.google.protobuf": ContentTypeProtobuf,
+	"application/x-msgpack":           ContentTypeMsgpack,
+	"avro/binary":                     ContentTypeAvro,
+}
+
+func init() {
+	avroSchema, err := avro.Parse(pb.AvroSchema)
+	if err != nil {
+		panic(fmt.Sprintf("invalid avro schema: %v", err))
+	}
+	Register(jsonCodec{})
+	Register(protoCodec{})
+	Register(avroCodec{schema: avroSchema, api: avro.Config{TagKey: "json"}.Freeze()})
+	Register(msgpackCodec{})
+}
+
+// Register регистрирует формат сообщения.
+func Register(c Codec) {
+	codecs[c.ContentType()] = c
+}
+
+// ForContentType возвращает формат по значению заголовка Content-Type.
+// Пустой заголовок означает JSON, как у исторических сообщений без заголовков.
+func ForContentType(contentType string) (Codec, error) {
+	if contentType == "" {
+		return codecs[ContentTypeJSON], nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный Content-Type %q: %v", contentType, err)
+	}
+	if alias, ok := aliases[mediaType]; ok {
+		mediaType = alias
+	}
+	c, ok := codecs[mediaType]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемый Content-Type: %s", mediaType)
+	}
+	return c, nil
+}
+
+// Negotiate выбирает формат ответа по заголовку Accept среди offered.
+// При пустом заголовке или */* возвращается первый из предложенных форматов; false означает,
+// что ни один формат не подходит.
+func Negotiate(accept string, offered ...string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return offered[0], true
+	}
+
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if alias, ok := aliases[mediaType]; ok {
+			mediaType = alias
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{mediaType, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		for _, o := range offered {
+			if c.mediaType == o || c.mediaType == "*/*" || c.mediaType == "application/*" {
+				return o, true
+			}
+		}
+	}
+	return "", false
+}
+
+// jsonCodec формат JSON. Документы старых версий схемы приводятся к текущей версии.
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return ContentTypeJSON }
+
+func (jsonCodec) Marshal(order model.Order) ([]byte, error) { return json.Marshal(order) }
+
+func (jsonCodec) Unmarshal(data []byte, order *model.Order) (err error) {
+	*order, err = model.Decode(data, strictJSON)
+	return err
+}
+
+// protoCodec формат protobuf (сообщение orders.v1.Order).
+type protoCodec struct{}
+
+func (protoCodec) ContentType() string { return ContentTypeProtobuf }
+
+func (protoCodec) Marshal(order model.Order) ([]byte, error) {
+	return proto.Marshal(pb.FromModel(order))
+}
+
+func (protoCodec) Unmarshal(data []byte, order *model.Order) error {
+	var p pb.Order
+	if err := proto.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*order = pb.ToModel(&p)
+	return normalize(order)
+}
+
+// avroCodec формат Avro (схема orders.avsc) без контейнера, одно сообщение - один заказ.
+type avroCodec struct {
+	schema avro.Schema
+	api    avro.API
+}
+
+func (c avroCodec) ContentType() string { return ContentTypeAvro }
+
+func (c avroCodec) Marshal(order model.Order) ([]byte, error) { return c.api.Marshal(c.schema, order) }
+
+func (c avroCodec) Unmarshal(data []byte, order *model.Order) error {
+	if err := c.api.Unmarshal(c.schema, data, order); err != nil {
+		return err
+	}
+	return normalize(order)
+}
+
+// msgpackCodec формат MessagePack с именами полей как в JSON.
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return ContentTypeMsgpack }
+
+func (msgpackCodec) Marshal(order model.Order) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(order); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, order *model.Order) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(order); err != nil {
+		return err
+	}
+	return normalize(order)
+}
+
+// normalize приводит заказ, разобранный из бинарного формата, к текущей версии схемы,
+// переводит время в UTC и проверяет валюту платежа, как при разборе JSON.
+func normalize(order *model.Order) error {
+	order.SchemaVersion = model.CurrentSchemaVersion
+	order.DateCreated = order.DateCreated.UTC()
+	order.Payment.PaymentDT.Time = order.Payment.PaymentDT.UTC()
+	return order.NormalizeCurrency()
+}
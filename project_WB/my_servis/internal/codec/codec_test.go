@@ -0,0 +1,98 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+func testOrder() model.Order {
+	return model.Order{
+		SchemaVersion: model.CurrentSchemaVersion,
+		OrderUID:      "b563feb7b2b84b6test",
+		TrackNumber:   "WBILMTESTTRACK",
+		Entry:         "WBIL",
+		Delivery: model.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDT: model.NewUnixTime(1637907727), Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []model.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NMID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SMID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OOFShard:        "1",
+	}
+}
+
+func TestRoundTripAllFormats(t *testing.T) {
+	order := testOrder()
+	for _, ct := range []string{ContentTypeJSON, ContentTypeProtobuf, ContentTypeAvro, ContentTypeMsgpack} {
+		c, err := ForContentType(ct)
+		if err != nil {
+			t.Fatalf("ForContentType(%q): %v", ct, err)
+		}
+		data, err := c.Marshal(order)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", ct, err)
+		}
+		var got model.Order
+		if err := c.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: unmarshal: %v", ct, err)
+		}
+		if !reflect.DeepEqual(got, order) {
+			t.Errorf("%s: round trip mismatch:\ngot  %+v\nwant %+v", ct, got, order)
+		}
+	}
+}
+
+func TestForContentType(t *testing.T) {
+	tests := map[string]string{
+		"":                                ContentTypeJSON,
+		"application/json; charset=utf-8": ContentTypeJSON,
+		"application/protobuf":            ContentTypeProtobuf,
+		"application/x-msgpack":           ContentTypeMsgpack,
+		"avro/binary":                     ContentTypeAvro,
+	}
+	for header, want := range tests {
+		c, err := ForContentType(header)
+		if err != nil || c.ContentType() != want {
+			t.Errorf("ForContentType(%q) = %v, %v; want %s", header, c, err, want)
+		}
+	}
+	if _, err := ForContentType("text/xml"); err == nil {
+		t.Error("expected error for unsupported content type")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	offered := []string{ContentTypeJSON, ContentTypeProtobuf}
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", ContentTypeJSON, true},
+		{"*/*", ContentTypeJSON, true},
+		{"application/x-protobuf", ContentTypeProtobuf, true},
+		{"application/json;q=0.5, application/protobuf", ContentTypeProtobuf, true},
+		{"text/html", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Negotiate(tt.accept, offered...)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Negotiate(%q) = %q, %v; want %q, %v", tt.accept, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -13,6 +13,11 @@ type Config struct {
 	Database   DatabaseConfig   `yaml:"database"`    // Database содержит настройки базы данных.
 	Nats       NatsConfig       `yaml:"nats"`        // Nats содержит настройки NATS.
 	HTTPServer HTTPServerConfig `yaml:"http_server"` // HTTPServer содержит настройки HTTP-сервера.
+	GRPCServer GRPCServerConfig `yaml:"grpc_server"` // GRPCServer содержит настройки gRPC-сервера.
+	Sharding   ShardingConfig   `yaml:"sharding"`    // Sharding содержит настройки распределения заказов по шардам.
+	PII        PIIConfig        `yaml:"pii"`         // PII содержит настройки шифрования и маскирования персональных данных.
+	Auth       AuthConfig       `yaml:"auth"`        // Auth содержит настройки аутентификации клиентов HTTP API.
+	RateLimit  RateLimitConfig  `yaml:"rate_limit"`  // RateLimit содержит настройки ограничения частоты запросов и дневных квот.
 }
 
 // DatabaseConfig содержит настройки подключения к базе данных.
@@ -23,13 +28,101 @@ type DatabaseConfig struct {
 	Password string `yaml:"password"` // Password пароль пользователя базы данных.
 	DBName   string `yaml:"dbname"`   // DBName имя базы данных.
 	SSLMode  string `yaml:"sslmode"`  // SSLMode режим SSL подключения.
+
+	MaxConns         int32  `yaml:"max_conns" env-default:"10"`           // MaxConns максимальное количество соединений в пуле.
+	MinConns         int32  `yaml:"min_conns" env-default:"2"`            // MinConns количество соединений, которые пул держит открытыми.
+	MaxConnLifetime  string `yaml:"max_conn_lifetime" env-default:"1h"`   // MaxConnLifetime время жизни соединения, после которого оно пересоздается.
+	MaxConnIdleTime  string `yaml:"max_conn_idle_time" env-default:"30m"` // MaxConnIdleTime время простоя, после которого соединение закрывается.
+	StatementTimeout string `yaml:"statement_timeout" env-default:"5s"`   // StatementTimeout ограничение времени выполнения запроса на стороне Postgres.
+	ConnectAttempts  int    `yaml:"connect_attempts" env-default:"10"`    // ConnectAttempts количество попыток подключения при запуске.
+
+	Replicas             []ReplicaConfig `yaml:"replicas"`                                // Replicas реплики только для чтения; без реплик все запросы идут на основной сервер.
+	MaxReplicaLag        string          `yaml:"max_replica_lag" env-default:"10s"`       // MaxReplicaLag допустимое отставание реплики, при превышении запросы идут на основной сервер.
+	ReplicaCheckInterval string          `yaml:"replica_check_interval" env-default:"5s"` // ReplicaCheckInterval период проверки доступности и отставания реплик.
+
+	Partitions PartitionsConfig `yaml:"partitions"` // Partitions содержит настройки секционирования таблиц заказов.
+}
+
+// PartitionsConfig содержит настройки помесячного секционирования таблиц заказов и политики хранения.
+type PartitionsConfig struct {
+	Premake       int    `yaml:"premake" env-default:"3"`           // Premake на сколько месяцев вперед заранее создаются секции.
+	Retention     int    `yaml:"retention" env-default:"0"`         // Retention сколько полных месяцев хранить заказы в базе; 0 хранит бессрочно.
+	Action        string `yaml:"action" env-default:"archive"`      // Action действие с устаревшей секцией: detach, archive или drop.
+	ArchiveDir    string `yaml:"archive_dir" env-default:"archive"` // ArchiveDir каталог архивов NDJSON для действия archive.
+	CheckInterval string `yaml:"check_interval" env-default:"1h"`   // CheckInterval период создания секций и применения политики хранения.
+}
+
+// ReplicaConfig содержит адрес реплики. Пользователь, пароль, база и настройки пула берутся из DatabaseConfig.
+type ReplicaConfig struct {
+	Host string `yaml:"host"` // Host адрес хоста реплики.
+	Port int    `yaml:"port"` // Port порт реплики.
+}
+
+// ShardingConfig содержит настройки шардирования заказов. Без шардов все заказы хранятся в Database.
+type ShardingConfig struct {
+	Key    string        `yaml:"key" env-default:"shardkey"` // Key поле заказа, по которому выбирается шард: shardkey или oof_shard.
+	Shards []ShardConfig `yaml:"shards"`                     // Shards дополнительные шарды; заказы с незнакомым ключом остаются в Database.
+}
+
+// ShardConfig описывает шард: базу данных и значения ключа, заказы с которыми в ней хранятся.
+// Настройки пула соединений и секционирования берутся из DatabaseConfig.
+type ShardConfig struct {
+	Name string   `yaml:"name"` // Name имя шарда, оно же имя раздела кэша.
+	DSN  string   `yaml:"dsn"`  // DSN строка подключения к базе данных шарда.
+	Keys []string `yaml:"keys"` // Keys значения ключа шарда.
+}
+
+// PIIConfig содержит настройки защиты персональных данных получателя.
+type PIIConfig struct {
+	KeyFile string `yaml:"key_file"` // KeyFile файл ключей шифрования; без него персональные данные хранятся открыто.
+}
+
+// AuthConfig содержит настройки аутентификации и авторизации клиентов HTTP API.
+// Клиент предъявляет API-ключ в заголовке X-API-Key или JWT в заголовке Authorization: Bearer.
+type AuthConfig struct {
+	Enabled   bool           `yaml:"enabled" env-default:"true"` // Enabled включает проверку; без нее API доступен всем.
+	APIKeys   []APIKeyConfig `yaml:"api_keys"`                   // APIKeys статические API-ключи.
+	KeysTable bool           `yaml:"keys_table"`                 // KeysTable искать API-ключи также в таблице api_keys.
+	JWKSFile  string         `yaml:"jwks_file"`                  // JWKSFile файл JWKS с открытыми ключами для проверки JWT; пустое значение отключает JWT.
+	Issuer    string         `yaml:"issuer"`                     // Issuer ожидаемое значение iss в JWT; пустое значение не проверяется.
+	Audience  string         `yaml:"audience"`                   // Audience ожидаемое значение aud в JWT; пустое значение не проверяется.
+}
+
+// APIKeyConfig описывает статический API-ключ.
+type APIKeyConfig struct {
+	Name   string   `yaml:"name"`   // Name имя клиента, под которым его запросы попадают в журнал аудита.
+	Key    string   `yaml:"key"`    // Key значение ключа.
+	Scopes []string `yaml:"scopes"` // Scopes права клиента: orders:read, orders:export, pii:read, admin.
+}
+
+// RateLimitConfig содержит настройки ограничения частоты запросов к HTTP API.
+// Запросы считаются отдельно для каждого клиента: по имени API-ключа или subject JWT,
+// а при отключенной аутентификации - по IP-адресу.
+type RateLimitConfig struct {
+	Enabled       bool                        `yaml:"enabled" env-default:"true"`       // Enabled включает ограничение частоты и квоты.
+	Rate          float64                     `yaml:"rate" env-default:"20"`            // Rate допустимое среднее количество запросов в секунду к маршруту.
+	Burst         int                         `yaml:"burst" env-default:"40"`           // Burst количество запросов, которое можно выполнить подряд без ожидания.
+	Routes        map[string]RouteLimitConfig `yaml:"routes"`                           // Routes ограничения отдельных маршрутов вместо Rate и Burst.
+	DailyQuota    int64                       `yaml:"daily_quota" env-default:"0"`      // DailyQuota дневная квота запросов клиента по всем маршрутам; 0 отключает квоту.
+	Quotas        map[string]int64            `yaml:"quotas"`                           // Quotas дневные квоты отдельных клиентов вместо DailyQuota.
+	FlushInterval string                      `yaml:"flush_interval" env-default:"10s"` // FlushInterval период сохранения счетчиков использования в базу данных.
+}
+
+// RouteLimitConfig содержит ограничение частоты запросов к маршруту. Rate 0 снимает ограничение.
+type RouteLimitConfig struct {
+	Rate  float64 `yaml:"rate"`  // Rate допустимое среднее количество запросов в секунду.
+	Burst int     `yaml:"burst"` // Burst количество запросов, которое можно выполнить подряд без ожидания.
 }
 
 // NatsConfig содержит настройки подключения к NATS.
 type NatsConfig struct {
-	ClusterID string `yaml:"cluster_id"` // ClusterID идентификатор кластера NATS.
-	ClientID  string `yaml:"client_id"`  // ClientID идентификатор клиента NATS.
-	URL       string `yaml:"url"`        // URL адрес сервера NATS.
+	ClusterID     string `yaml:"cluster_id"`                                   // ClusterID идентификатор кластера NATS.
+	ClientID      string `yaml:"client_id"`                                    // ClientID идентификатор клиента NATS.
+	URL           string `yaml:"url"`                                          // URL адрес сервера NATS.
+	OutboxSubject string `yaml:"outbox_subject" env-default:"orders.accepted"` // OutboxSubject subject JetStream для событий order.accepted.
+	StrictDecode  bool   `yaml:"strict_decode" env-default:"false"`            // StrictDecode отклонять сообщения с неизвестными полями.
+	BatchSize     int    `yaml:"batch_size" env-default:"100"`                 // BatchSize максимальный размер пакета записи заказов; 1 отключает пакетную запись.
+	BatchTimeout  string `yaml:"batch_timeout" env-default:"50ms"`             // BatchTimeout максимальное время накопления пакета.
 }
 
 // HTTPServerConfig содержит настройки HTTP-сервера.
@@ -37,6 +130,15 @@ type HTTPServerConfig struct {
 	Address     string `yaml:"address"`      // Address адрес, на котором запущен HTTP-сервер.
 	Timeout     string `yaml:"timeout"`      // Timeout таймаут запроса к серверу.
 	IdleTimeout string `yaml:"idle_timeout"` // IdleTimeout таймаут ожидания.
+
+	CacheControl    map[string]string `yaml:"cache_control"`                        // CacheControl значение заголовка Cache-Control по имени маршрута; без значения заголовок не задается.
+	CompressMinSize int               `yaml:"compress_min_size" env-default:"1024"` // CompressMinSize минимальный размер ответа для сжатия gzip или zstd; -1 отключает сжатие.
+}
+
+// GRPCServerConfig содержит настройки gRPC-сервера. Клиенты вызовов проверяются по настройкам Auth:
+// API-ключ передается в метаданных x-api-key, JWT - в метаданных authorization.
+type GRPCServerConfig struct {
+	Address string `yaml:"address"` // Address адрес gRPC-сервера; пустое значение отключает сервер.
 }
 
 // MustLoad загружает конфигурацию из указанного файла и завершает программу с ошибкой в случае неудачи.
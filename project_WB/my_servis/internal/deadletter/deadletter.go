@@ -0,0 +1,236 @@
+// Package deadletter хранит сообщения NATS, которые не удалось разобрать, и публикует их повторно.
+//
+// Такие сообщения подтверждаются, чтобы JetStream не доставлял их бесконечно, и откладываются
+// в таблицу dead_letters основной базы данных. После исправления отправителя или настроек
+// разбора администратор публикует их заново командой replay или удаляет. Сообщения клиента
+// попадают в выгрузку его данных и удаляются вместе с ними (см. ExportSubject и EraseSubject).
+package deadletter
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"main.go/internal/pii"
+)
+
+// ErrNotFound возвращается, если сообщения нет в очереди.
+var ErrNotFound = errors.New("сообщение не найдено")
+
+// Letter отложенное сообщение. Тело сообщения может содержать персональные данные,
+// поэтому хранится зашифрованным и выдается только в выгрузке данных клиента (Message).
+type Letter struct {
+	ID          int64     `json:"id"`
+	Subject     string    `json:"subject"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	Error       string    `json:"error"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Message отложенное сообщение клиента вместе с телом в base64 для выгрузки его данных.
+type Message struct {
+	ID          int64     `json:"id"`
+	Subject     string    `json:"subject"`
+	ContentType string    `json:"content_type"`
+	Error       string    `json:"error"`
+	CreatedAt   time.Time `json:"created_at"`
+	Data        string    `json:"data"`
+}
+
+// CreateTables создает таблицу отложенных сообщений.
+func CreateTables(db *sql.DB) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS dead_letters (
+		id BIGSERIAL PRIMARY KEY,
+		subject VARCHAR(255) NOT NULL,
+		content_type VARCHAR(255) NOT NULL DEFAULT '',
+		data BYTEA NOT NULL,
+		size INT NOT NULL,
+		error TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		log.Fatalf("Error creating dead letter table: %v", err)
+	}
+}
+
+// Add откладывает сообщение с причиной, по которой его не удалось обработать.
+func Add(ctx context.Context, msg *nats.Msg, reason error, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO dead_letters (subject, content_type, data, size, error)
+		VALUES ($1, $2, $3, $4, $5)`,
+		msg.Subject, msg.Header.Get("Content-Type"), pii.EncryptMessage(msg.Data), len(msg.Data), reason.Error())
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения отложенного сообщения: %v", err)
+	}
+	return nil
+}
+
+// List возвращает не больше limit отложенных сообщений, начиная с самых старых.
+func List(ctx context.Context, limit int, db *sql.DB) ([]Letter, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, subject, content_type, size, error, created_at
+		FROM dead_letters
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения отложенных сообщений: %v", err)
+	}
+	defer rows.Close()
+
+	letters := []Letter{}
+	for rows.Next() {
+		var l Letter
+		if err := rows.Scan(&l.ID, &l.Subject, &l.ContentType, &l.Size, &l.Error, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения отложенного сообщения: %v", err)
+		}
+		letters = append(letters, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения отложенных сообщений: %v", err)
+	}
+	return letters, nil
+}
+
+// Count возвращает количество отложенных сообщений.
+func Count(ctx context.Context, db *sql.DB) (int, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM dead_letters`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("ошибка подсчета отложенных сообщений: %v", err)
+	}
+	return n, nil
+}
+
+// Replay публикует сообщение заново в его канал с исходным Content-Type и удаляет его из очереди.
+// Если сообщение снова не удастся разобрать, оно вернется в очередь под новым номером.
+func Replay(ctx context.Context, id int64, js nats.JetStreamContext, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	msg := &nats.Msg{Header: nats.Header{}}
+	var contentType string
+	err = tx.QueryRowContext(ctx, `
+		SELECT subject, content_type, data FROM dead_letters WHERE id = $1 FOR UPDATE`, id).
+		Scan(&msg.Subject, &contentType, &msg.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка чтения отложенного сообщения: %v", err)
+	}
+	if msg.Data, err = pii.DecryptMessage(msg.Data); err != nil {
+		return fmt.Errorf("ошибка расшифровки отложенного сообщения %d: %v", id, err)
+	}
+	if contentType != "" {
+		msg.Header.Set("Content-Type", contentType)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM dead_letters WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("ошибка удаления отложенного сообщения: %v", err)
+	}
+	if _, err := js.PublishMsg(msg, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("ошибка публикации отложенного сообщения %d: %v", id, err)
+	}
+	return tx.Commit()
+}
+
+// Delete удаляет сообщение из очереди без повторной публикации.
+func Delete(ctx context.Context, id int64, db *sql.DB) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM dead_letters WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления отложенного сообщения: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ExportSubject возвращает отложенные сообщения клиента с идентификатором customerID
+// или получателя с адресом email.
+func ExportSubject(ctx context.Context, customerID, email string, db *sql.DB) ([]Message, error) {
+	return subjectMessages(ctx, customerID, email, db)
+}
+
+// EraseSubject удаляет отложенные сообщения клиента с идентификатором customerID или получателя
+// с адресом email и возвращает количество удаленных сообщений.
+func EraseSubject(ctx context.Context, customerID, email string, db *sql.DB) (int, error) {
+	messages, err := subjectMessages(ctx, customerID, email, db)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+	ids := make([]int64, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+	}
+	res, err := db.ExecContext(ctx, `DELETE FROM dead_letters WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления отложенных сообщений клиента: %v", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// subjectMessages просматривает все отложенные сообщения и возвращает сообщения субъекта.
+// Тела хранятся зашифрованными, поэтому сравниваются после расшифровки.
+func subjectMessages(ctx context.Context, customerID, email string, db *sql.DB) ([]Message, error) {
+	email = strings.TrimSpace(email)
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, subject, content_type, data, error, created_at
+		FROM dead_letters
+		ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения отложенных сообщений: %v", err)
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var m Message
+		var data []byte
+		if err := rows.Scan(&m.ID, &m.Subject, &m.ContentType, &data, &m.Error, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения отложенного сообщения: %v", err)
+		}
+		if data, err = pii.DecryptMessage(data); err != nil {
+			return nil, fmt.Errorf("ошибка расшифровки отложенного сообщения %d: %v", m.ID, err)
+		}
+		if matchSubject(data, customerID, email) {
+			m.Data = base64.StdEncoding.EncodeToString(data)
+			messages = append(messages, m)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения отложенных сообщений: %v", err)
+	}
+	return messages, nil
+}
+
+// matchSubject сообщает, относится ли тело сообщения к субъекту. Тело, которое разбирается как JSON
+// заказа, сравнивается по полям customer_id и delivery.email. Остальные тела (битый JSON, protobuf)
+// проверяются по вхождению значения: удаление скорее захватит лишнее сообщение, чем пропустит нужное.
+func matchSubject(data []byte, customerID, email string) bool {
+	var order struct {
+		CustomerID string `json:"customer_id"`
+		Delivery   struct {
+			Email string `json:"email"`
+		} `json:"delivery"`
+	}
+	if err := json.Unmarshal(data, &order); err == nil {
+		return (customerID != "" && order.CustomerID == customerID) ||
+			(email != "" && strings.EqualFold(strings.TrimSpace(order.Delivery.Email), email))
+	}
+	return (customerID != "" && bytes.Contains(data, []byte(customerID))) ||
+		(email != "" && bytes.Contains(bytes.ToLower(data), []byte(strings.ToLower(email))))
+}
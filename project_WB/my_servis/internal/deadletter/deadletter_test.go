@@ -0,0 +1,26 @@
+package deadletter
+
+import "testing"
+
+func TestMatchSubject(t *testing.T) {
+	order := []byte(`{"order_uid":"b563feb7b2b84b6test","customer_id":"test","delivery":{"email":"Test@Gmail.com"}}`)
+	tests := []struct {
+		name              string
+		data              []byte
+		customerID, email string
+		want              bool
+	}{
+		{"json customer", order, "test", "", true},
+		{"json email ignores case", order, "", "test@gmail.com", true},
+		{"json other customer", order, "tes", "", false},
+		{"json other email", order, "", "other@gmail.com", false},
+		{"broken json", []byte(`{"customer_id":"test","delivery":{"email":"test@gmail.com"`), "", "TEST@gmail.com", true},
+		{"binary", []byte("\x0a\x04test\x12\x0etest@gmail.com"), "test", "", true},
+		{"binary other", []byte("\x0a\x05other"), "test", "test@gmail.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchSubject(tt.data, tt.customerID, tt.email); got != tt.want {
+			t.Errorf("%s: matchSubject = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+const (
+	// TypeOrderStored тип события о сохранении нового заказа.
+	TypeOrderStored = "order.stored"
+
+	historySize      = 1024 // historySize количество последних событий, доступных для возобновления потока.
+	subscriberBuffer = 64   // subscriberBuffer размер очереди событий одного подписчика.
+)
+
+// Event представляет событие шины заказов.
+type Event struct {
+	ID    uint64      `json:"id"`
+	Type  string      `json:"type"`
+	Time  time.Time   `json:"time"`
+	Order model.Order `json:"order"`
+}
+
+// Filter задает серверный фильтр событий. Пустые поля не ограничивают выборку.
+type Filter struct {
+	CustomerID      string
+	DeliveryService string
+}
+
+// Match проверяет, подходит ли событие под фильтр.
+func (f Filter) Match(e Event) bool {
+	if f.CustomerID != "" && e.Order.CustomerID != f.CustomerID {
+		return false
+	}
+	if f.DeliveryService != "" && e.Order.DeliveryService != f.DeliveryService {
+		return false
+	}
+	return true
+}
+
+// Subscription представляет подписку на события шины.
+// Канал C закрывается при отписке или если подписчик не успевает читать события.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+	lagged bool
+	bus    *Bus
+}
+
+// Lagged сообщает, была ли подписка закрыта из-за переполнения очереди.
+// Клиент может переподключиться, передав идентификатор последнего полученного события.
+func (s *Subscription) Lagged() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.lagged
+}
+
+// Close отменяет подписку.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+// Bus внутрипроцессная шина событий с историей для возобновления потока.
+type Bus struct {
+	mu      sync.Mutex
+	lastID  uint64
+	history []Event
+	subs    map[*Subscription]struct{}
+}
+
+// NewBus создает новую шину событий.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish публикует событие всем подходящим подписчикам.
+// Подписчики с переполненной очередью отключаются, чтобы медленный клиент не задерживал остальных.
+func (b *Bus) Publish(eventType string, order model.Order) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e := Event{ID: b.lastID, Type: eventType, Time: time.Now().UTC(), Order: order}
+
+	b.history = append(b.history, e)
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			s.lagged = true
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+	return e
+}
+
+// Forget удаляет из истории события о заказах orderUIDs, чтобы их данные не попали
+// к клиентам, возобновляющим поток.
+func (b *Bus) Forget(orderUIDs []string) {
+	forget := make(map[string]bool, len(orderUIDs))
+	for _, uid := range orderUIDs {
+		forget[uid] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	history := b.history[:0]
+	for _, e := range b.history {
+		if !forget[e.Order.OrderUID] {
+			history = append(history, e)
+		}
+	}
+	clear(b.history[len(history):])
+	b.history = history
+}
+
+// Subscribe создает подписку с фильтром. Если lastEventID больше нуля,
+// в очередь сначала попадают сохраненные в истории события с большим идентификатором.
+func (b *Bus) Subscribe(filter Filter, lastEventID uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if lastEventID > 0 {
+		for _, e := range b.history {
+			if e.ID > lastEventID && filter.Match(e) {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	size := subscriberBuffer
+	if len(missed) > size {
+		size = len(missed) + subscriberBuffer
+	}
+	ch := make(chan Event, size)
+	for _, e := range missed {
+		ch <- e
+	}
+
+	s := &Subscription{C: ch, ch: ch, filter: filter, bus: b}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// defaultBus шина событий сервиса.
+var defaultBus = NewBus()
+
+// PublishOrderStored публикует событие о сохранении заказа в шину сервиса.
+func PublishOrderStored(order model.Order) {
+	defaultBus.Publish(TypeOrderStored, order)
+}
+
+// ForgetOrders удаляет события о заказах из истории шины сервиса.
+func ForgetOrders(orderUIDs []string) {
+	defaultBus.Forget(orderUIDs)
+}
+
+// Subscribe подписывается на шину событий сервиса.
+func Subscribe(filter Filter, lastEventID uint64) *Subscription {
+	return defaultBus.Subscribe(filter, lastEventID)
+}
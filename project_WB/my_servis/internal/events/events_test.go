@@ -0,0 +1,75 @@
+package events
+
+import (
+	"testing"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+func TestBusFilterAndResume(t *testing.T) {
+	bus := NewBus()
+
+	bus.Publish(TypeOrderStored, model.Order{OrderUID: "order_1", CustomerID: "c1"})
+	bus.Publish(TypeOrderStored, model.Order{OrderUID: "order_2", CustomerID: "c2"})
+	bus.Publish(TypeOrderStored, model.Order{OrderUID: "order_3", CustomerID: "c1"})
+
+	// Возобновление после первого события с фильтром по клиенту
+	sub := bus.Subscribe(Filter{CustomerID: "c1"}, 1)
+	defer sub.Close()
+
+	bus.Publish(TypeOrderStored, model.Order{OrderUID: "order_4", CustomerID: "c2"})
+	bus.Publish(TypeOrderStored, model.Order{OrderUID: "order_5", CustomerID: "c1"})
+
+	for _, want := range []string{"order_3", "order_5"} {
+		e := <-sub.C
+		if e.Order.OrderUID != want {
+			t.Errorf("got event for %s, want %s", e.Order.OrderUID, want)
+		}
+	}
+	select {
+	case e := <-sub.C:
+		t.Errorf("unexpected event for %s", e.Order.OrderUID)
+	default:
+	}
+}
+
+func TestBusDropsSlowSubscriber(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(Filter{}, 0)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		bus.Publish(TypeOrderStored, model.Order{})
+	}
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("got %d buffered events, want %d", n, subscriberBuffer)
+	}
+	if !sub.Lagged() {
+		t.Error("slow subscriber was not marked as lagged")
+	}
+	sub.Close() // повторное закрытие не должно паниковать
+}
+
+func TestBusForget(t *testing.T) {
+	bus := NewBus()
+	bus.Publish(TypeOrderStored, model.Order{OrderUID: "order_1"})
+	bus.Publish(TypeOrderStored, model.Order{OrderUID: "order_2"})
+	bus.Publish(TypeOrderStored, model.Order{OrderUID: "order_3"})
+
+	bus.Forget([]string{"order_2"})
+
+	resumed := bus.Subscribe(Filter{}, 1)
+	defer resumed.Close()
+	if e := <-resumed.C; e.Order.OrderUID != "order_3" {
+		t.Errorf("got event for %s after forget, want order_3", e.Order.OrderUID)
+	}
+	select {
+	case e := <-resumed.C:
+		t.Errorf("unexpected event for %s", e.Order.OrderUID)
+	default:
+	}
+}
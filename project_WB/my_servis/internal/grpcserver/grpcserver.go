@@ -0,0 +1,240 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	pb "github.com/Selandro/my_servis_order/project_WB/orderspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionalphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"main.go/internal/auth"
+	"main.go/internal/events"
+	"main.go/internal/pii"
+	cache "main.go/internal/storage/cache"
+)
+
+const (
+	defaultPageSize = 20   // defaultPageSize размер страницы ListOrders по умолчанию.
+	maxPageSize     = 1000 // maxPageSize максимальный размер страницы ListOrders.
+	maxBatchSize    = 1000 // maxBatchSize максимальное количество идентификаторов в BatchGetOrders.
+)
+
+// methodScopes права, которые требуются для вызова методов; они совпадают с правами
+// соответствующих маршрутов HTTP API. Проверка здоровья доступна без учетных данных.
+var methodScopes = map[string]string{
+	pb.OrderService_GetOrder_FullMethodName:                                auth.ScopeOrdersRead,
+	pb.OrderService_BatchGetOrders_FullMethodName:                          auth.ScopeOrdersRead,
+	pb.OrderService_ListOrders_FullMethodName:                              auth.ScopeOrdersRead,
+	pb.OrderService_WatchOrders_FullMethodName:                             auth.ScopeOrdersRead,
+	reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName:      auth.ScopeOrdersRead,
+	reflectionalphapb.ServerReflection_ServerReflectionInfo_FullMethodName: auth.ScopeOrdersRead,
+	healthpb.Health_Check_FullMethodName:                                   auth.PublicMethod,
+	healthpb.Health_Watch_FullMethodName:                                   auth.PublicMethod,
+}
+
+// Store хранилище заказов, к которому сервис обращается при промахе кэша.
+// Реализуется *database.Shards.
+type Store interface {
+	GetOrder(ctx context.Context, orderUID string) (model.Order, error)
+	GetOrders(ctx context.Context, orderUIDs []string) (map[string]model.Order, error)
+}
+
+// server реализует gRPC-сервис заказов поверх кэша и хранилища заказов.
+type server struct {
+	pb.UnimplementedOrderServiceServer
+	store Store
+}
+
+// New создает gRPC-сервер с сервисом заказов, сервисом здоровья и reflection.
+// Клиенты вызовов проверяются authz по API-ключу или JWT из метаданных x-api-key и authorization.
+func New(store Store, authz *auth.Authorizer) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authz.UnaryInterceptor(methodScopes)),
+		grpc.ChainStreamInterceptor(authz.StreamInterceptor(methodScopes)),
+	)
+	pb.RegisterOrderServiceServer(s, &server{store: store})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(pb.OrderService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	reflection.Register(s)
+	return s
+}
+
+// GetOrder возвращает заказ по идентификатору: сначала из кэша, затем из базы данных.
+// Телефон и email получателя видны полностью только клиентам с правом pii:read.
+func (s *server) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+	order, exists := cache.GetOrderFromCache(req.GetOrderUid())
+	if !exists {
+		var err error
+		order, err = s.store.GetOrder(ctx, req.GetOrderUid())
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "order not found")
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, "error fetching order")
+		}
+	}
+	auth.AuditCall(ctx, "order.read", order.OrderUID)
+	return pb.FromModel(pii.ForReader(auth.ReadsPII(ctx), order)), nil
+}
+
+// BatchGetOrders возвращает найденные заказы и список отсутствующих идентификаторов.
+func (s *server) BatchGetOrders(ctx context.Context, req *pb.BatchGetOrdersRequest) (*pb.BatchGetOrdersResponse, error) {
+	if len(req.GetOrderUids()) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "too many order_uids: max %d", maxBatchSize)
+	}
+	orders, missing, err := s.lookup(ctx, req.GetOrderUids())
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		uids[i] = order.GetOrderUid()
+	}
+	auth.AuditCall(ctx, "orders.batch_get", uids...)
+	return &pb.BatchGetOrdersResponse{Orders: orders, Missing: missing}, nil
+}
+
+// ListOrders возвращает страницу заказов из кэша, от новых к старым.
+func (s *server) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	size := int(req.GetPageSize())
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	orders, total := cache.ListOrders(offset, size)
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		uids[i] = order.OrderUID
+	}
+	auth.AuditCall(ctx, "orders.list", uids...)
+	resp := &pb.ListOrdersResponse{TotalSize: int32(total)}
+	for _, order := range pii.ForReaderAll(auth.ReadsPII(ctx), orders) {
+		resp.Orders = append(resp.Orders, pb.FromModel(order))
+	}
+	if next := offset + len(orders); next < total {
+		resp.NextPageToken = encodePageToken(next)
+	}
+	return resp, nil
+}
+
+// WatchOrders передает клиенту события о сохраненных заказах до отмены вызова.
+func (s *server) WatchOrders(req *pb.WatchOrdersRequest, stream pb.OrderService_WatchOrdersServer) error {
+	sub := events.Subscribe(events.Filter{
+		CustomerID:      req.GetCustomerId(),
+		DeliveryService: req.GetDeliveryService(),
+	}, req.GetLastEventId())
+	defer sub.Close()
+
+	auth.AuditCall(stream.Context(), "orders.stream")
+	full := auth.ReadsPII(stream.Context())
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					return status.Error(codes.ResourceExhausted, "client is too slow, resume with last_event_id")
+				}
+				return nil
+			}
+			err := stream.Send(&pb.OrderEvent{
+				Id:           e.ID,
+				Type:         e.Type,
+				TimeUnixNano: e.Time.UnixNano(),
+				Order:        pb.FromModel(pii.ForReader(full, e.Order)),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// lookup ищет заказы в кэше, а отсутствующие в нем - одним запросом в базе данных.
+// Порядок найденных заказов соответствует порядку идентификаторов в запросе;
+// персональные данные маскируются, как в GetOrder.
+func (s *server) lookup(ctx context.Context, orderUIDs []string) ([]*pb.Order, []string, error) {
+	found := make(map[string]model.Order, len(orderUIDs))
+	var misses []string
+	for _, uid := range orderUIDs {
+		if order, exists := cache.GetOrderFromCache(uid); exists {
+			found[uid] = order
+		} else {
+			misses = append(misses, uid)
+		}
+	}
+
+	if len(misses) > 0 {
+		fromDB, err := s.store.GetOrders(ctx, misses)
+		if err != nil {
+			return nil, nil, status.Error(codes.Internal, "error fetching orders")
+		}
+		for uid, order := range fromDB {
+			found[uid] = order
+		}
+	}
+
+	var orders []*pb.Order
+	var missing []string
+	full := auth.ReadsPII(ctx)
+	seen := make(map[string]bool, len(orderUIDs))
+	for _, uid := range orderUIDs {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		if order, ok := found[uid]; ok {
+			orders = append(orders, pb.FromModel(pii.ForReader(full, order)))
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+	return orders, missing, nil
+}
+
+// encodePageToken кодирует смещение следующей страницы в непрозрачный токен.
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodePageToken восстанавливает смещение из токена страницы.
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid offset in page token")
+	}
+	return offset, nil
+}
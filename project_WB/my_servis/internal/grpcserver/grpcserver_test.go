@@ -0,0 +1,224 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	pb "github.com/Selandro/my_servis_order/project_WB/orderspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	config "main.go/internal"
+	"main.go/internal/auth"
+	"main.go/internal/events"
+	cache "main.go/internal/storage/cache"
+)
+
+func TestPageToken(t *testing.T) {
+	for _, offset := range []int{0, 20, 12345} {
+		got, err := decodePageToken(encodePageToken(offset))
+		if err != nil || got != offset {
+			t.Errorf("decodePageToken(encodePageToken(%d)) = %d, %v", offset, got, err)
+		}
+	}
+	if _, err := decodePageToken("not-a-token"); err == nil {
+		t.Error("expected error for malformed token")
+	}
+}
+
+func TestGetOrderMasksPII(t *testing.T) {
+	cache.InitCache()
+	cache.CacheOrder(model.Order{OrderUID: "order_1", Delivery: model.Delivery{Phone: "+9720000000", Email: "test@gmail.com"}})
+
+	// Без клиента с правом pii:read в контексте телефон и email маскируются
+	got, err := (&server{}).GetOrder(context.Background(), &pb.GetOrderRequest{OrderUid: "order_1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetDelivery().GetPhone() != "+9*******00" || got.GetDelivery().GetEmail() != "t***@gmail.com" {
+		t.Errorf("delivery = %v, want masked phone and email", got.GetDelivery())
+	}
+}
+
+// fakeStore хранилище заказов в памяти для тестов сервиса.
+type fakeStore map[string]model.Order
+
+func (f fakeStore) GetOrder(ctx context.Context, orderUID string) (model.Order, error) {
+	order, ok := f[orderUID]
+	if !ok {
+		return model.Order{}, sql.ErrNoRows
+	}
+	return order, nil
+}
+
+func (f fakeStore) GetOrders(ctx context.Context, orderUIDs []string) (map[string]model.Order, error) {
+	found := make(map[string]model.Order)
+	for _, uid := range orderUIDs {
+		if order, ok := f[uid]; ok {
+			found[uid] = order
+		}
+	}
+	return found, nil
+}
+
+// startServer запускает сервис поверх bufconn и возвращает клиента и контекст
+// с API-ключом, у которого есть права orders:read и pii:read.
+func startServer(t *testing.T, store Store) (pb.OrderServiceClient, context.Context) {
+	t.Helper()
+	keys, err := auth.NewStaticKeys([]config.APIKeyConfig{
+		{Name: "support", Key: "support-key", Scopes: []string{auth.ScopeOrdersRead, auth.ScopePIIRead}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := New(store, auth.NewAuthorizer(auth.Chain{keys}))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return pb.NewOrderServiceClient(conn), metadata.AppendToOutgoingContext(ctx, "x-api-key", "support-key")
+}
+
+func TestGetOrder(t *testing.T) {
+	cache.InitCache()
+	cache.CacheOrder(model.Order{OrderUID: "cached", TrackNumber: "WBILMTESTTRACK"})
+	client, ctx := startServer(t, fakeStore{"stored": {OrderUID: "stored", TrackNumber: "WBSTORED"}})
+
+	got, err := client.GetOrder(ctx, &pb.GetOrderRequest{OrderUid: "cached"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetTrackNumber() != "WBILMTESTTRACK" {
+		t.Errorf("track_number = %q, want order from cache", got.GetTrackNumber())
+	}
+
+	got, err = client.GetOrder(ctx, &pb.GetOrderRequest{OrderUid: "stored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetTrackNumber() != "WBSTORED" {
+		t.Errorf("track_number = %q, want order from store", got.GetTrackNumber())
+	}
+
+	_, err = client.GetOrder(ctx, &pb.GetOrderRequest{OrderUid: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetOrder(missing) error = %v, want NotFound", err)
+	}
+}
+
+func TestBatchGetOrders(t *testing.T) {
+	cache.InitCache()
+	cache.CacheOrder(model.Order{OrderUID: "cached"})
+	client, ctx := startServer(t, fakeStore{"stored": {OrderUID: "stored"}})
+
+	resp, err := client.BatchGetOrders(ctx, &pb.BatchGetOrdersRequest{
+		OrderUids: []string{"stored", "missing_1", "cached", "missing_2", "cached"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uids []string
+	for _, order := range resp.GetOrders() {
+		uids = append(uids, order.GetOrderUid())
+	}
+	if !reflect.DeepEqual(uids, []string{"stored", "cached"}) {
+		t.Errorf("orders = %v, want [stored cached]", uids)
+	}
+	if !reflect.DeepEqual(resp.GetMissing(), []string{"missing_1", "missing_2"}) {
+		t.Errorf("missing = %v, want [missing_1 missing_2]", resp.GetMissing())
+	}
+}
+
+func TestListOrdersPaging(t *testing.T) {
+	cache.InitCache()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var want []string
+	for i := 5; i > 0; i-- {
+		uid := fmt.Sprintf("order_%d", i)
+		cache.CacheOrder(model.Order{OrderUID: uid, DateCreated: created.Add(time.Duration(i) * time.Hour)})
+		want = append(want, uid)
+	}
+	client, ctx := startServer(t, fakeStore{})
+
+	var got []string
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("paging does not stop")
+		}
+		resp, err := client.ListOrders(ctx, &pb.ListOrdersRequest{PageSize: 2, PageToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetTotalSize() != int32(len(want)) {
+			t.Errorf("total_size = %d, want %d", resp.GetTotalSize(), len(want))
+		}
+		for _, order := range resp.GetOrders() {
+			got = append(got, order.GetOrderUid())
+		}
+		token = resp.GetNextPageToken()
+		if token == "" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orders = %v, want %v", got, want)
+	}
+}
+
+func TestWatchOrders(t *testing.T) {
+	client, ctx := startServer(t, fakeStore{})
+
+	stream, err := client.WatchOrders(ctx, &pb.WatchOrdersRequest{CustomerId: "watcher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Подписка создается асинхронно, поэтому событие публикуется повторно, пока клиент его не получит
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			events.PublishOrderStored(model.Order{OrderUID: "other", CustomerID: "someone_else"})
+			events.PublishOrderStored(model.Order{OrderUID: "watched", CustomerID: "watcher"})
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	e, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.GetType() != events.TypeOrderStored || e.GetOrder().GetOrderUid() != "watched" {
+		t.Errorf("event = %v, want %s for order watched", e, events.TypeOrderStored)
+	}
+}
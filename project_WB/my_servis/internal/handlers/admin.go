@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"main.go/internal/deadletter"
+	"main.go/internal/natsstream"
+	"main.go/internal/outbox"
+	cache "main.go/internal/storage/cache"
+	database "main.go/internal/storage/database"
+)
+
+const defaultDeadLettersLimit = 50 // defaultDeadLettersLimit количество отложенных сообщений в ответе по умолчанию.
+
+// startedAt время запуска сервиса.
+var startedAt = time.Now()
+
+// Status содержит состояние сервиса для административной консоли.
+type Status struct {
+	StartedAt   time.Time     `json:"started_at"`
+	Ingested    int64         `json:"ingested"`
+	Cached      int           `json:"cached"`
+	DeadLetters int           `json:"dead_letters"`
+	Shards      []ShardStatus `json:"shards"`
+}
+
+// ShardStatus содержит состояние шарда: доступность его базы данных и количество заказов в кэше.
+type ShardStatus struct {
+	Name    string `json:"name"`
+	Cached  int    `json:"cached"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// EvictRequest тело запроса на вытеснение заказов из кэша.
+type EvictRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// EvictResult содержит заказы, вытесненные из кэша, и заказы, загруженные после этого из базы данных заново.
+type EvictResult struct {
+	Evicted  []string `json:"evicted"`
+	Reloaded []string `json:"reloaded"`
+}
+
+// GetStatus возвращает состояние сервиса: время запуска, счетчики заказов,
+// размер очереди отложенных сообщений и доступность баз данных шардов.
+func GetStatus(shards *database.Shards, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deadLetters, err := deadletter.Count(r.Context(), db)
+		if err != nil {
+			http.Error(w, "Error counting dead letters", http.StatusInternalServerError)
+			return
+		}
+		status := Status{
+			StartedAt:   startedAt,
+			Ingested:    natsstream.IngestedCount(),
+			Cached:      cache.CountOrders(),
+			DeadLetters: deadLetters,
+			Shards:      []ShardStatus{},
+		}
+		counts := cache.PartitionCounts()
+		for _, name := range shards.Names() {
+			shard := ShardStatus{Name: name, Cached: counts[name], Healthy: true}
+			if err := shards.DB(name).PingContext(r.Context()); err != nil {
+				shard.Healthy, shard.Error = false, err.Error()
+			}
+			status.Shards = append(status.Shards, shard)
+		}
+		writeJSON(w, status)
+	}
+}
+
+// GetOutboxMetrics возвращает размер и возраст исходящих очередей событий order.accepted всех шардов,
+// количество отложенных событий и счетчики публикации с момента запуска сервиса.
+func GetOutboxMetrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, outbox.Stats())
+}
+
+// ListDeadLetters возвращает отложенные сообщения NATS, начиная с самых старых.
+// Параметр limit ограничивает количество сообщений в ответе.
+func ListDeadLetters(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultDeadLettersLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		letters, err := deadletter.List(r.Context(), limit, db)
+		if err != nil {
+			http.Error(w, "Error fetching dead letters", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, letters)
+	}
+}
+
+// ReplayDeadLetter публикует отложенное сообщение заново в JetStream и удаляет его из очереди.
+func ReplayDeadLetter(js nats.JetStreamContext, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid id", http.StatusBadRequest)
+			return
+		}
+		err = deadletter.Replay(r.Context(), id, js, db)
+		if errors.Is(err, deadletter.ErrNotFound) {
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error replaying dead letter", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteDeadLetter удаляет отложенное сообщение без повторной публикации.
+func DeleteDeadLetter(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid id", http.StatusBadRequest)
+			return
+		}
+		err = deadletter.Delete(r.Context(), id, db)
+		if errors.Is(err, deadletter.ErrNotFound) {
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error deleting dead letter", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// EvictCache вытесняет заказы из кэша и загружает из базы данных те, что в ней остались.
+// Используется после исправления данных заказа в базе в обход сервиса.
+func EvictCache(shards *database.Shards) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req EvictRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		ids := uniqueIDs(req.IDs)
+		if len(ids) == 0 {
+			http.Error(w, "Missing ids", http.StatusBadRequest)
+			return
+		}
+		if len(ids) > maxBatchIDs {
+			http.Error(w, "Too many ids, maximum is "+strconv.Itoa(maxBatchIDs), http.StatusBadRequest)
+			return
+		}
+
+		result := EvictResult{Evicted: []string{}, Reloaded: []string{}}
+		for _, id := range ids {
+			if _, ok := cache.GetOrderFromCache(id); ok {
+				result.Evicted = append(result.Evicted, id)
+			}
+		}
+		cache.DeleteOrders(ids)
+
+		orders, err := shards.GetOrders(r.Context(), ids)
+		if err != nil {
+			http.Error(w, "Error reloading orders", http.StatusInternalServerError)
+			return
+		}
+		for _, id := range ids {
+			if order, ok := orders[id]; ok {
+				cache.CacheOrder(order)
+				result.Reloaded = append(result.Reloaded, id)
+			}
+		}
+		writeJSON(w, result)
+	}
+}
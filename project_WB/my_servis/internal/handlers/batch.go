@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"main.go/internal/auth"
+	"main.go/internal/pii"
+	"main.go/internal/projection"
+	cache "main.go/internal/storage/cache"
+	database "main.go/internal/storage/database"
+)
+
+const (
+	maxBatchIDs      = 500     // maxBatchIDs максимальное количество идентификаторов в одном запросе batchGet.
+	maxBatchBodySize = 1 << 20 // maxBatchBodySize максимальный размер тела запроса batchGet.
+)
+
+// BatchGetRequest тело запроса на получение нескольких заказов.
+type BatchGetRequest struct {
+	IDs    []string `json:"ids"`    // IDs идентификаторы заказов или трек-номера.
+	Fields []string `json:"fields"` // Fields поля заказа в ответе; пустой список возвращает заказ целиком.
+	View   string   `json:"view"`   // View именованное представление заказа вместо Fields: summary, full или public.
+}
+
+// BatchGetResult найденные заказы и идентификаторы, по которым ничего не найдено.
+type BatchGetResult struct {
+	Orders  []any    `json:"orders"`
+	Missing []string `json:"missing"`
+}
+
+// BatchGetOrders возвращает заказы по списку идентификаторов или трек-номеров за один запрос.
+// Заказы ищутся в кэше, а не найденные в нем - одним запросом к каждому шарду; найденные в базе
+// заказы добавляются в кэш. Заказы возвращаются в порядке идентификаторов в запросе.
+func BatchGetOrders(shards *database.Shards) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req BatchGetRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		ids := uniqueIDs(req.IDs)
+		if len(ids) == 0 {
+			http.Error(w, "Missing ids", http.StatusBadRequest)
+			return
+		}
+		if len(ids) > maxBatchIDs {
+			http.Error(w, "Too many ids, maximum is "+strconv.Itoa(maxBatchIDs), http.StatusBadRequest)
+			return
+		}
+		proj, err := projection.Select(req.View, req.Fields)
+		if err != nil {
+			http.Error(w, "Invalid fields or view: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		found, missing := cache.LookupOrders(ids)
+		if len(missing) > 0 {
+			fromDB, err := shards.LookupOrders(r.Context(), missing)
+			if err != nil {
+				http.Error(w, "Error fetching orders", http.StatusInternalServerError)
+				return
+			}
+			for uid, order := range fromDB {
+				found[uid] = order
+				cache.CacheOrder(order)
+			}
+		}
+
+		orders, missing := orderedMatches(ids, found)
+		uids := make([]string, len(orders))
+		for i, order := range orders {
+			uids[i] = order.OrderUID
+		}
+		auth.Audit(r, "orders.batch_get", uids...)
+
+		writeJSON(w, BatchGetResult{
+			Orders:  proj.ApplyAll(pii.ForReaderAll(auth.ReadsPII(r.Context()), orders)),
+			Missing: missing,
+		})
+	}
+}
+
+// uniqueIDs убирает пустые и повторяющиеся идентификаторы, сохраняя порядок.
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+// orderedMatches раскладывает найденные заказы в порядке ids: сначала совпадение по идентификатору,
+// затем все заказы с таким трек-номером. Каждый заказ попадает в результат один раз.
+func orderedMatches(ids []string, found map[string]model.Order) ([]model.Order, []string) {
+	byTrack := make(map[string][]model.Order)
+	for _, order := range found {
+		byTrack[order.TrackNumber] = append(byTrack[order.TrackNumber], order)
+	}
+	for _, orders := range byTrack {
+		sortByUID(orders)
+	}
+
+	orders := make([]model.Order, 0, len(found))
+	missing := []string{}
+	added := make(map[string]bool, len(found))
+	for _, id := range ids {
+		matches := byTrack[id]
+		if order, ok := found[id]; ok {
+			matches = append([]model.Order{order}, matches...)
+		}
+		if len(matches) == 0 {
+			missing = append(missing, id)
+			continue
+		}
+		for _, order := range matches {
+			if !added[order.OrderUID] {
+				added[order.OrderUID] = true
+				orders = append(orders, order)
+			}
+		}
+	}
+	return orders, missing
+}
+
+// sortByUID сортирует заказы по идентификатору.
+func sortByUID(orders []model.Order) {
+	slices.SortFunc(orders, func(a, b model.Order) int { return strings.Compare(a.OrderUID, b.OrderUID) })
+}
@@ -4,6 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"main.go/internal/auth"
+	"main.go/internal/codec"
+	"main.go/internal/httpcache"
+	"main.go/internal/pii"
+	"main.go/internal/projection"
 	cache "main.go/internal/storage/cache"
 )
 
@@ -16,23 +21,58 @@ func GetOrderFromCache(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем заказ из кэша
-	order, exists := cache.GetOrderFromCache(orderUID)
+	// Разбираем запрошенные поля или представление заказа (fields, view)
+	proj, err := projection.FromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid fields or view: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Получаем заказ из кэша вместе с JSON, сериализованным при записи в кэш
+	order, encoded, exists := cache.GetEncoded(orderUID)
 	if !exists {
 		http.Error(w, "Order not found", http.StatusNotFound) // Возвращаем ошибку, если заказ не найден в кэше.
 		return
 	}
+	auth.Audit(r, "order.read", orderUID)
+
+	// Телефон и email получателя видны полностью только клиентам с правом pii:read
+	if !auth.ReadsPII(r.Context()) {
+		order = pii.Mask(order)
+		encoded.JSON, encoded.ETag = encoded.MaskedJSON, encoded.MaskedETag
+	}
+
+	// Выбираем формат ответа по заголовку Accept (JSON или protobuf)
+	contentType, ok := codec.Negotiate(r.Header.Get("Accept"), codec.ContentTypeJSON, codec.ContentTypeProtobuf)
+	if !ok {
+		http.Error(w, "Not acceptable", http.StatusNotAcceptable) // Возвращаем ошибку, если клиент не принимает ни один из форматов.
+		return
+	}
+	c, _ := codec.ForContentType(contentType)
 
-	// Преобразуем данные заказа в формат JSON
-	responseData, err := json.Marshal(order)
+	// Преобразуем данные заказа в выбранный формат. Полный JSON берется из кэша без сериализации;
+	// при выборе полей в JSON попадают только они, в остальных форматах невыбранные поля обнуляются
+	responseData, etag := encoded.JSON, encoded.ETag
+	switch {
+	case contentType == codec.ContentTypeJSON && proj == nil && responseData != nil:
+	case contentType == codec.ContentTypeJSON:
+		responseData, err = json.Marshal(proj.Apply(order))
+		etag = httpcache.ETag(responseData)
+	default:
+		responseData, err = c.Marshal(proj.Prune(order))
+		etag = httpcache.ETag(responseData)
+	}
 	if err != nil {
-		http.Error(w, "Error marshaling response data", http.StatusInternalServerError) // Возвращаем ошибку, если возникла ошибка при преобразовании данных в JSON.
+		http.Error(w, "Error marshaling response data", http.StatusInternalServerError) // Возвращаем ошибку, если возникла ошибка при преобразовании данных.
 		return
 	}
 
-	// Устанавливаем заголовок Content-Type в application/json
-	w.Header().Set("Content-Type", "application/json")
+	// Устанавливаем заголовок Content-Type выбранного формата
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", auth.APIKeyHeader)
+	w.Header().Add("Vary", "Authorization")
 
-	// Отправляем данные заказа в ответ на запрос
-	w.Write(responseData)
+	// Отправляем данные заказа в ответ на запрос или 304, если у клиента уже есть эта версия
+	httpcache.Write(w, r, responseData, etag)
 }
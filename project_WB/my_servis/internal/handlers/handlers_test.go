@@ -1,12 +1,12 @@
 package handlers_test
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
-	_ "github.com/lib/pq"
 	config "main.go/internal"
 	"main.go/internal/handlers"
 	"main.go/internal/storage/cache"
@@ -39,7 +39,7 @@ func TestGetOrderFromCache(t *testing.T) {
 	}
 
 	// Проверить тело ответа
-	expected := `{"order_uid":"order_1","track_number":"track_1","entry":"entry_1","delivery":{"name":"Name_1","phone":"Phone_1","zip":"Zip_1","city":"City_1","address":"Address_1","region":"Region_1","email":"Email_1"},"payment":{"transaction":"Transaction_1","request_id":"RequestID_1","currency":"Currency_1","provider":"Provider_1","amount":1,"payment_dt":1,"bank":"Bank_1","delivery_cost":1,"goods_total":1,"custom_fee":1},"items":[{"chrt_id":1,"track_number":"track_1","price":1,"rid":"RID_1","name":"Name_1","sale":1,"size":"Size_1","total_price":1,"nm_id":1,"brand":"Brand_1","status":1},{"chrt_id":1,"track_number":"track_1","price":1,"rid":"RID_1","name":"Name_1","sale":1,"size":"Size_1","total_price":1,"nm_id":1,"brand":"Brand_1","status":1}],"locale":"Locale_1","internal_signature":"InternalSignature_1","customer_id":"CustomerID_1","delivery_service":"DeliveryService_1","shardkey":"Shardkey_1","sm_id":1,"date_created":"2021-11-26T06:22:19Z","oof_shard":"OOFShard_1"}`
+	expected := `{"schema_version":2,"order_uid":"order_1","track_number":"track_1","entry":"entry_1","delivery":{"name":"Name_1","phone":"Ph***_1","zip":"Zip_1","city":"City_1","address":"Address_1","region":"Region_1","email":"Em***_1"},"payment":{"transaction":"Transaction_1","request_id":"RequestID_1","currency":"RUB","provider":"Provider_1","amount":1,"payment_dt":1,"bank":"Bank_1","delivery_cost":1,"goods_total":1,"custom_fee":1},"items":[{"chrt_id":1,"track_number":"track_1","price":1,"rid":"RID_1","name":"Name_1","sale":1,"size":"Size_1","total_price":1,"nm_id":1,"brand":"Brand_1","status":1},{"chrt_id":1,"track_number":"track_1","price":1,"rid":"RID_1","name":"Name_1","sale":1,"size":"Size_1","total_price":1,"nm_id":1,"brand":"Brand_1","status":1}],"locale":"Locale_1","internal_signature":"InternalSignature_1","customer_id":"CustomerID_1","delivery_service":"DeliveryService_1","shardkey":"Shardkey_1","sm_id":1,"date_created":"2021-11-26T06:22:19Z","oof_shard":"OOFShard_1"}`
 	if rr.Body.String() != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v",
 			rr.Body.String(), expected)
@@ -49,11 +49,11 @@ func TestGetOrderFromCache(t *testing.T) {
 func preloadCache() {
 	cfg := config.MustLoad()
 	// Создать подключение к базе данных
-	db := database.Connect(cfg.Database)
-	defer db.Close()
+	cluster := database.Connect(cfg.Database)
+	defer cluster.Close()
 
 	// Загрузить все заказы из базы данных в кэш
-	allOrders, err := database.CacheAllOrdersFromDB(db)
+	allOrders, err := database.CacheAllOrdersFromDB(context.Background(), cluster.Replica())
 	if err != nil {
 		log.Printf("Error caching orders from database: %v", err)
 	}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	pb "github.com/Selandro/my_servis_order/project_WB/orderspb"
+	"google.golang.org/protobuf/proto"
+	"main.go/internal/auth"
+	"main.go/internal/codec"
+	"main.go/internal/natsstream"
+	"main.go/internal/pii"
+	"main.go/internal/projection"
+	"main.go/internal/search"
+	cache "main.go/internal/storage/cache"
+	database "main.go/internal/storage/database"
+)
+
+const (
+	defaultPageSize = 20  // defaultPageSize размер страницы списка заказов по умолчанию.
+	maxPageSize     = 100 // maxPageSize максимальный размер страницы списка заказов.
+)
+
+// OrderPage представляет страницу списка заказов.
+type OrderPage struct {
+	Orders []model.Order `json:"orders"`
+	Page   int           `json:"page"`
+	Size   int           `json:"size"`
+	Total  int           `json:"total"`
+}
+
+// sparsePage страница заказов, ограниченных выбранными полями.
+type sparsePage struct {
+	Orders []any `json:"orders"`
+	Page   int   `json:"page"`
+	Size   int   `json:"size"`
+	Total  int   `json:"total"`
+}
+
+// Counters содержит счетчики принятых и закэшированных заказов.
+type Counters struct {
+	Ingested int64 `json:"ingested"`
+	Cached   int   `json:"cached"`
+}
+
+// ListOrders возвращает постраничный список заказов из кэша.
+// Параметр q ищет заказ по идентификатору, трек-номеру или идентификатору клиента;
+// параметры page (с 1) и size задают страницу, fields или view - поля заказов в ответе.
+func ListOrders(w http.ResponseWriter, r *http.Request) {
+	page, size, ok := parsePage(r)
+	if !ok {
+		http.Error(w, "Invalid page or size parameter", http.StatusBadRequest)
+		return
+	}
+	proj, err := projection.FromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid fields or view: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	offset := (page - 1) * size
+
+	var result OrderPage
+	if q := r.URL.Query().Get("q"); q != "" {
+		found := cache.FindOrders(q)
+		result.Total = len(found)
+		result.Orders = cache.Paginate(found, offset, size)
+	} else {
+		result.Orders, result.Total = cache.ListOrders(offset, size)
+	}
+	result.Page = page
+	result.Size = size
+	writeOrderPage(w, r, result, proj)
+}
+
+// QueryOrders возвращает постраничный список заказов, документ которых удовлетворяет
+// выражению JSON path из параметра path, например path=$.items[*] ? (@.brand == "Vivienne Sabo").
+// Поиск выполняется на всех шардах, для основной базы - на реплике.
+func QueryOrders(shards *database.Shards) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			http.Error(w, "Missing path parameter", http.StatusBadRequest)
+			return
+		}
+		page, size, ok := parsePage(r)
+		if !ok {
+			http.Error(w, "Invalid page or size parameter", http.StatusBadRequest)
+			return
+		}
+		proj, err := projection.FromRequest(r)
+		if err != nil {
+			http.Error(w, "Invalid fields or view: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		orders, total, err := shards.QueryOrdersByPath(r.Context(), path, (page-1)*size, size)
+		if errors.Is(err, database.ErrInvalidPath) {
+			http.Error(w, "Invalid JSON path", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error querying orders", http.StatusInternalServerError)
+			return
+		}
+		writeOrderPage(w, r, OrderPage{Orders: orders, Page: page, Size: size, Total: total}, proj)
+	}
+}
+
+// SearchOrders возвращает постраничный список заказов, найденных по поисковому запросу из параметра q,
+// например q=brand:Vivienne city:Moscow amount>1000, в порядке убывания релевантности.
+// Поиск выполняется на всех шардах, для основной базы - на реплике.
+func SearchOrders(shards *database.Shards) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		if q == "" {
+			http.Error(w, "Missing q parameter", http.StatusBadRequest)
+			return
+		}
+		query, err := search.Parse(q)
+		if err != nil {
+			http.Error(w, "Invalid search query: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		page, size, ok := parsePage(r)
+		if !ok {
+			http.Error(w, "Invalid page or size parameter", http.StatusBadRequest)
+			return
+		}
+		proj, err := projection.FromRequest(r)
+		if err != nil {
+			http.Error(w, "Invalid fields or view: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		orders, total, err := shards.SearchOrders(r.Context(), query, (page-1)*size, size)
+		if errors.Is(err, database.ErrEncryptedPII) {
+			http.Error(w, "Search by recipient name, phone, email or address is unavailable while PII encryption is enabled", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error searching orders", http.StatusInternalServerError)
+			return
+		}
+		writeOrderPage(w, r, OrderPage{Orders: orders, Page: page, Size: size, Total: total}, proj)
+	}
+}
+
+// writeOrderPage отправляет страницу заказов в формате JSON или protobuf в зависимости от заголовка Accept.
+// Персональные данные маскируются, если запрос сделан не привилегированным API-ключом.
+// Заказы ограничиваются полями проекции proj.
+func writeOrderPage(w http.ResponseWriter, r *http.Request, result OrderPage, proj *projection.Projection) {
+	contentType, ok := codec.Negotiate(r.Header.Get("Accept"), codec.ContentTypeJSON, codec.ContentTypeProtobuf)
+	if !ok {
+		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	uids := make([]string, len(result.Orders))
+	for i, order := range result.Orders {
+		uids[i] = order.OrderUID
+	}
+	auth.Audit(r, "orders.list", uids...)
+	result.Orders = pii.ForReaderAll(auth.ReadsPII(r.Context()), result.Orders)
+	if contentType == codec.ContentTypeProtobuf {
+		msg := &pb.OrderPage{Page: int32(result.Page), Size: int32(result.Size), Total: int32(result.Total)}
+		for _, order := range result.Orders {
+			msg.Orders = append(msg.Orders, pb.FromModel(proj.Prune(order)))
+		}
+		writeProto(w, msg)
+		return
+	}
+	if proj != nil {
+		writeJSON(w, sparsePage{Orders: proj.ApplyAll(result.Orders), Page: result.Page, Size: result.Size, Total: result.Total})
+		return
+	}
+	writeJSON(w, result)
+}
+
+// writeProto отправляет protobuf-сообщение.
+func writeProto(w http.ResponseWriter, msg proto.Message) {
+	responseData, err := proto.Marshal(msg)
+	if err != nil {
+		http.Error(w, "Error marshaling response data", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", codec.ContentTypeProtobuf)
+	w.Write(responseData)
+}
+
+// GetCounters возвращает количество принятых из NATS и находящихся в кэше заказов.
+func GetCounters(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, Counters{
+		Ingested: natsstream.IngestedCount(),
+		Cached:   cache.CountOrders(),
+	})
+}
+
+// parsePage разбирает параметры пагинации page и size.
+func parsePage(r *http.Request) (int, int, bool) {
+	page, size := 1, defaultPageSize
+	var err error
+	if v := r.URL.Query().Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return 0, 0, false
+		}
+	}
+	if v := r.URL.Query().Get("size"); v != "" {
+		if size, err = strconv.Atoi(v); err != nil || size < 1 || size > maxPageSize {
+			return 0, 0, false
+		}
+	}
+	return page, size, true
+}
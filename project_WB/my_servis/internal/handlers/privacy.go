@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"main.go/internal/auth"
+	"main.go/internal/deadletter"
+	"main.go/internal/events"
+	cache "main.go/internal/storage/cache"
+	database "main.go/internal/storage/database"
+	"main.go/internal/webhooks"
+)
+
+// maxErasureBodySize максимальный размер тела запроса на удаление данных субъекта.
+const maxErasureBodySize = 64 << 10
+
+// ErasureResult результат удаления данных субъекта.
+type ErasureResult struct {
+	Orders      []string `json:"orders"`
+	DeadLetters int      `json:"dead_letters"`
+}
+
+// EraseSubject удаляет персональные данные клиента по запросу субъекта данных.
+// Тело запроса - JSON с полями customer_id и/или email. Заказы обезличиваются во всех шардах
+// и в очереди вебхуков, удаляются из кэша и истории потока событий; сообщения клиента,
+// которые не удалось разобрать, удаляются из очереди dead letter.
+func EraseSubject(shards *database.Shards, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var subject database.Subject
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxErasureBodySize)).Decode(&subject); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		erased, err := shards.EraseSubject(r.Context(), subject)
+		if errors.Is(err, database.ErrEmptySubject) {
+			http.Error(w, "Missing customer_id or email", http.StatusBadRequest)
+			return
+		}
+		auth.Audit(r, "subject.erase", erased...)
+		// Заказы, обезличенные в шардах до ошибки, все равно убираются из кэша и очереди вебхуков
+		cache.DeleteOrders(erased)
+		events.ForgetOrders(erased)
+		if len(erased) > 0 {
+			if werr := webhooks.EraseOrders(erased, db); werr != nil {
+				err = errors.Join(err, werr)
+			}
+		}
+		letters, derr := deadletter.EraseSubject(r.Context(), subject.CustomerID, subject.Email, db)
+		if derr != nil {
+			err = errors.Join(err, derr)
+		}
+		if err != nil {
+			http.Error(w, "Error erasing customer data", http.StatusInternalServerError)
+			return
+		}
+		if erased == nil {
+			erased = []string{}
+		}
+		writeJSON(w, ErasureResult{Orders: erased, DeadLetters: letters})
+	}
+}
+
+// ExportSubject выгружает все заказы клиента и его сообщения из очереди dead letter одним JSON-файлом
+// по запросу субъекта данных. Клиент задается параметрами customer_id и/или email.
+func ExportSubject(shards *database.Shards, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subject := database.Subject{
+			CustomerID: r.URL.Query().Get("customer_id"),
+			Email:      r.URL.Query().Get("email"),
+		}
+
+		export, err := shards.ExportSubject(r.Context(), subject)
+		if errors.Is(err, database.ErrEmptySubject) {
+			http.Error(w, "Missing customer_id or email", http.StatusBadRequest)
+			return
+		}
+		if err == nil {
+			export.DeadLetters, err = deadletter.ExportSubject(r.Context(), subject.CustomerID, subject.Email, db)
+		}
+		if err != nil {
+			http.Error(w, "Error exporting customer data", http.StatusInternalServerError)
+			return
+		}
+		uids := make([]string, len(export.Orders))
+		for i, order := range export.Orders {
+			uids[i] = order.OrderUID
+		}
+		auth.Audit(r, "subject.export", uids...)
+		w.Header().Set("Content-Disposition", `attachment; filename="customer-data.json"`)
+		writeJSON(w, export)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"main.go/internal/analytics"
+	database "main.go/internal/storage/database"
+)
+
+// defaultTopLimit количество строк в рейтингах по умолчанию.
+const defaultTopLimit = 10
+
+// StatsOrders возвращает количество заказов и выручку по дням или часам UTC в каждой валюте.
+// Параметры: from, to (RFC3339 или YYYY-MM-DD), currency, interval (day|hour, по умолчанию day).
+func StatsOrders(cluster *database.Cluster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tr, err := parseTimeRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		interval := r.URL.Query().Get("interval")
+		if interval == "" {
+			interval = "day"
+		}
+		if interval != "day" && interval != "hour" {
+			http.Error(w, "Invalid interval parameter", http.StatusBadRequest)
+			return
+		}
+		result, err := analytics.OrdersByInterval(cluster.Replica(), tr, interval)
+		if err != nil {
+			http.Error(w, "Error fetching stats", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, result)
+	}
+}
+
+// StatsBasket возвращает средний чек и среднее количество товаров в заказе по валютам.
+func StatsBasket(cluster *database.Cluster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tr, err := parseTimeRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := analytics.BasketSize(cluster.Replica(), tr)
+		if err != nil {
+			http.Error(w, "Error fetching stats", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, result)
+	}
+}
+
+// StatsTopBrands возвращает самые продаваемые бренды. Параметр limit ограничивает размер рейтинга.
+func StatsTopBrands(cluster *database.Cluster) http.HandlerFunc {
+	return statsTop(cluster, analytics.TopBrands)
+}
+
+// StatsTopProducts возвращает самые продаваемые товары по nm_id. Параметр limit ограничивает размер рейтинга.
+func StatsTopProducts(cluster *database.Cluster) http.HandlerFunc {
+	return statsTop(cluster, analytics.TopProducts)
+}
+
+// StatsPayments возвращает выручку с разбивкой по параметру group (provider|bank|currency).
+func StatsPayments(cluster *database.Cluster) http.HandlerFunc {
+	return statsBreakdown(cluster, "provider", analytics.RevenueByPayment)
+}
+
+// StatsDelivery возвращает заказы с разбивкой по параметру group (delivery_service|region).
+func StatsDelivery(cluster *database.Cluster) http.HandlerFunc {
+	return statsBreakdown(cluster, "delivery_service", analytics.DeliveryBreakdown)
+}
+
+// statsTop общий обработчик рейтингов. Как и остальные аналитические запросы, читает данные с реплики.
+func statsTop(cluster *database.Cluster, fetch func(*sql.DB, analytics.TimeRange, int) ([]analytics.TopEntry, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tr, err := parseTimeRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit := defaultTopLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		result, err := fetch(cluster.Replica(), tr, limit)
+		if err != nil {
+			http.Error(w, "Error fetching stats", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, result)
+	}
+}
+
+// statsBreakdown общий обработчик разбивок по признаку.
+func statsBreakdown(cluster *database.Cluster, defaultGroup string, fetch func(*sql.DB, analytics.TimeRange, string) ([]analytics.Breakdown, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tr, err := parseTimeRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		group := r.URL.Query().Get("group")
+		if group == "" {
+			group = defaultGroup
+		}
+		result, err := fetch(cluster.Replica(), tr, group)
+		if err != nil {
+			http.Error(w, "Invalid group parameter", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, result)
+	}
+}
+
+// parseTimeRange разбирает параметры from, to и currency запроса.
+func parseTimeRange(r *http.Request) (analytics.TimeRange, error) {
+	var tr analytics.TimeRange
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if tr.From, err = parseTime(v); err != nil {
+			return tr, fmt.Errorf("Invalid from parameter")
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if tr.To, err = parseTime(v); err != nil {
+			return tr, fmt.Errorf("Invalid to parameter")
+		}
+	}
+	if !tr.From.IsZero() && !tr.To.IsZero() && !tr.From.Before(tr.To) {
+		return tr, fmt.Errorf("Parameter from must be before to")
+	}
+	if v := r.URL.Query().Get("currency"); v != "" {
+		currency, err := model.ParseCurrency(v)
+		if err != nil {
+			return tr, fmt.Errorf("Invalid currency parameter")
+		}
+		tr.Currency = string(currency)
+	}
+	return tr, nil
+}
+
+// parseTime принимает время в формате RFC3339 или дату YYYY-MM-DD.
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+// writeJSON отправляет значение в формате JSON.
+func writeJSON(w http.ResponseWriter, v any) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+// writeJSONStatus отправляет значение в формате JSON с указанным кодом ответа.
+func writeJSONStatus(w http.ResponseWriter, status int, v any) {
+	responseData, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Error marshaling response data", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseData)
+}
@@ -0,0 +1,39 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"main.go/internal/handlers"
+)
+
+// TestStatsInvalidParams проверяет, что некорректные параметры отклоняются до обращения к базе данных.
+func TestStatsInvalidParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		query   string
+		want    string
+	}{
+		{"orders interval", handlers.StatsOrders(nil), "interval=week", "Invalid interval parameter"},
+		{"orders from", handlers.StatsOrders(nil), "from=yesterday", "Invalid from parameter"},
+		{"orders period", handlers.StatsOrders(nil), "from=2026-03-02&to=2026-03-01", "Parameter from must be before to"},
+		{"basket currency", handlers.StatsBasket(nil), "currency=XXX", "Invalid currency parameter"},
+		{"brands limit", handlers.StatsTopBrands(nil), "limit=0", "Invalid limit parameter"},
+		{"payments currency", handlers.StatsPayments(nil), "currency=rubles", "Invalid currency parameter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.handler(rr, httptest.NewRequest("GET", "/api/v1/stats?"+tt.query, nil))
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rr.Code, http.StatusBadRequest)
+			}
+			if got := strings.TrimSpace(rr.Body.String()); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
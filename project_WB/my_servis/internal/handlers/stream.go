@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"main.go/internal/auth"
+	"main.go/internal/events"
+	"main.go/internal/pii"
+)
+
+const (
+	heartbeatInterval = 15 * time.Second // heartbeatInterval период отправки heartbeat клиентам потока.
+	streamWriteWait   = 10 * time.Second // streamWriteWait время на запись одного сообщения медленному клиенту.
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// StreamOrders отдает поток событий о сохраненных заказах.
+// Запрос с заголовком Upgrade: websocket обслуживается по WebSocket, остальные - как Server-Sent Events.
+// Параметры customer_id и delivery_service фильтруют события на сервере, а заголовок Last-Event-ID
+// (или параметр last_event_id) возобновляет поток после указанного события.
+// Телефон и email получателя маскируются, если поток открыт не привилегированным API-ключом.
+func StreamOrders(w http.ResponseWriter, r *http.Request) {
+	filter := events.Filter{
+		CustomerID:      r.URL.Query().Get("customer_id"),
+		DeliveryService: r.URL.Query().Get("delivery_service"),
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	auth.Audit(r, "orders.stream")
+	privileged := auth.ReadsPII(r.Context())
+	if websocket.IsWebSocketUpgrade(r) {
+		streamWebSocket(w, r, filter, lastID, privileged)
+		return
+	}
+	streamSSE(w, r, filter, lastID, privileged)
+}
+
+// streamSSE отправляет события в формате Server-Sent Events.
+func streamSSE(w http.ResponseWriter, r *http.Request, filter events.Filter, lastID uint64, privileged bool) {
+	rc := http.NewResponseController(w)
+	// Общий WriteTimeout сервера не подходит для долгоживущего потока,
+	// поэтому срок записи продлевается перед каждым сообщением.
+	if err := rc.SetWriteDeadline(time.Now().Add(streamWriteWait)); err != nil {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := events.Subscribe(filter, lastID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if !ok {
+				if sub.Lagged() {
+					// Клиент не успевал читать поток; он может переподключиться с Last-Event-ID.
+					fmt.Fprint(w, "event: lagged\ndata: {}\n\n")
+					rc.Flush()
+				}
+				return
+			}
+			if !privileged {
+				e.Order = pii.Mask(e.Order)
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// streamWebSocket отправляет события по WebSocket, по одному JSON-сообщению на событие.
+func streamWebSocket(w http.ResponseWriter, r *http.Request, filter events.Filter, lastID uint64, privileged bool) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade уже отправил ответ с ошибкой
+	}
+	defer conn.Close()
+
+	sub := events.Subscribe(filter, lastID)
+	defer sub.Close()
+
+	// Чтение нужно для обработки управляющих фреймов и обнаружения закрытия соединения клиентом.
+	closed := make(chan struct{})
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				reason := "server closed stream"
+				if sub.Lagged() {
+					reason = "lagged"
+				}
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, reason),
+					time.Now().Add(streamWriteWait))
+				return
+			}
+			if !privileged {
+				e.Order = pii.Mask(e.Order)
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		}
+	}
+}
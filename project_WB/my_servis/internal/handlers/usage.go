@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"main.go/internal/ratelimit"
+)
+
+// UsageReport возвращает отчет об использовании API клиентами за день:
+// принятые и отклоненные запросы по маршрутам и расход дневных квот.
+// День задается параметром day (YYYY-MM-DD, UTC), по умолчанию - текущий.
+func UsageReport(limiter *ratelimit.Limiter, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		day := r.URL.Query().Get("day")
+		if day == "" {
+			day = time.Now().UTC().Format(time.DateOnly)
+		} else if _, err := time.Parse(time.DateOnly, day); err != nil {
+			http.Error(w, "Invalid day", http.StatusBadRequest)
+			return
+		}
+		report, err := limiter.Report(r.Context(), day, db)
+		if err != nil {
+			http.Error(w, "Error fetching usage report", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, report)
+	}
+}
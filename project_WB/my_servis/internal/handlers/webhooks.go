@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"main.go/internal/webhooks"
+)
+
+const (
+	defaultDeliveriesLimit = 50       // defaultDeliveriesLimit количество записей журнала доставки по умолчанию.
+	maxWebhookBodySize     = 16 << 10 // maxWebhookBodySize максимальный размер тела запроса на создание подписки.
+)
+
+// createWebhookRequest тело запроса на создание подписки.
+type createWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// CreateWebhook регистрирует подписку на события заказов.
+// Секрет для проверки подписи возвращается только в ответе на этот запрос.
+func CreateWebhook(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createWebhookRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBodySize)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		u, err := url.Parse(req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "Invalid url", http.StatusBadRequest)
+			return
+		}
+		if err := webhooks.ValidateEvents(req.Events); err != nil {
+			http.Error(w, "Invalid events: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sub, err := webhooks.CreateSubscription(webhooks.Subscription{
+			URL:    req.URL,
+			Events: req.Events,
+			Secret: req.Secret,
+		}, db)
+		if err != nil {
+			http.Error(w, "Error creating webhook", http.StatusInternalServerError)
+			return
+		}
+		writeJSONStatus(w, http.StatusCreated, sub)
+	}
+}
+
+// ListWebhooks возвращает зарегистрированные подписки.
+func ListWebhooks(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subs, err := webhooks.ListSubscriptions(db)
+		if err != nil {
+			http.Error(w, "Error fetching webhooks", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, subs)
+	}
+}
+
+// DeleteWebhook удаляет подписку.
+func DeleteWebhook(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid id", http.StatusBadRequest)
+			return
+		}
+		err = webhooks.DeleteSubscription(id, db)
+		if errors.Is(err, webhooks.ErrNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error deleting webhook", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListWebhookDeliveries возвращает журнал доставки по подписке, начиная с последних попыток.
+func ListWebhookDeliveries(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid id", http.StatusBadRequest)
+			return
+		}
+		limit := defaultDeliveriesLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		deliveries, err := webhooks.ListDeliveries(id, limit, db)
+		if errors.Is(err, webhooks.ErrNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error fetching deliveries", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, deliveries)
+	}
+}
@@ -0,0 +1,193 @@
+package httpcache
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// encoder потоковый кодировщик gzip или zstd.
+type encoder interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+// Пулы кодировщиков: создание кодировщика, особенно zstd, дороже сжатия небольшого ответа.
+var (
+	gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	zstdPool = sync.Pool{New: func() any {
+		e, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return e
+	}}
+)
+
+// acquire берет из пула кодировщик encoding, пишущий в w.
+func acquire(encoding string, w io.Writer) encoder {
+	if encoding == "zstd" {
+		e := zstdPool.Get().(*zstd.Encoder)
+		e.Reset(w)
+		return e
+	}
+	e := gzipPool.Get().(*gzip.Writer)
+	e.Reset(w)
+	return e
+}
+
+// release возвращает закрытый кодировщик в пул.
+func release(e encoder) {
+	switch e := e.(type) {
+	case *zstd.Encoder:
+		zstdPool.Put(e)
+	case *gzip.Writer:
+		gzipPool.Put(e)
+	}
+}
+
+// negotiateEncoding выбирает сжатие по заголовку Accept-Encoding: zstd или gzip с наибольшим весом q,
+// при равном весе - zstd. Пустая строка означает ответ без сжатия.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "zstd" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > bestQ || (q == bestQ && q > 0 && name == "zstd") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter накапливает начало ответа и, если он достигает minSize байт, сжимает его выбранным
+// кодировщиком. Короткие, пустые, потоковые и уже сжатые ответы передаются без изменений.
+type compressWriter struct {
+	http.ResponseWriter
+	cacheControl string
+	minSize      int
+	encoding     string
+
+	status    int
+	committed bool
+	buf       []byte
+	enc       encoder
+}
+
+// WriteHeader запоминает код ответа; заголовки отправляются, когда выбран способ передачи тела.
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	h := w.Header()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		w.encoding = ""
+	}
+	if w.encoding == "" {
+		w.commit()
+	}
+}
+
+// Write сжимает тело ответа или накапливает его, пока не станет ясно, стоит ли сжимать.
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	if w.committed {
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if w.Header().Get("Content-Type") == "" {
+			// Без явного типа net/http определил бы его по уже сжатым байтам
+			w.Header().Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.Header().Del("Content-Length")
+		w.enc = acquire(w.encoding, w.ResponseWriter)
+		w.commit()
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.enc.Write(buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// commit отправляет заголовки ответа.
+func (w *compressWriter) commit() {
+	h := w.Header()
+	if w.cacheControl != "" && (w.status == http.StatusOK || w.status == http.StatusNotModified) {
+		h.Set("Cache-Control", w.cacheControl)
+	}
+	if w.minSize >= 0 {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if etag := h.Get("ETag"); etag != "" && w.enc != nil {
+		h.Set("ETag", encodedETag(etag, w.encoding))
+	}
+	w.committed = true
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// writeBuffered отправляет накопленное начало ответа без сжатия.
+func (w *compressWriter) writeBuffered() {
+	if w.committed {
+		return
+	}
+	w.commit()
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// Flush отправляет клиенту записанную часть ответа. Ответ, который сбрасывается до достижения
+// minSize, считается потоковым и дальше передается без сжатия.
+func (w *compressWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	} else {
+		w.writeBuffered()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish завершает сжатый поток или отправляет короткий ответ без сжатия.
+func (w *compressWriter) finish() {
+	if w.enc != nil {
+		w.enc.Close()
+		release(w.enc)
+		w.enc = nil
+		return
+	}
+	if w.status != 0 {
+		w.writeBuffered()
+	}
+}
@@ -0,0 +1,107 @@
+// Package httpcache отвечает за кэширование ответов HTTP API на стороне клиента и их сжатие:
+// ETag и условные запросы If-None-Match, заголовок Cache-Control по маршрутам
+// и сжатие gzip или zstd по заголовку Accept-Encoding.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	config "main.go/internal"
+)
+
+// ETag возвращает сильный ETag по хешу содержимого ответа.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified сообщает, есть ли etag среди значений заголовка If-None-Match.
+// Слабые значения (W/) сравниваются без префикса, как требует RFC 9110 для If-None-Match,
+// а суффикс кодировки сжатого варианта ответа (см. encodedETag) не учитывается.
+func NotModified(r *http.Request, etag string) bool {
+	_, ok := matchETag(r, etag)
+	return ok
+}
+
+// matchETag возвращает значение If-None-Match, совпавшее с etag, без префикса W/.
+func matchETag(r *http.Request, etag string) (string, bool) {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return "", false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" {
+			return etag, true
+		}
+		if decodedETag(tag) == etag {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// encodedETag возвращает ETag варианта ответа, сжатого кодировкой encoding. Сильный ETag
+// относится к конкретному представлению, поэтому ответы gzip и zstd получают свой суффикс.
+func encodedETag(etag, encoding string) string {
+	if encoding == "" || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// decodedETag убирает из ETag суффикс кодировки, добавленный encodedETag.
+func decodedETag(etag string) string {
+	for _, encoding := range []string{"gzip", "zstd"} {
+		if tag, ok := strings.CutSuffix(etag, "-"+encoding+`"`); ok {
+			return tag + `"`
+		}
+	}
+	return etag
+}
+
+// Write отправляет тело ответа с заголовком ETag, а если клиент уже получил это содержимое, - 304 без тела.
+// Ответ 304 повторяет ETag того варианта ответа, который есть у клиента.
+func Write(w http.ResponseWriter, r *http.Request, body []byte, etag string) {
+	if tag, ok := matchETag(r, etag); ok {
+		w.Header().Set("ETag", tag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Write(body)
+}
+
+// Policy применяет к ответам маршрутов заголовок Cache-Control и сжатие.
+type Policy struct {
+	cacheControl map[string]string
+	minSize      int
+}
+
+// New создает Policy по настройкам HTTP-сервера.
+func New(cfg config.HTTPServerConfig) *Policy {
+	return &Policy{cacheControl: cfg.CacheControl, minSize: cfg.CompressMinSize}
+}
+
+// Wrap задает ответам маршрута route заголовок Cache-Control из конфигурации и сжимает ответы
+// не меньше CompressMinSize байт, если клиент принимает gzip или zstd. Cache-Control задается
+// только успешным ответам и ответам 304, чтобы клиенты не кэшировали ошибки.
+func (p *Policy) Wrap(route string, next http.Handler) http.Handler {
+	cacheControl := p.cacheControl[route]
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Соединения WebSocket передаются обработчику без обертки: ему нужен Hijack
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, cacheControl: cacheControl, minSize: p.minSize}
+		if p.minSize >= 0 && r.Method != http.MethodHead {
+			cw.encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
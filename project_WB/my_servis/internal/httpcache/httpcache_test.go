@@ -0,0 +1,89 @@
+package httpcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	config "main.go/internal"
+)
+
+func TestWrapCompressesAndRevalidates(t *testing.T) {
+	body := []byte(strings.Repeat(`{"order_uid":"b563feb7b2b84b6test"}`, 64))
+	etag := ETag(body)
+	p := New(config.HTTPServerConfig{CacheControl: map[string]string{"order": "private, no-cache"}, CompressMinSize: 1024})
+	h := p.Wrap("order", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		Write(w, r, body, etag)
+	}))
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/order?id=1", nil)
+		r.Header = header
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		acceptEncoding string
+		want           string
+		decode         func(io.Reader) ([]byte, error)
+	}{
+		{"gzip, deflate, br", "gzip", func(r io.Reader) ([]byte, error) {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(zr)
+		}},
+		{"gzip;q=0.5, zstd", "zstd", func(r io.Reader) ([]byte, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			return io.ReadAll(zr)
+		}},
+		{"zstd;q=0, identity", "", io.ReadAll},
+	}
+	for _, tt := range tests {
+		w := serve(http.Header{"Accept-Encoding": {tt.acceptEncoding}})
+		if got := w.Header().Get("Content-Encoding"); got != tt.want {
+			t.Errorf("%q: Content-Encoding = %q, want %q", tt.acceptEncoding, got, tt.want)
+		}
+		got, err := tt.decode(w.Body)
+		if err != nil || !bytes.Equal(got, body) {
+			t.Errorf("%q: decoded body mismatch, err %v", tt.acceptEncoding, err)
+		}
+		if w.Header().Get("ETag") != encodedETag(etag, tt.want) || w.Header().Get("Cache-Control") != "private, no-cache" {
+			t.Errorf("%q: headers %v", tt.acceptEncoding, w.Header())
+		}
+	}
+
+	for _, tag := range []string{etag, encodedETag(etag, "gzip"), encodedETag(etag, "zstd")} {
+		w := serve(http.Header{"If-None-Match": {`"other", W/` + tag}, "Accept-Encoding": {"gzip"}})
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("Content-Encoding") != "" || w.Header().Get("ETag") != tag {
+			t.Fatalf("conditional request %s: status %d, body %d bytes, headers %v", tag, w.Code, w.Body.Len(), w.Header())
+		}
+	}
+}
+
+func TestWrapSkipsSmallAndErrorCacheControl(t *testing.T) {
+	p := New(config.HTTPServerConfig{CacheControl: map[string]string{"order": "private, max-age=60"}, CompressMinSize: 1024})
+	h := p.Wrap("order", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Order not found", http.StatusNotFound)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/order?id=1", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound || w.Body.String() != "Order not found\n" ||
+		w.Header().Get("Content-Encoding") != "" || w.Header().Get("Cache-Control") != "" {
+		t.Fatalf("status %d, body %q, headers %v", w.Code, w.Body.String(), w.Header())
+	}
+}
@@ -0,0 +1,114 @@
+package natsstream
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"github.com/nats-io/nats.go"
+	database "main.go/internal/storage/database"
+)
+
+// pending декодированное сообщение, ожидающее записи в базу данных.
+type pending struct {
+	msg      *nats.Msg
+	order    model.Order
+	document []byte
+}
+
+// batcher накапливает сообщения и передает их flush пакетами не более size штук
+// или по истечении timeout с момента поступления первого сообщения пакета.
+type batcher struct {
+	size    int
+	timeout time.Duration
+	flush   func([]pending)
+}
+
+// run читает сообщения из in до его закрытия; оставшийся неполный пакет записывается перед выходом.
+func (b *batcher) run(in <-chan pending) {
+	var batch []pending
+	timer := time.NewTimer(b.timeout)
+	// stop останавливает таймер и убирает из канала уже сработавший сигнал, иначе следующий
+	// Reset (до Go 1.23) оставил бы его там и новый пакет был бы записан сразу
+	stop := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+	stop()
+
+	flush := func() {
+		stop()
+		if len(batch) > 0 {
+			b.flush(batch)
+			batch = nil
+		}
+	}
+
+	for {
+		select {
+		case p, ok := <-in:
+			if !ok {
+				flush()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(b.timeout)
+			}
+			batch = append(batch, p)
+			if len(batch) >= b.size {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// storeShards делит пакет по шардам заказов и записывает каждую часть в базу данных своего шарда.
+func storeShards(batch []pending, shards *database.Shards, db *sql.DB) {
+	byShard := make(map[string][]pending)
+	for _, p := range batch {
+		name := shards.Name(p.order)
+		byShard[name] = append(byShard[name], p)
+	}
+	for name, part := range byShard {
+		storeBatch(part, shards.DB(name), db)
+	}
+}
+
+// storeBatch записывает пакет в базу данных шарда shardDB одной транзакцией и подтверждает
+// сообщения только после фиксации. Если пакет записать не удалось, сообщения обрабатываются по одному.
+func storeBatch(batch []pending, shardDB, db *sql.DB) {
+	orders := make([]database.BatchOrder, 0, len(batch))
+	for _, p := range batch {
+		orders = append(orders, database.BatchOrder{Order: p.order, Document: p.document})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	inserted, err := database.InsertOrdersBatch(ctx, orders, shardDB)
+	cancel()
+	if err != nil {
+		fmt.Println("Ошибка пакетной вставки заказов, переход к обработке по одному:", err)
+		for _, p := range batch {
+			storeOne(p, shardDB, db)
+		}
+		return
+	}
+
+	for _, p := range batch {
+		if inserted[p.order.OrderUID] {
+			// Повтор того же заказа в пакете обрабатывается как уже существующий
+			delete(inserted, p.order.OrderUID)
+			afterInsert(p.order, db)
+		} else {
+			fmt.Println("Заказ с таким же ID уже существует")
+		}
+		p.msg.Ack()
+	}
+}
@@ -0,0 +1,45 @@
+package natsstream
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+func TestBatcherFlushesBySizeAndTimeout(t *testing.T) {
+	flushed := make(chan []pending, 10)
+	b := &batcher{size: 2, timeout: 20 * time.Millisecond, flush: func(batch []pending) { flushed <- batch }}
+
+	in := make(chan pending)
+	done := make(chan struct{})
+	go func() {
+		b.run(in)
+		close(done)
+	}()
+
+	for _, uid := range []string{"order_1", "order_2", "order_3"} {
+		in <- pending{order: model.Order{OrderUID: uid}}
+	}
+
+	// Первые два заказа образуют полный пакет, третий записывается по таймауту
+	if got := <-flushed; len(got) != 2 || got[0].order.OrderUID != "order_1" || got[1].order.OrderUID != "order_2" {
+		t.Fatalf("first batch = %v, want order_1 and order_2", got)
+	}
+	select {
+	case got := <-flushed:
+		if len(got) != 1 || got[0].order.OrderUID != "order_3" {
+			t.Fatalf("second batch = %v, want order_3", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("partial batch was not flushed after timeout")
+	}
+
+	// Закрытие входного канала записывает оставшийся пакет
+	in <- pending{order: model.Order{OrderUID: "order_4"}}
+	close(in)
+	<-done
+	if got := <-flushed; len(got) != 1 || got[0].order.OrderUID != "order_4" {
+		t.Fatalf("final batch = %v, want order_4", got)
+	}
+}
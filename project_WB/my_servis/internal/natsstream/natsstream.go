@@ -1,29 +1,58 @@
 package natsstream
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
 	"github.com/nats-io/nats.go"
 	config "main.go/internal"
+	"main.go/internal/analytics"
+	"main.go/internal/codec"
+	"main.go/internal/deadletter"
+	"main.go/internal/events"
 	"main.go/internal/storage/cache"
 	database "main.go/internal/storage/database"
-	"main.go/orders_model"
+	"main.go/internal/webhooks"
 )
 
+const (
+	// storeTimeout ограничивает время записи заказа или пакета в базу данных,
+	// чтобы зависший Postgres не блокировал обработку сообщений NATS.
+	storeTimeout = 10 * time.Second
+
+	eventsBatchSize    = 100                // eventsBatchSize количество событий заказов, применяемых за один проход.
+	eventsPollInterval = time.Second        // eventsPollInterval период опроса очереди событий заказов.
+	cleanupInterval    = time.Hour          // cleanupInterval период удаления старых отметок о применении событий.
+	appliedRetention   = 7 * 24 * time.Hour // appliedRetention срок хранения отметок о применении событий.
+)
+
+// ingested счетчик заказов, принятых из NATS с момента запуска сервиса.
+var ingested atomic.Int64
+
+// IngestedCount возвращает количество заказов, принятых из NATS с момента запуска сервиса.
+func IngestedCount() int64 {
+	return ingested.Load()
+}
+
 // Stream представляет поток сообщений от NATS.
 type Stream struct {
-	OrdersChannel chan *orders_model.Order
+	OrdersChannel chan *model.Order
 }
 
 // Subscribe подписывается на поток сообщений и обрабатывает их.
 func (s *Stream) Subscribe(db *sql.DB) {
 	for order := range s.OrdersChannel {
 		// Обработка сообщения - вставка заказа в базу данных и кэширование
-		if err := database.InsertOrderToDB(*order, db); err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		err := database.InsertOrderToDB(ctx, *order, nil, db)
+		cancel()
+		if err != nil {
 			fmt.Println("Ошибка при вставке заказа в базу данных:", err)
 			continue
 		}
@@ -48,36 +77,149 @@ func Connect(cfg config.NatsConfig) nats.JetStreamContext {
 }
 
 // Subscribe подписывается на указанный канал и обрабатывает полученные сообщения.
-func Subscribe(js nats.JetStreamContext, subject string, db *sql.DB) {
+// При batchSize больше 1 заказы записываются пакетами не более batchSize штук
+// или раз в batchTimeout; иначе каждое сообщение записывается отдельно.
+// Заказы записываются в базу данных своего шарда; статистика и вебхуки ведутся в основной базе db.
+func Subscribe(js nats.JetStreamContext, subject string, batchSize int, batchTimeout time.Duration, shards *database.Shards, db *sql.DB) {
 	ackWait := 30 * time.Second
+
+	store := func(p pending) { storeOne(p, shards.For(p.order), db) }
+	if batchSize > 1 {
+		in := make(chan pending, batchSize)
+		b := &batcher{size: batchSize, timeout: batchTimeout, flush: func(batch []pending) { storeShards(batch, shards, db) }}
+		go b.run(in)
+		store = func(p pending) { in <- p }
+	}
+
 	_, err := js.Subscribe(subject, func(msg *nats.Msg) {
-		var order orders_model.Order
-		if err := json.Unmarshal(msg.Data, &order); err != nil {
-			fmt.Println("Ошибка декодирования JSON:", err)
+		// Формат сообщения определяется заголовком Content-Type; без заголовка - JSON
+		c, err := codec.ForContentType(msg.Header.Get("Content-Type"))
+		if err != nil {
+			fmt.Println("Ошибка определения формата сообщения:", err)
+			deadLetter(msg, err, db)
 			return
 		}
-		available, err := database.OrderExists(order.OrderUID, db)
-		if err != nil {
-			fmt.Println("Ошибка при проверке существования заказа:", err)
+		var order model.Order
+		if err := c.Unmarshal(msg.Data, &order); err != nil {
+			fmt.Println("Ошибка декодирования сообщения:", c.ContentType(), err)
+			deadLetter(msg, err, db)
 			return
 		}
+		// Исходный JSON сохраняется как документ заказа; для бинарных форматов документ строится из модели
+		p := pending{msg: msg, order: order}
+		if c.ContentType() == codec.ContentTypeJSON {
+			p.document = msg.Data
+		}
+		store(p)
+	}, nats.AckWait(ackWait), nats.ManualAck())
+
+	if err != nil {
+		log.Fatalf("Ошибка при подписке на JetStream: %v", err)
+	}
+}
+
+// deadLetter откладывает сообщение, которое не удалось разобрать, и подтверждает его:
+// повторная доставка того же сообщения закончится той же ошибкой. Если сохранить сообщение
+// не удалось, оно остается неподтвержденным и будет доставлено снова.
+func deadLetter(msg *nats.Msg, reason error, db *sql.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := deadletter.Add(ctx, msg, reason, db); err != nil {
+		fmt.Println("Ошибка при сохранении сообщения в очередь отложенных:", err)
+		return
+	}
+	msg.Ack()
+}
+
+// storeOne записывает один заказ в базу данных шарда shardDB, если его там еще нет и его данные
+// не удалены по запросу клиента, и подтверждает сообщение.
+func storeOne(p pending, shardDB, db *sql.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
 
-		if available {
+	available, err := database.OrderExists(ctx, p.order.OrderUID, shardDB)
+	if err != nil {
+		fmt.Println("Ошибка при проверке существования заказа:", err)
+		return
+	}
+	erased, err := database.OrderErased(ctx, p.order.OrderUID, shardDB)
+	if err != nil {
+		fmt.Println("Ошибка при проверке отметки об удалении заказа:", err)
+		return
+	}
+
+	if available {
+		// Повторно доставленный заказ подтверждается, чтобы JetStream не присылал его снова
+		fmt.Println("Заказ с таким же ID уже существует")
+	} else if erased {
+		// Данные заказа удалены по запросу клиента; повтор сообщения не должен их восстановить
+		fmt.Println("Данные заказа удалены по запросу клиента, повторная запись пропущена:", p.order.OrderUID)
+	} else {
+		err := database.InsertOrderToDB(ctx, p.order, p.document, shardDB)
+		if errors.Is(err, database.ErrOrderExists) {
+			// Заказ записала параллельная вставка с тем же идентификатором
 			fmt.Println("Заказ с таким же ID уже существует")
+		} else if err != nil {
+			fmt.Println("Ошибка при вставке заказа в базу данных:", err)
+			return
 		} else {
+			afterInsert(p.order, db)
+		}
+	}
+	p.msg.Ack()
+}
+
+// afterInsert обновляет кэш и уведомляет подписчиков о сохраненном заказе.
+// Статистика и вебхуки обновляются из очереди событий заказов (см. RunOrderEvents).
+func afterInsert(order model.Order, db *sql.DB) {
+	cache.CacheOrder(order)
+	ingested.Add(1)
+	events.PublishOrderStored(order)
+	fmt.Println("Заказ успешно добавлен:", order.OrderUID)
+}
 
-			err := database.InsertOrderToDB(order, db)
-			if err != nil {
-				fmt.Println("Ошибка при вставке заказа в базу данных:", err)
-				return
+// RunOrderEvents применяет события о сохраненных заказах из очереди шарда shardDB в основной базе
+// данных db: обновляет сводные таблицы статистики и ставит вебхуки в исходящую очередь. События записываются в транзакции вставки заказа,
+// поэтому ни одно из них не теряется при сбое после ее фиксации. Не возвращает управление.
+func RunOrderEvents(shardDB, db *sql.DB) {
+	var lastCleanup time.Time
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		n, err := database.ApplyOrderEvents(ctx, eventsBatchSize, applyOrderEvent, shardDB, db)
+		if err != nil {
+			fmt.Println("Ошибка применения событий заказов:", err)
+		}
+		if time.Since(lastCleanup) > cleanupInterval {
+			if err := database.DeleteAppliedEvents(ctx, time.Now().Add(-appliedRetention), db); err != nil {
+				fmt.Println("Ошибка очистки отметок о применении событий:", err)
 			}
-			cache.CacheOrder(order)
-			fmt.Println("Заказ успешно добавлен:", order.OrderUID)
-			msg.Ack()
+			lastCleanup = time.Now()
 		}
-	}, nats.AckWait(ackWait), nats.ManualAck())
+		cancel()
+		if n < eventsBatchSize {
+			time.Sleep(eventsPollInterval)
+		}
+	}
+}
 
+// applyOrderEvent применяет событие о сохраненном заказе в транзакции tx основной базы данных.
+func applyOrderEvent(ctx context.Context, ev database.OrderEvent, tx *sql.Tx) error {
+	if err := analytics.RecordOrder(ctx, ev.Order, tx); err != nil {
+		return fmt.Errorf("ошибка обновления статистики: %v", err)
+	}
+	return webhooks.Enqueue(ctx, ev.ID, ev.Type, ev.CreatedAt, ev.Order, tx)
+}
+
+// BackfillStats заполняет пустые сводные таблицы статистики заказами всех шардов, события
+// которых уже применены. Вызывается при запуске до RunOrderEvents, чтобы заказ не был учтен дважды.
+func BackfillStats(ctx context.Context, shards *database.Shards, db *sql.DB) error {
+	empty, err := analytics.Empty(ctx, db)
+	if err != nil || !empty {
+		return err
+	}
+	orders, err := shards.AppliedOrders(ctx)
 	if err != nil {
-		log.Fatalf("Ошибка при подписке на JetStream: %v", err)
+		return err
 	}
+	return analytics.Backfill(ctx, orders, db)
 }
@@ -0,0 +1,429 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// initialisms части имен, которые в Go пишутся заглавными буквами.
+var initialisms = map[string]string{"id": "ID", "ids": "IDs", "uid": "UID", "url": "URL", "ms": "MS", "dt": "DT"}
+
+// goName переводит имя из спецификации (order_uid, getOrder) в экспортируемое имя Go (OrderUID, GetOrder).
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		if s, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// generator собирает исходный код клиента.
+type generator struct {
+	spec    *Spec
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+// GenerateClient генерирует исходный код клиента пакета pkg: типы схем из components и по методу
+// на каждую операцию, кроме помеченных x-go-skip. Схемы с x-go-type становятся псевдонимами
+// типов модели заказа. Транспорт (Client, do) пакет клиента реализует вручную.
+func GenerateClient(spec *Spec, pkg string) ([]byte, error) {
+	g := &generator{spec: spec, imports: map[string]bool{}}
+	if err := g.schemas(); err != nil {
+		return nil, err
+	}
+	if err := g.operations(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by genclient from openapi.json. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	out.WriteString("import (\n")
+	for _, path := range imports {
+		if path != modelImport {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+	}
+	if g.imports[modelImport] {
+		fmt.Fprintf(&out, "\n\tmodel %q\n", modelImport)
+	}
+	out.WriteString(")\n\n")
+	fmt.Fprintf(&out, "// Version is the API version (info.version) the client was generated from.\nconst Version = %q\n\n", spec.Info.Version)
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка форматирования клиента: %v", err)
+	}
+	return src, nil
+}
+
+// modelImport путь пакета модели заказа для схем с x-go-type.
+const modelImport = "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+
+// comment пишет комментарий из строк текста спецификации; пустая строка разделяет абзацы.
+func (g *generator) comment(lines ...string) {
+	for _, line := range lines {
+		if line == "" {
+			g.buf.WriteString("//\n")
+			continue
+		}
+		for _, s := range strings.Split(line, "\n") {
+			fmt.Fprintf(&g.buf, "// %s\n", s)
+		}
+	}
+}
+
+// schemas генерирует типы схем в алфавитном порядке.
+func (g *generator) schemas() error {
+	names := make([]string, 0, len(g.spec.Components.Schemas))
+	for name := range g.spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := g.spec.Components.Schemas[name]
+		if s.GoType != "" {
+			if !strings.HasPrefix(s.GoType, "model.") {
+				return fmt.Errorf("схема %s: неподдерживаемый x-go-type %q", name, s.GoType)
+			}
+			g.imports[modelImport] = true
+			g.comment(fmt.Sprintf("%s is %s from the shared order model.", name, s.GoType))
+			fmt.Fprintf(&g.buf, "type %s = %s\n\n", name, s.GoType)
+			continue
+		}
+		if s.Type != "object" {
+			return fmt.Errorf("схема %s: ожидается object, получено %q", name, s.Type)
+		}
+		g.comment(fmt.Sprintf("%s is the %s schema.", name, name))
+		if s.Description != "" {
+			g.comment("", s.Description)
+		}
+		fmt.Fprintf(&g.buf, "type %s struct {\n", name)
+		required := map[string]bool{}
+		for _, r := range s.Required {
+			required[r] = true
+		}
+		for _, prop := range s.Properties {
+			typ, err := g.goType(prop.Schema)
+			if err != nil {
+				return fmt.Errorf("схема %s, свойство %s: %v", name, prop.Name, err)
+			}
+			tag := prop.Name
+			if !required[prop.Name] {
+				tag += ",omitempty"
+			}
+			if prop.Schema.Description != "" {
+				fmt.Fprintf(&g.buf, "\t// %s\n", prop.Schema.Description)
+			}
+			fmt.Fprintf(&g.buf, "\t%s %s `json:%q`\n", goName(prop.Name), typ, tag)
+		}
+		g.buf.WriteString("}\n\n")
+	}
+	return nil
+}
+
+// goType возвращает тип Go для схемы.
+func (g *generator) goType(s *Schema) (string, error) {
+	if s.Ref != "" {
+		if _, err := g.spec.Schema(s); err != nil {
+			return "", err
+		}
+		return refName(s.Ref), nil
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("массив без items")
+		}
+		item, err := g.goType(s.Items)
+		return "[]" + item, err
+	case "object":
+		if s.AdditionalProperties == nil {
+			return "map[string]any", nil
+		}
+		value, err := g.goType(s.AdditionalProperties)
+		return "map[string]" + value, err
+	}
+	return "", fmt.Errorf("неподдерживаемый тип %q", s.Type)
+}
+
+// endpoint операция вместе с путем и HTTP-методом.
+type endpoint struct {
+	path   string
+	method string
+	op     Operation
+}
+
+// operations генерирует методы клиента в порядке путей и методов.
+func (g *generator) operations() error {
+	var endpoints []endpoint
+	for path, methods := range g.spec.Paths {
+		for method, op := range methods {
+			if !op.GoSkip {
+				endpoints = append(endpoints, endpoint{path: path, method: method, op: op})
+			}
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].path != endpoints[j].path {
+			return endpoints[i].path < endpoints[j].path
+		}
+		return endpoints[i].method < endpoints[j].method
+	})
+	for _, e := range endpoints {
+		if err := g.operation(e); err != nil {
+			return fmt.Errorf("операция %s %s: %v", strings.ToUpper(e.method), e.path, err)
+		}
+	}
+	return nil
+}
+
+// param параметр запроса в структуре параметров метода.
+type param struct {
+	Parameter
+	field string
+	typ   string
+}
+
+// operation генерирует метод клиента и, если у операции есть параметры пути или запроса, их структуру.
+func (g *generator) operation(e endpoint) error {
+	if e.op.OperationID == "" {
+		return fmt.Errorf("не задан operationId")
+	}
+	name := goName(e.op.OperationID)
+
+	var params []param
+	for _, p := range e.op.Parameters {
+		p, err := g.spec.Parameter(p)
+		if err != nil {
+			return err
+		}
+		if p.In != "query" && p.In != "path" {
+			continue
+		}
+		typ, err := g.goType(p.Schema)
+		if err != nil {
+			return fmt.Errorf("параметр %s: %v", p.Name, err)
+		}
+		if typ != "string" && typ != "int" && typ != "int64" {
+			return fmt.Errorf("параметр %s: неподдерживаемый тип %s", p.Name, typ)
+		}
+		params = append(params, param{Parameter: p, field: goName(p.Name), typ: typ})
+	}
+	if len(params) > 0 {
+		g.comment(fmt.Sprintf("%sParams are the parameters of %s.", name, name))
+		fmt.Fprintf(&g.buf, "type %sParams struct {\n", name)
+		for _, p := range params {
+			desc := p.Description
+			if p.Required {
+				desc = strings.TrimSpace("Required. " + desc)
+			}
+			if desc != "" {
+				fmt.Fprintf(&g.buf, "\t// %s\n", desc)
+			}
+			fmt.Fprintf(&g.buf, "\t%s %s\n", p.field, p.typ)
+		}
+		g.buf.WriteString("}\n\n")
+	}
+
+	var bodyType string
+	if e.op.RequestBody != nil {
+		media, ok := e.op.RequestBody.Content["application/json"]
+		if !ok {
+			return fmt.Errorf("тело запроса не в формате JSON")
+		}
+		var err error
+		if bodyType, err = g.goType(media.Schema); err != nil {
+			return err
+		}
+	}
+
+	resultType, err := g.resultType(e.op)
+	if err != nil {
+		return err
+	}
+
+	// Сигнатура метода
+	g.comment(fmt.Sprintf("%s calls %s %s: %s.", name, strings.ToUpper(e.method), e.path, strings.TrimSuffix(e.op.Summary, ".")))
+	if e.op.Description != "" {
+		g.comment("", e.op.Description)
+	}
+	if e.op.RequiredScope != "" {
+		g.comment("", "Required scope: "+e.op.RequiredScope+".")
+	}
+	fmt.Fprintf(&g.buf, "func (c *Client) %s(ctx context.Context", name)
+	g.imports["context"] = true
+	if len(params) > 0 {
+		fmt.Fprintf(&g.buf, ", params %sParams", name)
+	}
+	if bodyType != "" {
+		fmt.Fprintf(&g.buf, ", body %s", bodyType)
+	}
+	switch {
+	case resultType == "":
+		g.buf.WriteString(") error {\n")
+	case strings.HasPrefix(resultType, "[]"):
+		fmt.Fprintf(&g.buf, ") (%s, error) {\n", resultType)
+	default:
+		fmt.Fprintf(&g.buf, ") (*%s, error) {\n", resultType)
+	}
+
+	// Параметры запроса
+	query := "nil"
+	if hasQuery(params) {
+		g.imports["net/url"] = true
+		query = "query"
+		g.buf.WriteString("\tquery := url.Values{}\n")
+		for _, p := range params {
+			if p.In != "query" {
+				continue
+			}
+			value := g.formatValue("params."+p.field, p.typ)
+			if p.Required {
+				fmt.Fprintf(&g.buf, "\tquery.Set(%q, %s)\n", p.Name, value)
+				continue
+			}
+			zero := `""`
+			if p.typ != "string" {
+				zero = "0"
+			}
+			fmt.Fprintf(&g.buf, "\tif params.%s != %s {\n\t\tquery.Set(%q, %s)\n\t}\n", p.field, zero, p.Name, value)
+		}
+	}
+
+	// Путь с подставленными параметрами
+	path, err := g.pathExpr(e.path, params)
+	if err != nil {
+		return err
+	}
+	body := "nil"
+	if bodyType != "" {
+		body = "body"
+	}
+	method := "http.Method" + strings.ToUpper(e.method[:1]) + strings.ToLower(e.method[1:])
+	g.imports["net/http"] = true
+
+	switch {
+	case resultType == "":
+		fmt.Fprintf(&g.buf, "\treturn c.do(ctx, %s, %s, %s, %s, nil)\n}\n\n", method, path, query, body)
+	case strings.HasPrefix(resultType, "[]"):
+		fmt.Fprintf(&g.buf, "\tvar result %s\n\tif err := c.do(ctx, %s, %s, %s, %s, &result); err != nil {\n\t\treturn nil, err\n\t}\n\treturn result, nil\n}\n\n",
+			resultType, method, path, query, body)
+	default:
+		fmt.Fprintf(&g.buf, "\tvar result %s\n\tif err := c.do(ctx, %s, %s, %s, %s, &result); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &result, nil\n}\n\n",
+			resultType, method, path, query, body)
+	}
+	return nil
+}
+
+// resultType возвращает тип JSON-ответа первого успешного кода операции или пустую строку,
+// если успешный ответ не содержит тела.
+func (g *generator) resultType(op Operation) (string, error) {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return "", fmt.Errorf("нет успешного ответа")
+	}
+	sort.Strings(codes)
+	resp, err := g.spec.Response(op.Responses[codes[0]])
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Content) == 0 {
+		return "", nil
+	}
+	media, ok := resp.Content["application/json"]
+	if !ok {
+		return "", fmt.Errorf("ответ %s не в формате JSON", codes[0])
+	}
+	return g.goType(media.Schema)
+}
+
+// hasQuery сообщает, есть ли среди параметров параметры строки запроса.
+func hasQuery(params []param) bool {
+	for _, p := range params {
+		if p.In == "query" {
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue возвращает выражение, приводящее значение параметра к строке.
+func (g *generator) formatValue(expr, typ string) string {
+	switch typ {
+	case "int":
+		g.imports["strconv"] = true
+		return "strconv.Itoa(" + expr + ")"
+	case "int64":
+		g.imports["strconv"] = true
+		return "strconv.FormatInt(" + expr + ", 10)"
+	}
+	return expr
+}
+
+// pathExpr возвращает выражение Go для пути операции с подставленными параметрами пути.
+func (g *generator) pathExpr(path string, params []param) (string, error) {
+	var parts []string
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(path[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("незакрытый параметр пути")
+		}
+		end += start
+		name := path[start+1 : end]
+		var found *param
+		for i := range params {
+			if params[i].In == "path" && params[i].Name == name {
+				found = &params[i]
+			}
+		}
+		if found == nil {
+			return "", fmt.Errorf("параметр пути %s не описан", name)
+		}
+		g.imports["net/url"] = true
+		parts = append(parts, strconv.Quote(path[:start]), "url.PathEscape("+g.formatValue("params."+found.field, found.typ)+")")
+		path = path[end+1:]
+	}
+	if path != "" || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(path))
+	}
+	return strings.Join(parts, " + "), nil
+}
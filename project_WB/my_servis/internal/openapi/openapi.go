@@ -0,0 +1,222 @@
+// Package openapi содержит спецификацию OpenAPI 3 HTTP API сервиса заказов, раздает ее
+// вместе со встроенным просмотрщиком и генерирует по ней типизированный Go-клиент (пакет orderclient).
+//
+// Спецификация openapi.json - источник истины для клиента: после изменения эндпоинтов
+// обновите ее и перегенерируйте клиент командой go generate ./internal/openapi.
+package openapi
+
+//go:generate go run ../../cmd/genclient -out ../../../orderclient/client_gen.go
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"main.go/internal/httpcache"
+)
+
+//go:embed openapi.json docs.html
+var files embed.FS
+
+// specJSON содержимое openapi.json и его ETag.
+var (
+	specJSON, _ = files.ReadFile("openapi.json")
+	specETag    = httpcache.ETag(specJSON)
+)
+
+// JSON возвращает спецификацию в формате JSON.
+func JSON() []byte {
+	return specJSON
+}
+
+// Handler раздает спецификацию; клиенты могут кэшировать ее по ETag.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		httpcache.Write(w, r, specJSON, specETag)
+	})
+}
+
+// DocsHandler раздает страницу просмотра спецификации. Страница загружает /openapi.json
+// и не использует внешние CDN.
+func DocsHandler() http.Handler {
+	page, _ := files.ReadFile("docs.html")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+}
+
+// Spec разобранная спецификация OpenAPI; содержит только используемые сервисом элементы.
+type Spec struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Info сведения о спецификации.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Operation операция над путем.
+type Operation struct {
+	OperationID   string              `json:"operationId"`
+	Summary       string              `json:"summary"`
+	Description   string              `json:"description"`
+	RequiredScope string              `json:"x-required-scope"`
+	GoSkip        bool                `json:"x-go-skip"` // GoSkip исключает операцию из клиента, например поток событий.
+	Parameters    []Parameter         `json:"parameters"`
+	RequestBody   *RequestBody        `json:"requestBody"`
+	Responses     map[string]Response `json:"responses"`
+}
+
+// Parameter параметр операции или ссылка на параметр из components.
+type Parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody тело запроса.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response ответ операции или ссылка на ответ из components.
+type Response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType содержимое запроса или ответа.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema схема JSON-значения.
+type Schema struct {
+	Ref                  string     `json:"$ref"`
+	Type                 string     `json:"type"`
+	Format               string     `json:"format"`
+	Description          string     `json:"description"`
+	Nullable             bool       `json:"nullable"`
+	Enum                 []any      `json:"enum"`
+	Items                *Schema    `json:"items"`
+	Properties           Properties `json:"properties"`
+	AdditionalProperties *Schema    `json:"additionalProperties"`
+	Required             []string   `json:"required"`
+	GoType               string     `json:"x-go-type"` // GoType готовый тип для клиента вместо генерируемой структуры.
+}
+
+// Property свойство объекта.
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// Properties свойства объекта в порядке их объявления в спецификации.
+type Properties []Property
+
+// UnmarshalJSON разбирает объект свойств, сохраняя их порядок.
+func (p *Properties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for dec.More() {
+		name, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var schema Schema
+		if err := dec.Decode(&schema); err != nil {
+			return err
+		}
+		*p = append(*p, Property{Name: name.(string), Schema: &schema})
+	}
+	return nil
+}
+
+// Get возвращает схему свойства name.
+func (p Properties) Get(name string) (*Schema, bool) {
+	for _, prop := range p {
+		if prop.Name == name {
+			return prop.Schema, true
+		}
+	}
+	return nil, false
+}
+
+// Components переиспользуемые элементы спецификации.
+type Components struct {
+	Parameters map[string]Parameter `json:"parameters"`
+	Responses  map[string]Response  `json:"responses"`
+	Schemas    map[string]*Schema   `json:"schemas"`
+}
+
+// Parse разбирает спецификацию.
+func Parse(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("ошибка разбора спецификации: %v", err)
+	}
+	return &spec, nil
+}
+
+// Load возвращает разобранную встроенную спецификацию.
+func Load() (*Spec, error) {
+	return Parse(specJSON)
+}
+
+// refName возвращает имя элемента components по ссылке $ref.
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// Parameter возвращает параметр, разрешая ссылку на components.
+func (s *Spec) Parameter(p Parameter) (Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	resolved, ok := s.Components.Parameters[refName(p.Ref)]
+	if !ok {
+		return p, fmt.Errorf("неизвестный параметр %s", p.Ref)
+	}
+	return resolved, nil
+}
+
+// Response возвращает ответ, разрешая ссылку на components.
+func (s *Spec) Response(r Response) (Response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+	resolved, ok := s.Components.Responses[refName(r.Ref)]
+	if !ok {
+		return r, fmt.Errorf("неизвестный ответ %s", r.Ref)
+	}
+	return resolved, nil
+}
+
+// Schema возвращает схему, разрешая ссылку на components.
+func (s *Spec) Schema(schema *Schema) (*Schema, error) {
+	if schema == nil || schema.Ref == "" {
+		return schema, nil
+	}
+	resolved, ok := s.Components.Schemas[refName(schema.Ref)]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная схема %s", schema.Ref)
+	}
+	return resolved, nil
+}
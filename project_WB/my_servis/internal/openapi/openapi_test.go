@@ -0,0 +1,429 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	config "main.go/internal"
+	"main.go/internal/analytics"
+	"main.go/internal/auth"
+	"main.go/internal/deadletter"
+	"main.go/internal/events"
+	"main.go/internal/handlers"
+	"main.go/internal/outbox"
+	"main.go/internal/ratelimit"
+	cache "main.go/internal/storage/cache"
+	database "main.go/internal/storage/database"
+	"main.go/internal/webhooks"
+)
+
+// routePattern находит в cmd/main.go маршруты API, защищенные правом доступа.
+var routePattern = regexp.MustCompile(`http\.Handle\("(?:([A-Z]+) )?(/[^"]*)", route\(auth\.(\w+),`)
+
+// scopes значения констант прав доступа, используемых в cmd/main.go.
+var scopes = map[string]string{
+	"ScopeOrdersRead":   auth.ScopeOrdersRead,
+	"ScopeOrdersExport": auth.ScopeOrdersExport,
+	"ScopeAdmin":        auth.ScopeAdmin,
+}
+
+func loadSpec(t *testing.T) *Spec {
+	t.Helper()
+	spec, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// TestRoutesMatchSpec сверяет маршруты, зарегистрированные в cmd/main.go, с операциями спецификации:
+// каждый маршрут описан, каждая операция зарегистрирована, и права доступа совпадают.
+func TestRoutesMatchSpec(t *testing.T) {
+	spec := loadSpec(t)
+	src, err := os.ReadFile("../../cmd/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered := map[string]bool{}
+	for _, m := range routePattern.FindAllStringSubmatch(string(src), -1) {
+		method, path, scope := strings.ToLower(m[1]), m[2], scopes[m[3]]
+		if method == "" {
+			method = "get"
+		}
+		registered[method+" "+path] = true
+		op, ok := spec.Paths[path][method]
+		if !ok {
+			t.Errorf("route %s %s is not described in openapi.json", strings.ToUpper(method), path)
+			continue
+		}
+		if op.RequiredScope != scope {
+			t.Errorf("%s %s: x-required-scope %q, route requires %q", strings.ToUpper(method), path, op.RequiredScope, scope)
+		}
+	}
+	if len(registered) == 0 {
+		t.Fatal("no routes found in cmd/main.go")
+	}
+	for path, methods := range spec.Paths {
+		for method, op := range methods {
+			if !registered[method+" "+path] {
+				t.Errorf("operation %s (%s %s) is not registered in cmd/main.go", op.OperationID, strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// TestSchemasMatchTypes сверяет схемы с типами, которые обработчики кодируют в JSON.
+func TestSchemasMatchTypes(t *testing.T) {
+	spec := loadSpec(t)
+	types := map[string]reflect.Type{
+		"Order":             reflect.TypeOf(model.Order{}),
+		"Delivery":          reflect.TypeOf(model.Delivery{}),
+		"Payment":           reflect.TypeOf(model.Payment{}),
+		"Item":              reflect.TypeOf(model.Item{}),
+		"OrderPage":         reflect.TypeOf(handlers.OrderPage{}),
+		"Counters":          reflect.TypeOf(handlers.Counters{}),
+		"BatchGetRequest":   reflect.TypeOf(handlers.BatchGetRequest{}),
+		"BatchGetResult":    reflect.TypeOf(handlers.BatchGetResult{}),
+		"OrdersBucket":      reflect.TypeOf(analytics.OrdersBucket{}),
+		"Basket":            reflect.TypeOf(analytics.Basket{}),
+		"TopEntry":          reflect.TypeOf(analytics.TopEntry{}),
+		"Breakdown":         reflect.TypeOf(analytics.Breakdown{}),
+		"Event":             reflect.TypeOf(events.Event{}),
+		"Subscription":      reflect.TypeOf(webhooks.Subscription{}),
+		"WebhookDelivery":   reflect.TypeOf(webhooks.Delivery{}),
+		"Subject":           reflect.TypeOf(database.Subject{}),
+		"ErasureResult":     reflect.TypeOf(handlers.ErasureResult{}),
+		"SubjectExport":     reflect.TypeOf(database.SubjectExport{}),
+		"UsageReport":       reflect.TypeOf(ratelimit.Report{}),
+		"ClientUsage":       reflect.TypeOf(ratelimit.ClientUsage{}),
+		"RouteUsage":        reflect.TypeOf(ratelimit.RouteUsage{}),
+		"Status":            reflect.TypeOf(handlers.Status{}),
+		"ShardStatus":       reflect.TypeOf(handlers.ShardStatus{}),
+		"OutboxMetrics":     reflect.TypeOf(outbox.Metrics{}),
+		"DeadLetter":        reflect.TypeOf(deadletter.Letter{}),
+		"DeadLetterMessage": reflect.TypeOf(deadletter.Message{}),
+		"EvictRequest":      reflect.TypeOf(handlers.EvictRequest{}),
+		"EvictResult":       reflect.TypeOf(handlers.EvictResult{}),
+		// CreateWebhookRequest - неэкспортируемый тип обработчика, его поля совпадают с webhooks.Subscription.
+		"CreateWebhookRequest": reflect.TypeOf(struct {
+			URL    string   `json:"url"`
+			Events []string `json:"events"`
+			Secret string   `json:"secret"`
+		}{}),
+	}
+	for name, schema := range spec.Components.Schemas {
+		typ, ok := types[name]
+		if !ok {
+			t.Errorf("schema %s has no Go type to check against", name)
+			continue
+		}
+		for _, problem := range compareType(spec, schema, typ, name) {
+			t.Error(problem)
+		}
+	}
+}
+
+var (
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+// compareType сравнивает схему объекта с полями структуры typ.
+func compareType(spec *Spec, schema *Schema, typ reflect.Type, where string) []string {
+	var problems []string
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+		prop, ok := schema.Properties.Get(name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s.%s: field is missing from the schema", where, name))
+			continue
+		}
+		if slices.Contains(schema.Required, name) && strings.Contains(opts, "omitempty") {
+			problems = append(problems, fmt.Sprintf("%s.%s: required in the schema but omitempty in Go", where, name))
+		}
+		if p := compareKind(spec, prop, f.Type); p != "" {
+			problems = append(problems, fmt.Sprintf("%s.%s: %s", where, name, p))
+		}
+	}
+	for _, prop := range schema.Properties {
+		if _, ok := fields[prop.Name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s.%s: property has no Go field", where, prop.Name))
+		}
+	}
+	return problems
+}
+
+// compareKind проверяет, что значение типа typ кодируется в JSON типом схемы.
+func compareKind(spec *Spec, schema *Schema, typ reflect.Type) string {
+	resolved, err := spec.Schema(schema)
+	if err != nil {
+		return err.Error()
+	}
+	var ok bool
+	switch {
+	case typ == timeType:
+		ok = resolved.Type == "string" && resolved.Format == "date-time"
+	case typ.Kind() == reflect.Interface:
+		ok = true
+	case typ.Implements(marshalerType):
+		ok = resolved.Type != "object" // собственная сериализация (UnixTime) проверяется в TestHandlersMatchSpec
+	case resolved.Type == "object":
+		ok = typ.Kind() == reflect.Struct
+	case resolved.Type == "array":
+		if typ.Kind() != reflect.Slice {
+			break
+		}
+		return compareKind(spec, resolved.Items, typ.Elem())
+	case resolved.Type == "string":
+		ok = typ.Kind() == reflect.String
+	case resolved.Type == "integer":
+		ok = typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64
+	case resolved.Type == "number":
+		ok = typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64
+	case resolved.Type == "boolean":
+		ok = typ.Kind() == reflect.Bool
+	}
+	if !ok {
+		return fmt.Sprintf("Go type %s does not match schema type %s", typ, resolved.Type)
+	}
+	return ""
+}
+
+// TestHandlersMatchSpec выполняет запросы к обработчикам, работающим без базы данных,
+// и проверяет, что коды ответов описаны в спецификации, а тела ответов соответствуют схемам.
+func TestHandlersMatchSpec(t *testing.T) {
+	spec := loadSpec(t)
+	cache.InitCache()
+	cache.SetCache(map[string]model.Order{
+		"order_1": testOrder("order_1", "track_1"),
+		"order_2": testOrder("order_2", "track_2"),
+	})
+
+	keys, err := auth.NewStaticKeys([]config.APIKeyConfig{
+		{Name: "reader", Key: "reader-key", Scopes: []string{auth.ScopeOrdersRead}},
+		{Name: "nobody", Key: "nobody-key", Scopes: []string{auth.ScopeOrdersExport}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authz := auth.NewAuthorizer(auth.Chain{keys})
+	mux := http.NewServeMux()
+	handle := func(method, path string, h http.Handler) {
+		mux.Handle(strings.ToUpper(method)+" "+path, authz.Require(spec.Paths[path][method].RequiredScope, h))
+	}
+	handle("get", "/order", http.HandlerFunc(handlers.GetOrderFromCache))
+	handle("get", "/api/v1/orders", http.HandlerFunc(handlers.ListOrders))
+	handle("get", "/api/v1/counters", http.HandlerFunc(handlers.GetCounters))
+	handle("post", "/api/v1/orders:batchGet", handlers.BatchGetOrders(nil))
+
+	tests := []struct {
+		method, path, target string
+		key, accept, body    string
+		want                 int
+	}{
+		{"get", "/order", "/order?id=order_1", "reader-key", "", "", 200},
+		{"get", "/order", "/order?id=order_1&view=summary", "reader-key", "", "", 200},
+		{"get", "/order", "/order?id=order_1&fields=payment.payment_dt,items.brand", "reader-key", "", "", 200},
+		{"get", "/order", "/order?id=order_1", "reader-key", "application/x-protobuf", "", 200},
+		{"get", "/order", "/order", "reader-key", "", "", 400},
+		{"get", "/order", "/order?id=order_1&view=unknown", "reader-key", "", "", 400},
+		{"get", "/order", "/order?id=missing", "reader-key", "", "", 404},
+		{"get", "/order", "/order?id=order_1", "reader-key", "text/xml", "", 406},
+		{"get", "/order", "/order?id=order_1", "", "", "", 401},
+		{"get", "/order", "/order?id=order_1", "nobody-key", "", "", 403},
+		{"get", "/api/v1/orders", "/api/v1/orders", "reader-key", "", "", 200},
+		{"get", "/api/v1/orders", "/api/v1/orders?q=track_2&view=public", "reader-key", "", "", 200},
+		{"get", "/api/v1/orders", "/api/v1/orders?size=1&page=2&fields=order_uid", "reader-key", "", "", 200},
+		{"get", "/api/v1/orders", "/api/v1/orders?page=0", "reader-key", "", "", 400},
+		{"get", "/api/v1/counters", "/api/v1/counters", "reader-key", "", "", 200},
+		{"post", "/api/v1/orders:batchGet", "/api/v1/orders:batchGet", "reader-key", "", `{"ids":["track_2","order_1"],"view":"summary"}`, 200},
+		{"post", "/api/v1/orders:batchGet", "/api/v1/orders:batchGet", "reader-key", "", `{"ids":[]}`, 400},
+		{"post", "/api/v1/orders:batchGet", "/api/v1/orders:batchGet", "reader-key", "", `{"ids":["order_1"],"view":"summary","fields":["order_uid"]}`, 400},
+	}
+	for _, tt := range tests {
+		name := strings.ToUpper(tt.method) + " " + tt.target
+		r := httptest.NewRequest(strings.ToUpper(tt.method), tt.target, strings.NewReader(tt.body))
+		if tt.key != "" {
+			r.Header.Set("X-API-Key", tt.key)
+		}
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", name, w.Code, tt.want)
+			continue
+		}
+		for _, problem := range checkResponse(spec, spec.Paths[tt.path][tt.method], w) {
+			t.Errorf("%s: %s", name, problem)
+		}
+	}
+}
+
+// checkResponse проверяет, что ответ описан в операции: код, тип содержимого и тело по схеме.
+func checkResponse(spec *Spec, op Operation, w *httptest.ResponseRecorder) []string {
+	code := fmt.Sprint(w.Code)
+	ref, ok := op.Responses[code]
+	if !ok {
+		return []string{"status " + code + " is not documented"}
+	}
+	resp, err := spec.Response(ref)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	contentType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if len(resp.Content) == 0 {
+		if w.Body.Len() > 0 {
+			return []string{"status " + code + " is documented without a body"}
+		}
+		return nil
+	}
+	media, ok := resp.Content[contentType]
+	if !ok {
+		return []string{fmt.Sprintf("content type %q is not documented for status %s", contentType, code)}
+	}
+	if contentType != "application/json" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(w.Body.Bytes()))
+	dec.UseNumber()
+	var body any
+	if err := dec.Decode(&body); err != nil {
+		return []string{"invalid JSON: " + err.Error()}
+	}
+	return validate(spec, media.Schema, body, "$")
+}
+
+// validate проверяет JSON-значение по схеме. Свойства, которых нет в схеме, считаются ошибкой,
+// чтобы новые поля ответов не появлялись без описания.
+func validate(spec *Spec, schema *Schema, v any, path string) []string {
+	s, err := spec.Schema(schema)
+	if err != nil {
+		return []string{path + ": " + err.Error()}
+	}
+	if v == nil {
+		if s.Nullable {
+			return nil
+		}
+		return []string{path + ": null is not allowed"}
+	}
+	var problems []string
+	mismatch := func() []string {
+		return []string{fmt.Sprintf("%s: %T does not match type %s", path, v, s.Type)}
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, path+"."+name+": required property is missing")
+			}
+		}
+		for name, value := range obj {
+			prop, ok := s.Properties.Get(name)
+			if !ok {
+				problems = append(problems, path+"."+name+": property is not described")
+				continue
+			}
+			problems = append(problems, validate(spec, prop, value, path+"."+name)...)
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return mismatch()
+		}
+		for i, item := range arr {
+			problems = append(problems, validate(spec, s.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return mismatch()
+		}
+		switch s.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				problems = append(problems, path+": invalid date-time")
+			}
+		case "date":
+			if _, err := time.Parse(time.DateOnly, str); err != nil {
+				problems = append(problems, path+": invalid date")
+			}
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, any(str)) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %v", path, str, s.Enum))
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return mismatch()
+		}
+		if _, err := n.Int64(); err != nil {
+			problems = append(problems, path+": not an integer")
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return mismatch()
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch()
+		}
+	}
+	return problems
+}
+
+// testOrder возвращает заполненный заказ для запросов к обработчикам.
+func testOrder(uid, track string) model.Order {
+	return model.Order{
+		SchemaVersion: model.CurrentSchemaVersion,
+		OrderUID:      uid,
+		TrackNumber:   track,
+		Entry:         "WBIL",
+		Delivery:      model.Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Email: "test@gmail.com"},
+		Payment:       model.Payment{Transaction: uid, Currency: "USD", Amount: 1817, PaymentDT: model.NewUnixTime(1637907727)},
+		Items:         []model.Item{{ChrtID: 9934930, TrackNumber: track, Price: 453, Name: "Mascaras", Brand: "Vivienne Sabo", Status: 202}},
+		CustomerID:    "test",
+		DateCreated:   time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+}
+
+// TestClientUpToDate проверяет, что клиент orderclient сгенерирован по текущей спецификации.
+func TestClientUpToDate(t *testing.T) {
+	want, err := GenerateClient(loadSpec(t), "orderclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../../../orderclient/client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("orderclient/client_gen.go is out of date, run go generate ./internal/openapi")
+	}
+}
@@ -0,0 +1,202 @@
+package outbox
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"main.go/internal/pii"
+)
+
+const (
+	batchSize       = 100                // batchSize количество событий, публикуемых за один проход.
+	pollInterval    = time.Second        // pollInterval период опроса исходящей очереди.
+	cleanupInterval = time.Hour          // cleanupInterval период удаления опубликованных событий.
+	sentRetention   = 7 * 24 * time.Hour // sentRetention срок хранения опубликованных событий.
+	maxAttempts     = 5                  // maxAttempts количество неудачных попыток подготовить событие до его откладывания.
+)
+
+// Счетчики публикации с момента запуска сервиса.
+var (
+	publishedTotal atomic.Int64 // количество опубликованных событий
+	errorsTotal    atomic.Int64 // количество ошибок публикации
+)
+
+// Metrics метрики исходящих очередей всех баз данных для административного API.
+type Metrics struct {
+	Pending             int64   `json:"pending"`
+	OldestPendingAgeSec float64 `json:"oldest_pending_age_seconds"`
+	Failed              int64   `json:"failed"`
+	PublishedTotal      int64   `json:"published_total"`
+	PublishErrorsTotal  int64   `json:"publish_errors_total"`
+}
+
+// backlog состояние исходящей очереди одной базы данных.
+type backlog struct {
+	pending int64
+	oldest  float64
+	failed  int64
+}
+
+// backlogs состояние очередей по базам данных: при шардировании каждый шард публикует свои события,
+// а метрики показывают сумму ожидающих событий и возраст самого старого из них.
+var (
+	backlogs     = make(map[*sql.DB]backlog)
+	backlogsLock sync.Mutex
+)
+
+// Stats возвращает метрики исходящих очередей всех баз данных.
+func Stats() Metrics {
+	backlogsLock.Lock()
+	defer backlogsLock.Unlock()
+	m := Metrics{PublishedTotal: publishedTotal.Load(), PublishErrorsTotal: errorsTotal.Load()}
+	for _, b := range backlogs {
+		m.Pending += b.pending
+		m.Failed += b.failed
+		m.OldestPendingAgeSec = max(m.OldestPendingAgeSec, b.oldest)
+	}
+	return m
+}
+
+// RunRelay публикует события из исходящей очереди в subject JetStream и отмечает их отправленными.
+// Не возвращает управление.
+func RunRelay(db *sql.DB, js nats.JetStreamContext, subject string) {
+	var lastCleanup time.Time
+	for {
+		n, err := relayBatch(db, js, subject)
+		if err != nil {
+			errorsTotal.Add(1)
+			fmt.Println("Ошибка публикации событий из outbox:", err)
+		}
+		if err := updateBacklog(db); err != nil {
+			fmt.Println("Ошибка обновления метрик outbox:", err)
+		}
+		if time.Since(lastCleanup) > cleanupInterval {
+			if _, err := db.Exec(`DELETE FROM outbox WHERE sent_at < $1`, time.Now().Add(-sentRetention)); err != nil {
+				fmt.Println("Ошибка очистки outbox:", err)
+			}
+			lastCleanup = time.Now()
+		}
+		if n < batchSize {
+			time.Sleep(pollInterval)
+		}
+	}
+}
+
+// relayBatch публикует одну порцию событий и возвращает количество опубликованных.
+// Заголовок Nats-Msg-Id позволяет JetStream отбросить дубликаты, если после публикации
+// сервис упадет до фиксации отметки об отправке. Событие, которое не удалось подготовить
+// maxAttempts раз подряд (например, после ротации ключей шифрования), откладывается с текстом
+// ошибки и больше не задерживает следующие события.
+func relayBatch(db *sql.DB, js nats.JetStreamContext, subject string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, msg_id, payload, attempts
+		FROM outbox
+		WHERE sent_at IS NULL AND failed_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching outbox: %v", err)
+	}
+
+	type event struct {
+		id       int64
+		msgID    string
+		payload  []byte
+		attempts int
+	}
+	var events []event
+	for rows.Next() {
+		var e event
+		if err := rows.Scan(&e.id, &e.msgID, &e.payload, &e.attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning outbox row: %v", err)
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error fetching outbox: %v", err)
+	}
+
+	var sent []int64
+	var publishErr error
+	changed := false
+	for _, e := range events {
+		// Персональные данные заказа хранятся в очереди зашифрованными
+		data, err := pii.DecryptEnvelope(e.payload, "order")
+		if err != nil {
+			publishErr = fmt.Errorf("ошибка подготовки события %s: %v", e.msgID, err)
+			parked := e.attempts+1 >= maxAttempts
+			if _, err := tx.Exec(`
+				UPDATE outbox SET attempts = attempts + 1, last_error = $2,
+					failed_at = CASE WHEN $3::boolean THEN now() END
+				WHERE id = $1`, e.id, publishErr.Error(), parked); err != nil {
+				return 0, fmt.Errorf("ошибка отметки неудачной попытки события %s: %v", e.msgID, err)
+			}
+			changed = true
+			if parked {
+				fmt.Println("Событие outbox отложено после", maxAttempts, "неудачных попыток:", e.msgID)
+				continue
+			}
+			// Сохраняем порядок: событие будет подготовлено повторно в следующем проходе.
+			break
+		}
+
+		msg := nats.NewMsg(subject)
+		msg.Header.Set(nats.MsgIdHdr, e.msgID)
+		msg.Header.Set("Content-Type", "application/json")
+		msg.Data = data
+		if _, err := js.PublishMsg(msg); err != nil {
+			// Сохраняем порядок: остальные события будут опубликованы в следующем проходе.
+			publishErr = fmt.Errorf("ошибка публикации события %s: %v", e.msgID, err)
+			break
+		}
+		sent = append(sent, e.id)
+	}
+
+	if len(sent) > 0 {
+		if _, err := tx.Exec(`UPDATE outbox SET sent_at = now() WHERE id = ANY($1)`, sent); err != nil {
+			return 0, fmt.Errorf("ошибка отметки событий outbox: %v", err)
+		}
+		changed = true
+	}
+	if changed {
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+		publishedTotal.Add(int64(len(sent)))
+	}
+	return len(sent), publishErr
+}
+
+// updateBacklog обновляет метрики размера и возраста исходящей очереди.
+func updateBacklog(db *sql.DB) error {
+	var b backlog
+	var oldest sql.NullFloat64
+	err := db.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE failed_at IS NULL),
+			EXTRACT(EPOCH FROM now() - MIN(created_at) FILTER (WHERE failed_at IS NULL)),
+			COUNT(*) FILTER (WHERE failed_at IS NOT NULL)
+		FROM outbox
+		WHERE sent_at IS NULL`).Scan(&b.pending, &oldest, &b.failed)
+	if err != nil {
+		return err
+	}
+	b.oldest = oldest.Float64
+
+	backlogsLock.Lock()
+	defer backlogsLock.Unlock()
+	backlogs[db] = b
+	return nil
+}
@@ -0,0 +1,72 @@
+package pii
+
+import (
+	"strings"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+// MaskPhone скрывает середину номера телефона, оставляя по два символа в начале и в конце.
+func MaskPhone(phone string) string {
+	return maskMiddle(phone, 2, 2)
+}
+
+// MaskEmail скрывает имя почтового ящика, оставляя его первый символ и домен.
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return maskMiddle(email, 2, 2)
+	}
+	local := []rune(email[:at])
+	return string(local[0]) + "***" + email[at:]
+}
+
+// maskMiddle заменяет символы между первыми head и последними tail символами на '*'.
+// Слишком короткое значение скрывается целиком.
+func maskMiddle(value string, head, tail int) string {
+	runes := []rune(value)
+	if len(runes) <= head+tail {
+		return strings.Repeat("*", len(runes))
+	}
+	for i := head; i < len(runes)-tail; i++ {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
+
+// Mask возвращает копию заказа с замаскированными телефоном и email получателя.
+func Mask(order model.Order) model.Order {
+	order.Delivery.Phone = MaskPhone(order.Delivery.Phone)
+	order.Delivery.Email = MaskEmail(order.Delivery.Email)
+	return order
+}
+
+// Redact возвращает копию заказа, в которой скрыты все персональные данные получателя.
+// Используется для вывода, который может попасть в журналы.
+func Redact(order model.Order) model.Order {
+	order = Mask(order)
+	order.Delivery.Name = maskMiddle(order.Delivery.Name, 1, 0)
+	order.Delivery.Address = maskMiddle(order.Delivery.Address, 0, 0)
+	return order
+}
+
+// ForReader возвращает заказ в том виде, в каком его можно выдать клиенту: с правом на чтение
+// персональных данных (full) - полностью, остальным - с замаскированными телефоном и email.
+func ForReader(full bool, order model.Order) model.Order {
+	if full {
+		return order
+	}
+	return Mask(order)
+}
+
+// ForReaderAll применяет ForReader к списку заказов, не изменяя исходный срез.
+func ForReaderAll(full bool, orders []model.Order) []model.Order {
+	if full {
+		return orders
+	}
+	masked := make([]model.Order, len(orders))
+	for i, order := range orders {
+		masked[i] = Mask(order)
+	}
+	return masked
+}
@@ -0,0 +1,402 @@
+// Package pii шифрует персональные данные получателя (имя, телефон, email, адрес) и маскирует их при выводе.
+//
+// Используется конвертное шифрование: каждое значение шифруется собственным случайным ключом данных
+// (AES-256-GCM), а ключ данных - ключом шифрования ключей из файла ключей. Зашифрованное значение
+// самодостаточно и имеет вид enc:v1:<id ключа>:<ключ данных>:<данные>, поэтому при ротации
+// достаточно перешифровать ключи данных новым ключом, не трогая сами данные.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	config "main.go/internal"
+)
+
+// prefix признак зашифрованного значения.
+const prefix = "enc:v1:"
+
+// ErrNoKeyring возвращается при попытке расшифровать значение, когда файл ключей не загружен.
+var ErrNoKeyring = errors.New("файл ключей шифрования персональных данных не загружен")
+
+// Keyring ключи шифрования ключей. Новые значения шифруются основным ключом,
+// остальные ключи нужны для чтения значений, зашифрованных до ротации.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// keyFile формат файла ключей:
+//
+//	{"primary": "2024-10", "keys": {"2024-01": "<base64, 32 байта>", "2024-10": "<base64, 32 байта>"}}
+type keyFile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// LoadKeyring загружает ключи из файла.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла ключей: %v", err)
+	}
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("ошибка разбора файла ключей: %v", err)
+	}
+	k := &Keyring{primary: f.Primary, keys: make(map[string]cipher.AEAD, len(f.Keys))}
+	for id, encoded := range f.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("некорректный идентификатор ключа %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("ключ %q должен быть 32 байтами в base64", id)
+		}
+		if k.keys[id], err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := k.keys[k.primary]; !ok {
+		return nil, fmt.Errorf("основной ключ %q отсутствует в файле ключей", k.primary)
+	}
+	return k, nil
+}
+
+// newAEAD создает AES-256-GCM для ключа.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal шифрует plaintext, добавляя случайный nonce в начало результата.
+func seal(aead cipher.AEAD, plaintext, additional []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional)
+}
+
+// open расшифровывает результат seal.
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("слишком короткий шифротекст")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+}
+
+// IsEncrypted сообщает, зашифровано ли значение.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt шифрует значение основным ключом. Пустые и уже зашифрованные значения не меняются.
+func (k *Keyring) Encrypt(value string) string {
+	if value == "" || IsEncrypted(value) {
+		return value
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		panic(err)
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		panic(err)
+	}
+	return k.format(k.primary, dek, seal(aead, []byte(value), nil))
+}
+
+// format собирает зашифрованное значение из ключа данных, зашифрованного ключом id, и данных.
+func (k *Keyring) format(id string, dek, data []byte) string {
+	wrapped := seal(k.keys[id], dek, []byte(id))
+	return prefix + id + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + base64.RawStdEncoding.EncodeToString(data)
+}
+
+// parse разбирает зашифрованное значение и возвращает идентификатор ключа, ключ данных и данные.
+func (k *Keyring) parse(value string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("некорректный формат зашифрованного значения")
+	}
+	id := parts[0]
+	kek, ok := k.keys[id]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("неизвестный ключ %q", id)
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, errors.New("некорректный формат зашифрованного значения")
+	}
+	data, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, errors.New("некорректный формат зашифрованного значения")
+	}
+	dek, err := open(kek, wrapped, []byte(id))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("ошибка расшифровки ключа данных: %v", err)
+	}
+	return id, dek, data, nil
+}
+
+// Decrypt расшифровывает значение. Незашифрованные значения, сохраненные до включения шифрования,
+// возвращаются как есть.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	_, dek, data, err := k.parse(value)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("ошибка расшифровки значения: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap перешифровывает ключ данных значения основным ключом; незашифрованное значение шифруется.
+// Второй результат сообщает, изменилось ли значение.
+func (k *Keyring) Rewrap(value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if !IsEncrypted(value) {
+		return k.Encrypt(value), true, nil
+	}
+	id, dek, data, err := k.parse(value)
+	if err != nil {
+		return "", false, err
+	}
+	if id == k.primary {
+		return value, false, nil
+	}
+	return k.format(k.primary, dek, data), true, nil
+}
+
+var (
+	keyring     *Keyring
+	keyringLock sync.RWMutex
+)
+
+// SetKeyring задает ключи, которыми шифруются персональные данные. Без ключей данные хранятся открыто.
+func SetKeyring(k *Keyring) {
+	keyringLock.Lock()
+	defer keyringLock.Unlock()
+	keyring = k
+}
+
+// current возвращает заданные ключи или nil.
+func current() *Keyring {
+	keyringLock.RLock()
+	defer keyringLock.RUnlock()
+	return keyring
+}
+
+// Setup загружает файл ключей из конфигурации.
+// Без файла ключей новые персональные данные хранятся открыто, а зашифрованные прочитать нельзя.
+func Setup(cfg config.PIIConfig) error {
+	if cfg.KeyFile == "" {
+		SetKeyring(nil)
+		return nil
+	}
+	k, err := LoadKeyring(cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+	SetKeyring(k)
+	return nil
+}
+
+// Enabled сообщает, загружены ли ключи шифрования.
+func Enabled() bool {
+	return current() != nil
+}
+
+// piiFields поля доставки с персональными данными. Город, индекс и регион не шифруются:
+// по ним строится статистика и поиск.
+func piiFields(d *model.Delivery) []*string {
+	return []*string{&d.Name, &d.Phone, &d.Address, &d.Email}
+}
+
+// EncryptDelivery возвращает копию доставки с зашифрованными персональными данными.
+func EncryptDelivery(d model.Delivery) model.Delivery {
+	k := current()
+	if k == nil {
+		return d
+	}
+	for _, field := range piiFields(&d) {
+		*field = k.Encrypt(*field)
+	}
+	return d
+}
+
+// DecryptDelivery расшифровывает персональные данные доставки на месте.
+func DecryptDelivery(d *model.Delivery) error {
+	k := current()
+	for _, field := range piiFields(d) {
+		if !IsEncrypted(*field) {
+			continue
+		}
+		if k == nil {
+			return ErrNoKeyring
+		}
+		plaintext, err := k.Decrypt(*field)
+		if err != nil {
+			return err
+		}
+		*field = plaintext
+	}
+	return nil
+}
+
+// RewrapDelivery перешифровывает персональные данные доставки основным ключом.
+// Второй результат сообщает, изменилось ли хотя бы одно поле.
+func RewrapDelivery(d *model.Delivery) (bool, error) {
+	k := current()
+	if k == nil {
+		return false, ErrNoKeyring
+	}
+	changed := false
+	for _, field := range piiFields(d) {
+		value, ok, err := k.Rewrap(*field)
+		if err != nil {
+			return false, err
+		}
+		*field = value
+		changed = changed || ok
+	}
+	return changed, nil
+}
+
+// EncryptDocument шифрует персональные данные в объекте delivery JSON-документа заказа.
+// Остальные поля документа, в том числе неизвестные модели, сохраняются без изменений.
+func EncryptDocument(document []byte) ([]byte, error) {
+	if current() == nil {
+		return document, nil
+	}
+	return transformDocument(document, func(d *model.Delivery) (bool, error) {
+		*d = EncryptDelivery(*d)
+		return true, nil
+	})
+}
+
+// DecryptDocument расшифровывает персональные данные в объекте delivery JSON-документа заказа.
+func DecryptDocument(document []byte) ([]byte, error) {
+	return transformDocument(document, func(d *model.Delivery) (bool, error) {
+		for _, field := range piiFields(d) {
+			if IsEncrypted(*field) {
+				return true, DecryptDelivery(d)
+			}
+		}
+		return false, nil
+	})
+}
+
+// DecryptEnvelope расшифровывает персональные данные заказа, вложенного в поле field JSON-события.
+// События хранятся в исходящих очередях с зашифрованными данными и расшифровываются перед отправкой.
+func DecryptEnvelope(payload []byte, field string) ([]byte, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("ошибка разбора события: %v", err)
+	}
+	order, ok := envelope[field]
+	if !ok {
+		return payload, nil
+	}
+	decrypted, err := DecryptDocument(order)
+	if err != nil {
+		return nil, err
+	}
+	envelope[field] = decrypted
+	return json.Marshal(envelope)
+}
+
+// EncryptMessage шифрует сообщение целиком. Так хранятся сообщения, которые не удалось разобрать:
+// найти в них персональные данные по полям нельзя. Без ключей сообщение не меняется.
+func EncryptMessage(data []byte) []byte {
+	k := current()
+	if k == nil {
+		return data
+	}
+	return []byte(k.Encrypt(string(data)))
+}
+
+// DecryptMessage расшифровывает сообщение, зашифрованное EncryptMessage.
+func DecryptMessage(data []byte) ([]byte, error) {
+	if !IsEncrypted(string(data)) {
+		return data, nil
+	}
+	k := current()
+	if k == nil {
+		return nil, ErrNoKeyring
+	}
+	plaintext, err := k.Decrypt(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(plaintext), nil
+}
+
+// RewrapDocument перешифровывает персональные данные документа основным ключом.
+func RewrapDocument(document []byte) ([]byte, bool, error) {
+	changed := false
+	result, err := transformDocument(document, func(d *model.Delivery) (bool, error) {
+		var err error
+		changed, err = RewrapDelivery(d)
+		return changed, err
+	})
+	return result, changed, err
+}
+
+// transformDocument применяет fn к полям персональных данных объекта delivery документа.
+func transformDocument(document []byte, fn func(d *model.Delivery) (bool, error)) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, fmt.Errorf("ошибка разбора документа заказа: %v", err)
+	}
+	raw, ok := doc["delivery"]
+	if !ok {
+		return document, nil
+	}
+	var delivery map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &delivery); err != nil {
+		return nil, fmt.Errorf("ошибка разбора доставки: %v", err)
+	}
+
+	fields := map[string]*string{}
+	var d model.Delivery
+	for name, field := range map[string]*string{"name": &d.Name, "phone": &d.Phone, "address": &d.Address, "email": &d.Email} {
+		if value, ok := delivery[name]; ok && json.Unmarshal(value, field) == nil {
+			fields[name] = field
+		}
+	}
+	changed, err := fn(&d)
+	if err != nil || !changed {
+		return document, err
+	}
+	for name, field := range fields {
+		delivery[name], _ = json.Marshal(*field)
+	}
+	if doc["delivery"], err = json.Marshal(delivery); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
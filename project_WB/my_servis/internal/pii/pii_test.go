@@ -0,0 +1,109 @@
+package pii
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+// writeKeyFile создает файл ключей с основным ключом primary.
+func writeKeyFile(t *testing.T, primary string, ids ...string) string {
+	t.Helper()
+	keys := make(map[string]string, len(ids))
+	for i, id := range ids {
+		keys[id] = base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune('a'+i)), 32)))
+	}
+	data, err := json.Marshal(keyFile{Primary: primary, Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncryptRotateDecrypt(t *testing.T) {
+	old, err := LoadKeyring(writeKeyFile(t, "k1", "k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := LoadKeyring(writeKeyFile(t, "k2", "k1", "k2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := old.Encrypt("+9720000000")
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "9720000000") {
+		t.Fatalf("Encrypt returned %q", sealed)
+	}
+	rewrapped, changed, err := rotated.Rewrap(sealed)
+	if err != nil || !changed || !strings.HasPrefix(rewrapped, prefix+"k2:") {
+		t.Fatalf("Rewrap = %q, %v, %v", rewrapped, changed, err)
+	}
+	if got, err := rotated.Decrypt(rewrapped); err != nil || got != "+9720000000" {
+		t.Errorf("Decrypt after rotation = %q, %v", got, err)
+	}
+	if _, err := old.Decrypt(rewrapped); err == nil {
+		t.Error("Decrypt with a keyring missing the new key returned no error")
+	}
+	if got, err := rotated.Decrypt("plain"); err != nil || got != "plain" {
+		t.Errorf("Decrypt of a legacy value = %q, %v", got, err)
+	}
+}
+
+func TestEncryptDocumentKeepsUnknownFields(t *testing.T) {
+	k, err := LoadKeyring(writeKeyFile(t, "k1", "k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(k)
+	defer SetKeyring(nil)
+
+	document := []byte(`{"order_uid":"1","delivery":{"name":"Test Testov","phone":"+9720000000","city":"Kiryat Mozkin","email":"test@gmail.com","floor":3}}`)
+	encrypted, err := EncryptDocument(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, raw := range []string{"Test Testov", "9720000000", "test@gmail.com"} {
+		if strings.Contains(string(encrypted), raw) {
+			t.Errorf("encrypted document contains %q: %s", raw, encrypted)
+		}
+	}
+	if !strings.Contains(string(encrypted), `"floor":3`) || !strings.Contains(string(encrypted), "Kiryat Mozkin") {
+		t.Errorf("encrypted document lost fields: %s", encrypted)
+	}
+
+	again, err := EncryptDocument(encrypted)
+	if err != nil || string(again) != string(encrypted) {
+		t.Errorf("EncryptDocument is not idempotent: %s", again)
+	}
+
+	var order model.Order
+	if err := json.Unmarshal(encrypted, &order); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptDelivery(&order.Delivery); err != nil {
+		t.Fatal(err)
+	}
+	if order.Delivery.Name != "Test Testov" || order.Delivery.Phone != "+9720000000" || order.Delivery.Email != "test@gmail.com" {
+		t.Errorf("DecryptDelivery = %+v", order.Delivery)
+	}
+}
+
+func TestMask(t *testing.T) {
+	order := model.Order{Delivery: model.Delivery{Phone: "+9720000000", Email: "test@gmail.com"}}
+	masked := ForReader(false, order)
+	if masked.Delivery.Phone != "+9*******00" || masked.Delivery.Email != "t***@gmail.com" {
+		t.Errorf("masked delivery = %+v", masked.Delivery)
+	}
+	if full := ForReader(true, order); full.Delivery != order.Delivery {
+		t.Errorf("full reader got %+v", full.Delivery)
+	}
+}
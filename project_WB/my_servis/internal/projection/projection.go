@@ -0,0 +1,290 @@
+// Package projection отбирает поля заказа для ответов API.
+//
+// Поле задается путем из имен JSON через точку: track_number, delivery.city, items.brand.
+// Путь внутри массива применяется к каждому его элементу. Вместо списка полей можно выбрать
+// именованное представление: summary, full или public.
+package projection
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+// node дерево выбранных полей; nil означает поле целиком.
+type node map[string]node
+
+// orderSchema дерево всех полей заказа для проверки путей.
+var orderSchema = schemaOf(reflect.TypeOf(model.Order{}))
+
+// marshalerType тип json.Marshaler: значения с собственной сериализацией отбираются целиком.
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// schemaOf строит дерево полей по тегам json структуры.
+func schemaOf(t reflect.Type) node {
+	for t.Kind() == reflect.Slice || t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return nil
+	}
+	n := node{}
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if name == "" {
+			continue
+		}
+		n[name] = schemaOf(t.Field(i).Type)
+	}
+	return n
+}
+
+// jsonName возвращает имя поля структуры в JSON или пустую строку для пропускаемых полей.
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" || !f.IsExported() {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// Именованные представления заказа.
+const (
+	ViewSummary = "summary" // ViewSummary краткие сведения для списков и дашбордов.
+	ViewFull    = "full"    // ViewFull заказ целиком.
+	ViewPublic  = "public"  // ViewPublic заказ без персональных данных и служебных полей.
+)
+
+// views поля именованных представлений; nil - заказ целиком.
+var views = map[string][]string{
+	ViewSummary: {"order_uid", "track_number", "customer_id", "delivery_service", "date_created",
+		"delivery.city", "payment.amount", "payment.currency"},
+	ViewFull: nil,
+	ViewPublic: {"order_uid", "track_number", "entry", "locale", "delivery_service", "date_created",
+		"delivery.city", "delivery.region",
+		"payment.currency", "payment.amount", "payment.payment_dt", "payment.delivery_cost", "payment.goods_total", "payment.custom_fee",
+		"items.chrt_id", "items.nm_id", "items.name", "items.brand", "items.size", "items.price", "items.sale", "items.total_price", "items.status"},
+}
+
+var (
+	// ErrUnknownView возвращается для неизвестного имени представления.
+	ErrUnknownView = errors.New("неизвестное представление")
+	// ErrViewAndFields возвращается, если заданы одновременно представление и список полей.
+	ErrViewAndFields = errors.New("нельзя задать одновременно view и fields")
+)
+
+// View возвращает проекцию именованного представления.
+func View(name string) (*Projection, error) {
+	fields, ok := views[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownView, name)
+	}
+	return Parse(fields)
+}
+
+// Select строит проекцию по представлению view или списку полей fields; задать можно только одно из них.
+// Без view и fields заказ возвращается целиком.
+func Select(view string, fields []string) (*Projection, error) {
+	if view != "" && len(fields) > 0 {
+		return nil, ErrViewAndFields
+	}
+	if view != "" {
+		return View(view)
+	}
+	return Parse(fields)
+}
+
+// FromRequest строит проекцию по параметрам запроса view и fields (поля через запятую).
+func FromRequest(r *http.Request) (*Projection, error) {
+	var fields []string
+	if s := strings.TrimSpace(r.URL.Query().Get("fields")); s != "" {
+		fields = strings.Split(s, ",")
+	}
+	return Select(r.URL.Query().Get("view"), fields)
+}
+
+// Projection набор полей заказа, которые попадают в ответ. Пустая проекция (nil) оставляет заказ целиком.
+type Projection struct {
+	fields node
+}
+
+// Parse проверяет пути полей и строит проекцию. Для пустого списка возвращает nil.
+func Parse(fields []string) (*Projection, error) {
+	tree := node{}
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		parts := strings.Split(field, ".")
+		schema := orderSchema
+		for _, part := range parts {
+			child, ok := schema[part]
+			if !ok {
+				return nil, fmt.Errorf("неизвестное поле %q", field)
+			}
+			schema = child
+		}
+		tree.insert(parts)
+	}
+	if len(tree) == 0 {
+		return nil, nil
+	}
+	return &Projection{fields: tree}, nil
+}
+
+// insert добавляет путь в дерево. Выбранное целиком поле поглощает свои вложенные поля.
+func (n node) insert(parts []string) {
+	cur := n
+	for i, part := range parts {
+		child, ok := cur[part]
+		if ok && child == nil {
+			return
+		}
+		if i == len(parts)-1 {
+			cur[part] = nil
+			return
+		}
+		if !ok {
+			child = node{}
+			cur[part] = child
+		}
+		cur = child
+	}
+}
+
+// Apply возвращает заказ, ограниченный выбранными полями. Поля отбираются до сериализации:
+// результат кодируется в JSON без невыбранных полей в порядке их объявления в модели.
+func (p *Projection) Apply(order model.Order) any {
+	if p == nil {
+		return order
+	}
+	return p.fields.pick(reflect.ValueOf(order))
+}
+
+// ApplyAll применяет проекцию к каждому заказу.
+func (p *Projection) ApplyAll(orders []model.Order) []any {
+	result := make([]any, len(orders))
+	for i, order := range orders {
+		result[i] = p.Apply(order)
+	}
+	return result
+}
+
+// Prune возвращает копию заказа, в которой невыбранные поля обнулены. Используется для форматов
+// без разреженного представления, например protobuf, где нулевые значения не передаются.
+func (p *Projection) Prune(order model.Order) model.Order {
+	if p == nil {
+		return order
+	}
+	v := reflect.ValueOf(&order).Elem()
+	p.fields.prune(v)
+	return order
+}
+
+// field поле разреженного объекта.
+type field struct {
+	name  string
+	value any
+}
+
+// object разреженный объект JSON с сохранением порядка полей.
+type object []field
+
+// MarshalJSON кодирует поля объекта по порядку.
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// pick отбирает из значения v поля дерева n.
+func (n node) pick(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Struct:
+		out := make(object, 0, len(n))
+		for i := 0; i < v.NumField(); i++ {
+			name := jsonName(v.Type().Field(i))
+			child, ok := n[name]
+			if name == "" || !ok {
+				continue
+			}
+			f := field{name: name}
+			if child == nil {
+				f.value = v.Field(i).Interface()
+			} else {
+				f.value = child.pick(v.Field(i))
+			}
+			out = append(out, f)
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = n.pick(v.Index(i))
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// prune обнуляет в значении v поля, которых нет в дереве n.
+func (n node) prune(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name := jsonName(v.Type().Field(i))
+			if name == "" {
+				continue
+			}
+			child, ok := n[name]
+			switch {
+			case !ok:
+				v.Field(i).SetZero()
+			case child != nil:
+				child.pruneField(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			n.prune(v.Index(i))
+		}
+	}
+}
+
+// pruneField обнуляет вложенные поля значения v; срез копируется, чтобы не изменить заказ в кэше.
+func (n node) pruneField(v reflect.Value) {
+	if v.Kind() == reflect.Slice && !v.IsNil() {
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		v.Set(c)
+	}
+	n.prune(v)
+}
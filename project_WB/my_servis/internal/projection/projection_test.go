@@ -0,0 +1,70 @@
+package projection
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+func testOrder() model.Order {
+	return model.Order{
+		OrderUID:          "b563feb7b2b84b6test",
+		TrackNumber:       "WBILMTESTTRACK",
+		InternalSignature: "sig",
+		Delivery:          model.Delivery{City: "Kiryat Mozkin", Phone: "+9720000000"},
+		Payment:           model.Payment{Amount: 1817, Currency: "USD", Transaction: "b563feb7b2b84b6test"},
+		Items:             []model.Item{{Brand: "Vivienne Sabo", Price: 453, RID: "ab4219087a764ae0btest"}, {Brand: "Essence"}},
+	}
+}
+
+func TestApply(t *testing.T) {
+	p, err := Parse([]string{"order_uid", "delivery.city", "items.brand", "delivery"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(p.Apply(testOrder()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"order_uid":"b563feb7b2b84b6test",` +
+		`"delivery":{"name":"","phone":"+9720000000","zip":"","city":"Kiryat Mozkin","address":"","region":"","email":""},` +
+		`"items":[{"brand":"Vivienne Sabo"},{"brand":"Essence"}]}`
+	if string(got) != want {
+		t.Fatalf("Apply = %s, want %s", got, want)
+	}
+
+	for _, fields := range [][]string{{"delivery.unknown"}, {"order_uid.x"}, {"payments"}} {
+		if _, err := Parse(fields); err == nil {
+			t.Errorf("Parse(%v) accepted unknown field", fields)
+		}
+	}
+}
+
+func TestViews(t *testing.T) {
+	order := testOrder()
+	public, err := View(ViewPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned := public.Prune(order)
+	if pruned.InternalSignature != "" || pruned.Delivery.Phone != "" || pruned.Payment.Transaction != "" ||
+		pruned.Delivery.City != "Kiryat Mozkin" || pruned.Payment.Amount != 1817 || pruned.Items[0].Price != 453 || pruned.Items[0].RID != "" {
+		t.Fatalf("public view = %+v", pruned)
+	}
+	// Заказ в кэше не должен измениться
+	if order.Items[0].RID == "" || order.Delivery.Phone == "" {
+		t.Fatalf("Prune modified the source order: %+v", order)
+	}
+
+	if p, err := Select(ViewFull, nil); p != nil || err != nil {
+		t.Errorf("full view = %v, %v", p, err)
+	}
+	if _, err := Select("compact", nil); !errors.Is(err, ErrUnknownView) {
+		t.Errorf("unknown view error = %v", err)
+	}
+	if _, err := Select(ViewSummary, []string{"order_uid"}); !errors.Is(err, ErrViewAndFields) {
+		t.Errorf("view and fields error = %v", err)
+	}
+}
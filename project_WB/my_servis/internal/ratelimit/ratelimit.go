@@ -0,0 +1,219 @@
+// Package ratelimit ограничивает частоту запросов клиентов HTTP API и ведет их дневные квоты.
+//
+// Частота ограничивается алгоритмом token bucket отдельно для каждой пары клиент - маршрут.
+// Клиент определяется по результату аутентификации, а без нее - по IP-адресу. Счетчики запросов
+// за день периодически сохраняются в таблицу api_usage, по ним строится отчет об использовании API.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	config "main.go/internal"
+	"main.go/internal/auth"
+)
+
+// bucketKey ключ корзины токенов клиента на маршруте.
+type bucketKey struct {
+	client string
+	route  string
+}
+
+// bucket корзина токенов: каждый запрос забирает токен, токены пополняются со скоростью Rate до Burst.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// usageKey ключ счетчика использования.
+type usageKey struct {
+	day    string
+	client string
+	route  string
+}
+
+// counters количество принятых и отклоненных запросов.
+type counters struct {
+	requests int64
+	rejected int64
+}
+
+// Decision результат проверки запроса.
+type Decision struct {
+	Allowed    bool          // Allowed запрос можно выполнить.
+	Limit      int           // Limit размер корзины маршрута; 0, если частота маршрута не ограничена.
+	Remaining  int           // Remaining сколько запросов можно выполнить подряд без ожидания.
+	Reset      time.Duration // Reset через сколько корзина наполнится полностью.
+	RetryAfter time.Duration // RetryAfter через сколько можно повторить отклоненный запрос.
+	Quota      int64         // Quota дневная квота клиента; 0, если квоты нет.
+	QuotaLeft  int64         // QuotaLeft остаток дневной квоты.
+}
+
+// Limiter ограничивает частоту запросов и считает использование API клиентами.
+type Limiter struct {
+	enabled    bool
+	def        config.RouteLimitConfig
+	routes     map[string]config.RouteLimitConfig
+	dailyQuota int64
+	quotas     map[string]int64
+	now        func() time.Time
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+	day     string
+	totals  map[string]int64       // totals принятые за текущий день запросы клиентов, включая сохраненные в базе.
+	pending map[usageKey]*counters // pending счетчики, еще не сохраненные в базу данных.
+}
+
+// New создает Limiter по конфигурации.
+func New(cfg config.RateLimitConfig) *Limiter {
+	return &Limiter{
+		enabled:    cfg.Enabled,
+		def:        config.RouteLimitConfig{Rate: cfg.Rate, Burst: cfg.Burst},
+		routes:     cfg.Routes,
+		dailyQuota: cfg.DailyQuota,
+		quotas:     cfg.Quotas,
+		now:        time.Now,
+		buckets:    make(map[bucketKey]*bucket),
+		totals:     make(map[string]int64),
+		pending:    make(map[usageKey]*counters),
+	}
+}
+
+// Enabled сообщает, включено ли ограничение.
+func (l *Limiter) Enabled() bool {
+	return l.enabled
+}
+
+// routeLimit возвращает ограничение маршрута.
+func (l *Limiter) routeLimit(route string) config.RouteLimitConfig {
+	if limit, ok := l.routes[route]; ok {
+		return limit
+	}
+	return l.def
+}
+
+// quota возвращает дневную квоту клиента.
+func (l *Limiter) quota(client string) int64 {
+	if q, ok := l.quotas[client]; ok {
+		return q
+	}
+	return l.dailyQuota
+}
+
+// Allow проверяет запрос клиента к маршруту и учитывает его в счетчиках использования.
+func (l *Limiter) Allow(client, route string) Decision {
+	now := l.now()
+	day := now.UTC().Format(time.DateOnly)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if day != l.day {
+		l.day = day
+		clear(l.totals)
+	}
+	c := l.pending[usageKey{day, client, route}]
+	if c == nil {
+		c = &counters{}
+		l.pending[usageKey{day, client, route}] = c
+	}
+
+	d := Decision{Allowed: true, Quota: l.quota(client)}
+	if d.Quota > 0 {
+		d.QuotaLeft = max(d.Quota-l.totals[client], 0)
+		if d.QuotaLeft == 0 {
+			// Квота восстанавливается в полночь UTC
+			midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+			d.Allowed = false
+			d.RetryAfter = midnight.Sub(now)
+			c.rejected++
+			return d
+		}
+	}
+
+	if limit := l.routeLimit(route); limit.Rate > 0 {
+		burst := float64(max(limit.Burst, 1))
+		b := l.buckets[bucketKey{client, route}]
+		if b == nil {
+			b = &bucket{tokens: burst, last: now}
+			l.buckets[bucketKey{client, route}] = b
+		}
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+		b.last = now
+		d.Limit = int(burst)
+		if b.tokens < 1 {
+			d.Allowed = false
+			d.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+		} else {
+			b.tokens--
+		}
+		d.Remaining = int(b.tokens)
+		d.Reset = seconds((burst - b.tokens) / limit.Rate)
+	}
+
+	if !d.Allowed {
+		c.rejected++
+		return d
+	}
+	c.requests++
+	l.totals[client]++
+	if d.Quota > 0 {
+		d.QuotaLeft--
+	}
+	return d
+}
+
+// seconds переводит секунды в time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ClientID возвращает клиента запроса: имя API-ключа или subject JWT, а без аутентификации - IP-адрес.
+func ClientID(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok && p.Method != "none" {
+		return p.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Limit пропускает к next запросы в пределах ограничения маршрута route и дневной квоты клиента.
+// Ответ содержит заголовки RateLimit-Limit, RateLimit-Remaining и RateLimit-Reset, а при превышении
+// отклоняется с кодом 429 и заголовком Retry-After. Клиент определяется по ClientID, поэтому
+// Limit должен стоять после проверки аутентификации.
+func (l *Limiter) Limit(route string, next http.Handler) http.Handler {
+	if !l.enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := l.Allow(ClientID(r), route)
+		h := w.Header()
+		if d.Limit > 0 {
+			h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+		}
+		if d.Quota > 0 {
+			h.Set("X-Quota-Limit", strconv.FormatInt(d.Quota, 10))
+			h.Set("X-Quota-Remaining", strconv.FormatInt(d.QuotaLeft, 10))
+		}
+		if !d.Allowed {
+			h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(d.RetryAfter), 1)))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ceilSeconds округляет интервал вверх до целых секунд.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
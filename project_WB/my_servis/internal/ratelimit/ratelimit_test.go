@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	config "main.go/internal"
+)
+
+func TestLimitBurstAndQuota(t *testing.T) {
+	now := time.Date(2026, 10, 19, 23, 59, 0, 0, time.UTC)
+	l := New(config.RateLimitConfig{
+		Enabled: true, Rate: 1, Burst: 2,
+		Routes: map[string]config.RouteLimitConfig{"stream": {}},
+		Quotas: map[string]int64{"ip:192.0.2.1": 4},
+	})
+	l.now = func() time.Time { return now }
+	handler := l.Limit("order", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/order", nil)
+		r.RemoteAddr = "192.0.2.1:5000"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := do(); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, w.Code)
+		}
+	}
+	w := do()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("over burst: status %d, headers %v", w.Code, w.Header())
+	}
+
+	// За две секунды корзина наполняется, но квота клиента позволяет только два запроса
+	now = now.Add(2 * time.Second)
+	do()
+	do()
+	w = do()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("X-Quota-Remaining") != "0" || w.Header().Get("Retry-After") != "58" {
+		t.Fatalf("over quota: status %d, headers %v", w.Code, w.Header())
+	}
+
+	// Маршрут без ограничения частоты все равно учитывается в квоте
+	if d := l.Allow("ip:192.0.2.1", "stream"); d.Allowed || d.Limit != 0 {
+		t.Fatalf("stream over quota: %+v", d)
+	}
+
+	// В новых сутках квота восстанавливается
+	now = now.Add(time.Minute)
+	if w := do(); w.Code != http.StatusOK {
+		t.Fatalf("next day: status %d", w.Code)
+	}
+	if c := l.pending[usageKey{"2026-10-19", "ip:192.0.2.1", "order"}]; c.requests != 4 || c.rejected != 2 {
+		t.Fatalf("counters = %+v", *c)
+	}
+}
+
+func TestPruneBuckets(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	l := New(config.RateLimitConfig{
+		Enabled: true, Rate: 1, Burst: 2,
+		Routes: map[string]config.RouteLimitConfig{"export": {Rate: 0.1, Burst: 1}},
+	})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 100; i++ {
+		l.Allow(fmt.Sprintf("ip:192.0.2.%d", i), "order")
+	}
+	l.Allow("ip:192.0.2.200", "export")
+	l.Allow("ip:192.0.2.200", "export")
+
+	// Через две секунды корзины маршрута order снова полные, а корзина export еще пополняется
+	now = now.Add(2 * time.Second)
+	l.Allow("ip:192.0.2.0", "order")
+	l.pruneBuckets()
+	if len(l.buckets) != 2 || l.buckets[bucketKey{"ip:192.0.2.0", "order"}] == nil || l.buckets[bucketKey{"ip:192.0.2.200", "export"}] == nil {
+		t.Fatalf("buckets after prune = %v, want only the active order and export buckets", l.buckets)
+	}
+
+	// Простаивающие корзины удаляются, как только наполнятся
+	now = now.Add(10 * time.Second)
+	l.pruneBuckets()
+	if len(l.buckets) != 0 {
+		t.Fatalf("buckets after idle = %v, want none", l.buckets)
+	}
+}
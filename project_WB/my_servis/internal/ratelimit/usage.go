@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// CreateTables создает таблицу дневных счетчиков использования API.
+func CreateTables(db *sql.DB) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS api_usage (
+		day DATE NOT NULL,
+		client VARCHAR(255) NOT NULL,
+		route VARCHAR(64) NOT NULL,
+		requests BIGINT NOT NULL DEFAULT 0,
+		rejected BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, client, route)
+	);`)
+	if err != nil {
+		log.Fatalf("Error creating api usage table: %v", err)
+	}
+}
+
+// Run периодически сохраняет счетчики использования в базу данных и удаляет заполненные корзины токенов.
+// Перед первым сохранением загружает счетчики за текущий день, чтобы квоты учитывали запросы до перезапуска.
+func (l *Limiter) Run(interval time.Duration, db *sql.DB) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := l.Flush(ctx, db); err != nil {
+			log.Printf("Ошибка сохранения счетчиков использования API: %v", err)
+		}
+		cancel()
+		l.pruneBuckets()
+		time.Sleep(interval)
+	}
+}
+
+// pruneBuckets удаляет корзины, которые успели наполниться: они не отличаются от новых.
+func (l *Limiter) pruneBuckets() {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, b := range l.buckets {
+		limit := l.routeLimit(key.route)
+		if b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(max(limit.Burst, 1)) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Flush сохраняет накопленные счетчики в таблицу api_usage и обновляет итоги дня для квот
+// по данным всех экземпляров сервиса.
+func (l *Limiter) Flush(ctx context.Context, db *sql.DB) error {
+	l.mu.Lock()
+	batch := l.pending
+	l.pending = make(map[usageKey]*counters)
+	l.mu.Unlock()
+
+	if err := saveUsage(ctx, batch, db); err != nil {
+		// Несохраненные счетчики возвращаются в очередь до следующей попытки
+		l.mu.Lock()
+		for key, c := range batch {
+			if p := l.pending[key]; p != nil {
+				p.requests += c.requests
+				p.rejected += c.rejected
+			} else {
+				l.pending[key] = c
+			}
+		}
+		l.mu.Unlock()
+		return err
+	}
+
+	day := l.now().UTC().Format(time.DateOnly)
+	totals, err := loadTotals(ctx, day, db)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.day != "" && l.day != day {
+		return nil
+	}
+	// Запросы, принятые во время сохранения, еще не попали в базу
+	for key, c := range l.pending {
+		if key.day == day {
+			totals[key.client] += c.requests
+		}
+	}
+	l.day = day
+	l.totals = totals
+	return nil
+}
+
+// saveUsage прибавляет счетчики к строкам таблицы api_usage одной транзакцией.
+func saveUsage(ctx context.Context, batch map[usageKey]*counters, db *sql.DB) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO api_usage (day, client, route, requests, rejected) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (day, client, route) DO UPDATE SET
+			requests = api_usage.requests + EXCLUDED.requests,
+			rejected = api_usage.rejected + EXCLUDED.rejected`)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки запроса: %v", err)
+	}
+	defer stmt.Close()
+	for key, c := range batch {
+		if _, err := stmt.ExecContext(ctx, key.day, key.client, key.route, c.requests, c.rejected); err != nil {
+			return fmt.Errorf("ошибка сохранения счетчиков использования: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return nil
+}
+
+// loadTotals возвращает принятые за день запросы каждого клиента.
+func loadTotals(ctx context.Context, day string, db *sql.DB) (map[string]int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT client, sum(requests) FROM api_usage WHERE day = $1 GROUP BY client", day)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения счетчиков использования: %v", err)
+	}
+	defer rows.Close()
+	totals := make(map[string]int64)
+	for rows.Next() {
+		var client string
+		var requests int64
+		if err := rows.Scan(&client, &requests); err != nil {
+			return nil, fmt.Errorf("ошибка чтения счетчиков использования: %v", err)
+		}
+		totals[client] = requests
+	}
+	return totals, rows.Err()
+}
+
+// RouteUsage использование маршрута клиентом за день.
+type RouteUsage struct {
+	Route    string `json:"route"`
+	Requests int64  `json:"requests"`
+	Rejected int64  `json:"rejected"`
+}
+
+// ClientUsage использование API клиентом за день.
+type ClientUsage struct {
+	Client    string       `json:"client"`
+	Requests  int64        `json:"requests"`
+	Rejected  int64        `json:"rejected"`
+	Quota     int64        `json:"quota,omitempty"`
+	QuotaUsed float64      `json:"quota_used,omitempty"` // QuotaUsed доля использованной квоты.
+	Routes    []RouteUsage `json:"routes"`
+}
+
+// Report отчет об использовании API за день.
+type Report struct {
+	Day     string        `json:"day"`
+	Clients []ClientUsage `json:"clients"`
+}
+
+// Report сохраняет накопленные счетчики и возвращает отчет об использовании API за день day (YYYY-MM-DD, UTC).
+func (l *Limiter) Report(ctx context.Context, day string, db *sql.DB) (Report, error) {
+	report := Report{Day: day, Clients: []ClientUsage{}}
+	if err := l.Flush(ctx, db); err != nil {
+		return report, err
+	}
+	rows, err := db.QueryContext(ctx,
+		"SELECT client, route, requests, rejected FROM api_usage WHERE day = $1 ORDER BY client, route", day)
+	if err != nil {
+		return report, fmt.Errorf("ошибка чтения счетчиков использования: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var client string
+		var route RouteUsage
+		if err := rows.Scan(&client, &route.Route, &route.Requests, &route.Rejected); err != nil {
+			return report, fmt.Errorf("ошибка чтения счетчиков использования: %v", err)
+		}
+		n := len(report.Clients)
+		if n == 0 || report.Clients[n-1].Client != client {
+			report.Clients = append(report.Clients, ClientUsage{Client: client, Quota: l.quota(client)})
+			n++
+		}
+		c := &report.Clients[n-1]
+		c.Requests += route.Requests
+		c.Rejected += route.Rejected
+		c.Routes = append(c.Routes, route)
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("ошибка чтения счетчиков использования: %v", err)
+	}
+	for i := range report.Clients {
+		if c := &report.Clients[i]; c.Quota > 0 {
+			c.QuotaUsed = math.Round(float64(c.Requests)/float64(c.Quota)*1000) / 1000
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,285 @@
+// Package search разбирает язык поисковых запросов по заказам.
+//
+// Запрос состоит из слов и условий по полям, разделенных пробелами; все части должны выполняться:
+//
+//	Vivienne city:Moscow amount>1000 -status:202 name:"Test Testov"
+//
+// Слово без поля ищется полнотекстово по получателю, адресу и товарам, а также точно
+// по идентификатору заказа, трек-номеру и идентификатору клиента. Значение с пробелами
+// заключается в кавычки, минус перед условием его отрицает.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Kind определяет, как сравнивается значение поля.
+type Kind int
+
+const (
+	KindText   Kind = iota // KindText поиск подстроки без учета регистра.
+	KindPhone              // KindPhone поиск цифр номера без учета форматирования.
+	KindExact              // KindExact точное совпадение без учета регистра.
+	KindNumber             // KindNumber сравнение целых чисел.
+	KindDate               // KindDate сравнение даты создания заказа.
+)
+
+// Field описывает поле, доступное в запросе.
+type Field struct {
+	Kind Kind
+	PII  bool // PII поле с персональными данными, которое может храниться зашифрованным.
+}
+
+// Fields поля запроса.
+var Fields = map[string]Field{
+	"name":     {Kind: KindText, PII: true},  // name имя получателя.
+	"phone":    {Kind: KindPhone, PII: true}, // phone телефон получателя, достаточно фрагмента.
+	"email":    {Kind: KindText, PII: true},  // email адрес электронной почты получателя.
+	"address":  {Kind: KindText, PII: true},  // address адрес доставки.
+	"city":     {Kind: KindText},             // city город доставки.
+	"region":   {Kind: KindText},             // region регион доставки.
+	"zip":      {Kind: KindText},             // zip почтовый индекс.
+	"brand":    {Kind: KindText},             // brand бренд любого товара заказа.
+	"item":     {Kind: KindText},             // item название любого товара заказа.
+	"uid":      {Kind: KindExact},            // uid идентификатор заказа.
+	"track":    {Kind: KindExact},            // track трек-номер.
+	"customer": {Kind: KindExact},            // customer идентификатор клиента.
+	"service":  {Kind: KindExact},            // service служба доставки.
+	"currency": {Kind: KindExact},            // currency валюта платежа.
+	"provider": {Kind: KindExact},            // provider платежный провайдер.
+	"bank":     {Kind: KindExact},            // bank банк.
+	"amount":   {Kind: KindNumber},           // amount сумма платежа в минимальных единицах валюты.
+	"status":   {Kind: KindNumber},           // status статус любого товара заказа.
+	"nm":       {Kind: KindNumber},           // nm артикул (nm_id) любого товара заказа.
+	"date":     {Kind: KindDate},             // date дата создания заказа (YYYY-MM-DD или RFC 3339).
+}
+
+// Операторы сравнения условий.
+const (
+	OpMatch = ":"  // OpMatch совпадение по правилам поля.
+	OpEQ    = "="  // OpEQ равенство.
+	OpGT    = ">"  // OpGT больше.
+	OpGE    = ">=" // OpGE больше или равно.
+	OpLT    = "<"  // OpLT меньше.
+	OpLE    = "<=" // OpLE меньше или равно.
+)
+
+// ErrInvalidQuery возвращается для запроса с синтаксической ошибкой, неизвестным полем или недопустимым значением.
+var ErrInvalidQuery = errors.New("некорректный поисковый запрос")
+
+// Term условие по полю.
+type Term struct {
+	Field  string
+	Op     string
+	Value  string
+	Negate bool
+
+	Number int64     // Number значение числового поля.
+	From   time.Time // From начало интервала для даты, заданной днем, или момент сравнения.
+	To     time.Time // To конец интервала (не включая) для даты, заданной днем.
+}
+
+// Kind возвращает вид поля условия.
+func (t Term) Kind() Kind {
+	return Fields[t.Field].Kind
+}
+
+// PII сообщает, относится ли условие к персональным данным.
+func (t Term) PII() bool {
+	return Fields[t.Field].PII
+}
+
+// Query разобранный запрос.
+type Query struct {
+	Words []string // Words слова полнотекстового поиска.
+	Terms []Term   // Terms условия по полям.
+}
+
+// Empty сообщает, что запрос ничего не ограничивает.
+func (q Query) Empty() bool {
+	return len(q.Words) == 0 && len(q.Terms) == 0
+}
+
+// maxParts максимальное количество слов и условий в запросе.
+const maxParts = 20
+
+// Parse разбирает строку запроса.
+func Parse(s string) (Query, error) {
+	var q Query
+	tokens, err := tokenize(s)
+	if err != nil {
+		return q, err
+	}
+	if len(tokens) > maxParts {
+		return q, fmt.Errorf("%w: не больше %d условий", ErrInvalidQuery, maxParts)
+	}
+	for _, tok := range tokens {
+		if tok.field == "" {
+			q.Words = append(q.Words, tok.value)
+			continue
+		}
+		term, err := newTerm(tok)
+		if err != nil {
+			return q, err
+		}
+		q.Terms = append(q.Terms, term)
+	}
+	return q, nil
+}
+
+// token часть запроса: слово или условие.
+type token struct {
+	field, op, value string
+	negate           bool
+}
+
+// tokenize разбивает запрос на слова и условия с учетом кавычек.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		if unicode.IsSpace(rs[i]) {
+			i++
+			continue
+		}
+		var tok token
+		// Имя поля и оператор
+		j := i
+		if rs[j] == '-' {
+			j++
+		}
+		k := j
+		for k < len(rs) && (unicode.IsLetter(rs[k]) || rs[k] == '_') {
+			k++
+		}
+		if op := opAt(rs, k); op != "" && k > j {
+			tok.negate = j > i
+			tok.field = strings.ToLower(string(rs[j:k]))
+			tok.op = op
+			i = k + len(op)
+		}
+		// Значение
+		value, next, err := valueAt(rs, i)
+		if err != nil {
+			return nil, err
+		}
+		tok.value = value
+		i = next
+		if tok.field != "" && tok.value == "" {
+			return nil, fmt.Errorf("%w: пустое значение поля %s", ErrInvalidQuery, tok.field)
+		}
+		if tok.field == "" && tok.value == "" {
+			continue
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// opAt возвращает оператор в позиции i или пустую строку.
+func opAt(rs []rune, i int) string {
+	if i >= len(rs) {
+		return ""
+	}
+	switch rs[i] {
+	case ':', '=':
+		return string(rs[i])
+	case '>', '<':
+		if i+1 < len(rs) && rs[i+1] == '=' {
+			return string(rs[i : i+2])
+		}
+		return string(rs[i])
+	}
+	return ""
+}
+
+// valueAt читает значение с позиции i: строку в кавычках или символы до пробела.
+func valueAt(rs []rune, i int) (string, int, error) {
+	if i < len(rs) && rs[i] == '"' {
+		var b strings.Builder
+		for j := i + 1; j < len(rs); j++ {
+			switch {
+			case rs[j] == '\\' && j+1 < len(rs):
+				j++
+				b.WriteRune(rs[j])
+			case rs[j] == '"':
+				return b.String(), j + 1, nil
+			default:
+				b.WriteRune(rs[j])
+			}
+		}
+		return "", 0, fmt.Errorf("%w: незакрытая кавычка", ErrInvalidQuery)
+	}
+	j := i
+	for j < len(rs) && !unicode.IsSpace(rs[j]) {
+		j++
+	}
+	return string(rs[i:j]), j, nil
+}
+
+// newTerm проверяет поле, оператор и значение условия.
+func newTerm(tok token) (Term, error) {
+	term := Term{Field: tok.field, Op: tok.op, Value: strings.TrimSpace(tok.value), Negate: tok.negate}
+	field, ok := Fields[term.Field]
+	if !ok {
+		return term, fmt.Errorf("%w: неизвестное поле %q", ErrInvalidQuery, term.Field)
+	}
+	comparison := term.Op != OpMatch && term.Op != OpEQ
+	switch field.Kind {
+	case KindText, KindExact:
+		if comparison {
+			return term, fmt.Errorf("%w: поле %s не поддерживает оператор %s", ErrInvalidQuery, term.Field, term.Op)
+		}
+	case KindPhone:
+		if comparison {
+			return term, fmt.Errorf("%w: поле %s не поддерживает оператор %s", ErrInvalidQuery, term.Field, term.Op)
+		}
+		term.Value = Digits(term.Value)
+		if term.Value == "" {
+			return term, fmt.Errorf("%w: в номере телефона нет цифр", ErrInvalidQuery)
+		}
+	case KindNumber:
+		n, err := strconv.ParseInt(term.Value, 10, 64)
+		if err != nil {
+			return term, fmt.Errorf("%w: поле %s ожидает целое число", ErrInvalidQuery, term.Field)
+		}
+		term.Number = n
+	case KindDate:
+		if t, err := time.Parse(time.DateOnly, term.Value); err == nil {
+			// День целиком: date:2024-01-05 - заказы за этот день, date>2024-01-05 - после него
+			term.From, term.To = t, t.AddDate(0, 0, 1)
+			break
+		}
+		t, err := time.Parse(time.RFC3339, term.Value)
+		if err != nil {
+			return term, fmt.Errorf("%w: поле %s ожидает дату YYYY-MM-DD или RFC 3339", ErrInvalidQuery, term.Field)
+		}
+		term.From, term.To = t, t
+	}
+	if term.Op == OpEQ {
+		term.Op = OpMatch
+	}
+	return term, nil
+}
+
+// Digits оставляет в строке только цифры: телефоны сравниваются без пробелов, скобок и дефисов.
+func Digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// Words разбивает слово запроса на части из букв и цифр для полнотекстового поиска.
+func Words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,45 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`Vivienne brand:Sabo city="Kiryat Mozkin" amount>=1000 -status:202 phone:"+972 (000) 00" date<2021-11-26`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(q.Words, []string{"Vivienne"}) {
+		t.Errorf("Words = %q", q.Words)
+	}
+	day := time.Date(2021, 11, 26, 0, 0, 0, 0, time.UTC)
+	want := []Term{
+		{Field: "brand", Op: OpMatch, Value: "Sabo"},
+		{Field: "city", Op: OpMatch, Value: "Kiryat Mozkin"},
+		{Field: "amount", Op: OpGE, Value: "1000", Number: 1000},
+		{Field: "status", Op: OpMatch, Value: "202", Number: 202, Negate: true},
+		{Field: "phone", Op: OpMatch, Value: "97200000"},
+		{Field: "date", Op: OpLT, Value: "2021-11-26", From: day, To: day.AddDate(0, 0, 1)},
+	}
+	if !reflect.DeepEqual(q.Terms, want) {
+		t.Errorf("Terms = %+v, want %+v", q.Terms, want)
+	}
+
+	for _, s := range []string{`unknown:x`, `city>Moscow`, `amount>many`, `name:"Test`, `phone:abc`, `date:yesterday`, `brand:`} {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Parse(%q) err = %v, want ErrInvalidQuery", s, err)
+		}
+	}
+	if q, err := Parse("   "); err != nil || !q.Empty() {
+		t.Errorf("Parse(blank) = %+v, %v", q, err)
+	}
+}
+
+func TestWords(t *testing.T) {
+	if words := Words("Vivienne-Sabo's 2021"); !reflect.DeepEqual(words, []string{"vivienne", "sabo", "s", "2021"}) {
+		t.Errorf("Words = %q", words)
+	}
+}
@@ -1,41 +1,287 @@
 package cache
 
 import (
+	"encoding/json"
+	"sort"
 	"sync"
 
-	model "main.go/orders_model"
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"main.go/internal/httpcache"
+	"main.go/internal/pii"
 )
 
+// partition раздел кэша с заказами одного шарда и собственной блокировкой,
+// чтобы запись в один шард не блокировала чтение заказов других шардов.
+type partition struct {
+	lock   sync.RWMutex
+	orders map[string]entry
+}
+
+// entry заказ в кэше вместе с его заранее сериализованным представлением.
+type entry struct {
+	order   model.Order
+	encoded Encoded
+}
+
+// Encoded JSON заказа, сериализованный при записи в кэш, и ETag по хешу содержимого.
+// Вариант Masked соответствует ответу с маскированными телефоном и email получателя.
+type Encoded struct {
+	JSON       []byte
+	ETag       string
+	MaskedJSON []byte
+	MaskedETag string
+}
+
+// newEntry сериализует заказ для кэша.
+func newEntry(order model.Order) entry {
+	e := entry{order: order}
+	// Ошибка сериализации оставляет представление пустым, тогда обработчик сериализует заказ сам
+	if data, err := json.Marshal(order); err == nil {
+		e.encoded.JSON, e.encoded.ETag = data, httpcache.ETag(data)
+	}
+	if data, err := json.Marshal(pii.Mask(order)); err == nil {
+		e.encoded.MaskedJSON, e.encoded.MaskedETag = data, httpcache.ETag(data)
+	}
+	return e
+}
+
 var (
-	OrderCache     map[string]model.Order
-	orderCacheLock sync.RWMutex
+	partitions     = make(map[string]*partition)
+	partitionOf    = singlePartition
+	partitionsLock sync.RWMutex
 )
 
-// InitCache инициализирует кэш заказов.
+// singlePartition помещает все заказы в один раздел.
+func singlePartition(model.Order) string { return "" }
+
+// InitCache инициализирует кэш заказов из одного раздела.
 func InitCache() {
-	OrderCache = make(map[string]model.Order)
+	InitPartitions(singlePartition)
+}
+
+// InitPartitions инициализирует кэш заказов, разделенный по шардам: of возвращает имя раздела заказа.
+func InitPartitions(of func(model.Order) string) {
+	partitionsLock.Lock()
+	defer partitionsLock.Unlock()
+	partitions = make(map[string]*partition)
+	partitionOf = of
+}
+
+// partitionName возвращает имя раздела кэша для заказа.
+func partitionName(order model.Order) string {
+	partitionsLock.RLock()
+	of := partitionOf
+	partitionsLock.RUnlock()
+	return of(order)
 }
 
-// CacheOrder добавляет заказ в кэш.
+// partitionFor возвращает раздел кэша по имени, создавая его при первом обращении.
+func partitionFor(name string) *partition {
+	partitionsLock.RLock()
+	p, ok := partitions[name]
+	partitionsLock.RUnlock()
+	if ok {
+		return p
+	}
+
+	partitionsLock.Lock()
+	defer partitionsLock.Unlock()
+	if p, ok := partitions[name]; ok {
+		return p
+	}
+	p = &partition{orders: make(map[string]entry)}
+	partitions[name] = p
+	return p
+}
+
+// allPartitions возвращает все разделы кэша.
+func allPartitions() []*partition {
+	partitionsLock.RLock()
+	defer partitionsLock.RUnlock()
+	result := make([]*partition, 0, len(partitions))
+	for _, p := range partitions {
+		result = append(result, p)
+	}
+	return result
+}
+
+// CacheOrder добавляет заказ в раздел его шарда. Заказ сериализуется до захвата блокировки.
 func CacheOrder(order model.Order) {
-	orderCacheLock.Lock()
-	defer orderCacheLock.Unlock()
-	OrderCache[order.OrderUID] = order
+	e := newEntry(order)
+	p := partitionFor(partitionName(order))
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.orders[order.OrderUID] = e
 }
 
 // GetOrderFromCache получает заказ из кэша по его идентификатору.
 func GetOrderFromCache(orderUID string) (model.Order, bool) {
-	orderCacheLock.RLock()
-	defer orderCacheLock.RUnlock()
-	order, exists := OrderCache[orderUID]
-	return order, exists
+	e, exists := getEntry(orderUID)
+	return e.order, exists
+}
+
+// GetEncoded получает заказ из кэша вместе с его сериализованным представлением.
+func GetEncoded(orderUID string) (model.Order, Encoded, bool) {
+	e, exists := getEntry(orderUID)
+	return e.order, e.encoded, exists
+}
+
+// getEntry ищет запись заказа во всех разделах.
+func getEntry(orderUID string) (entry, bool) {
+	for _, p := range allPartitions() {
+		p.lock.RLock()
+		e, exists := p.orders[orderUID]
+		p.lock.RUnlock()
+		if exists {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+// LookupOrders ищет в кэше заказы по идентификаторам или трек-номерам ids. Возвращает найденные заказы
+// по идентификатору заказа и ids, для которых ничего не найдено. Каждый раздел блокируется один раз.
+func LookupOrders(ids []string) (map[string]model.Order, []string) {
+	found := make(map[string]model.Order, len(ids))
+	matched := make(map[string]bool, len(ids))
+	for _, p := range allPartitions() {
+		p.lock.RLock()
+		for _, id := range ids {
+			if e, exists := p.orders[id]; exists {
+				found[id] = e.order
+				matched[id] = true
+			}
+		}
+		if len(matched) < len(ids) {
+			// Трек-номера не индексированы, поэтому раздел просматривается целиком
+			tracks := make(map[string]bool, len(ids))
+			for _, id := range ids {
+				tracks[id] = true
+			}
+			for uid, e := range p.orders {
+				if tracks[e.order.TrackNumber] {
+					found[uid] = e.order
+					matched[e.order.TrackNumber] = true
+				}
+			}
+		}
+		p.lock.RUnlock()
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !matched[id] {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing
+}
+
+// DeleteOrders удаляет заказы из кэша.
+func DeleteOrders(orderUIDs []string) {
+	for _, p := range allPartitions() {
+		p.lock.Lock()
+		for _, uid := range orderUIDs {
+			delete(p.orders, uid)
+		}
+		p.lock.Unlock()
+	}
 }
 
 // SetCache кэширует все заказы.
 func SetCache(allOrders map[string]model.Order) {
-	orderCacheLock.Lock()
-	defer orderCacheLock.Unlock()
-	for k, v := range allOrders {
-		OrderCache[k] = v
+	byPartition := make(map[string][]entry)
+	for _, order := range allOrders {
+		name := partitionName(order)
+		byPartition[name] = append(byPartition[name], newEntry(order))
+	}
+	for name, entries := range byPartition {
+		p := partitionFor(name)
+		p.lock.Lock()
+		for _, e := range entries {
+			p.orders[e.order.OrderUID] = e
+		}
+		p.lock.Unlock()
+	}
+}
+
+// CountOrders возвращает количество заказов в кэше.
+func CountOrders() int {
+	total := 0
+	for _, p := range allPartitions() {
+		p.lock.RLock()
+		total += len(p.orders)
+		p.lock.RUnlock()
+	}
+	return total
+}
+
+// PartitionCounts возвращает количество заказов в каждом разделе кэша.
+func PartitionCounts() map[string]int {
+	partitionsLock.RLock()
+	defer partitionsLock.RUnlock()
+	counts := make(map[string]int, len(partitions))
+	for name, p := range partitions {
+		p.lock.RLock()
+		counts[name] = len(p.orders)
+		p.lock.RUnlock()
+	}
+	return counts
+}
+
+// FindOrders ищет заказы по точному совпадению идентификатора заказа, трек-номера или идентификатора клиента.
+func FindOrders(query string) []model.Order {
+	if order, exists := GetOrderFromCache(query); exists {
+		return []model.Order{order}
+	}
+
+	var result []model.Order
+	for _, p := range allPartitions() {
+		p.lock.RLock()
+		for _, e := range p.orders {
+			if e.order.TrackNumber == query || e.order.CustomerID == query {
+				result = append(result, e.order)
+			}
+		}
+		p.lock.RUnlock()
+	}
+	sortOrders(result)
+	return result
+}
+
+// ListOrders возвращает страницу заказов, отсортированных от новых к старым, и общее количество заказов.
+func ListOrders(offset, limit int) ([]model.Order, int) {
+	var all []model.Order
+	for _, p := range allPartitions() {
+		p.lock.RLock()
+		for _, e := range p.orders {
+			all = append(all, e.order)
+		}
+		p.lock.RUnlock()
+	}
+
+	sortOrders(all)
+	return Paginate(all, offset, limit), len(all)
+}
+
+// sortOrders сортирует заказы по дате создания (новые первыми), а при равенстве - по идентификатору.
+func sortOrders(orders []model.Order) {
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.After(orders[j].DateCreated)
+		}
+		return orders[i].OrderUID < orders[j].OrderUID
+	})
+}
+
+// Paginate возвращает часть среза с учетом смещения и размера страницы.
+func Paginate(orders []model.Order, offset, limit int) []model.Order {
+	if offset >= len(orders) {
+		return []model.Order{}
+	}
+	end := offset + limit
+	if end > len(orders) {
+		end = len(orders)
 	}
+	return orders[offset:end]
 }
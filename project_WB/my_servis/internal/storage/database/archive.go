@@ -0,0 +1,200 @@
+package database
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxArchiveLine максимальный размер одного документа заказа в архиве.
+const maxArchiveLine = 16 << 20
+
+// ArchivePath возвращает путь архива заказов за месяц, например archive/orders-2024-01.ndjson.gz.
+func ArchivePath(dir string, month time.Time) string {
+	return filepath.Join(dir, "orders-"+month.Format("2006-01")+".ndjson.gz")
+}
+
+// archivePartitions выгружает документы заказов за месяц в сжатый NDJSON и удаляет секции.
+// Секции удаляются в той же транзакции после того, как файл архива полностью записан на диск;
+// если транзакция не зафиксирована, файл удаляется.
+func archivePartitions(ctx context.Context, dir string, month time.Time, db *sql.DB) error {
+	path := ArchivePath(dir, month)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("архив %s уже существует", path)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("ошибка создания каталога архивов: %v", err)
+	}
+
+	err := inMaintenanceTx(ctx, db, func(tx *sql.Tx) error {
+		if err := exportPartition(ctx, path, month, tx); err != nil {
+			return err
+		}
+		return dropPartitionsTx(ctx, month, tx)
+	})
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// exportPartition записывает документы заказов секции за месяц в файл path, по одному JSON на строку.
+// Файл сначала пишется во временный и переименовывается только после успешной записи.
+func exportPartition(ctx context.Context, path string, month time.Time, tx *sql.Tx) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("ошибка создания архива: %v", err)
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		"SELECT order_uid, document FROM %s ORDER BY date_created, order_uid", partitionName("orders", month)))
+	if err != nil {
+		return fmt.Errorf("ошибка выгрузки заказов за %s: %v", month.Format("2006-01"), err)
+	}
+	defer rows.Close()
+
+	gz := gzip.NewWriter(f)
+	for rows.Next() {
+		var orderUID string
+		var document []byte
+		if err := rows.Scan(&orderUID, &document); err != nil {
+			return fmt.Errorf("ошибка чтения документа заказа: %v", err)
+		}
+		if document == nil {
+			return fmt.Errorf("документ заказа %s не заполнен", orderUID)
+		}
+		// JSONB выводится Postgres в одну строку, поэтому документ можно писать как строку NDJSON
+		if _, err := gz.Write(append(document, '\n')); err != nil {
+			return fmt.Errorf("ошибка записи архива: %v", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка выгрузки заказов за %s: %v", month.Format("2006-01"), err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("ошибка записи архива: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("ошибка записи архива: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("ошибка записи архива: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// RestoreArchive возвращает в базу заказы за месяц и возвращает количество восстановленных заказов.
+// Если секции месяца были отключены политикой detach, они подключаются обратно; иначе секции
+// создаются и заполняются из архива NDJSON в каталоге dir, а заказы, уже находящиеся в базе, пропускаются.
+// Восстановленный месяц защищается от политики хранения до вызова ReleaseHold.
+// События order.accepted для восстановленных заказов повторно не публикуются.
+func RestoreArchive(ctx context.Context, dir string, month time.Time, db *sql.DB) (int, error) {
+	month = monthStart(month)
+	restored := 0
+	err := inMaintenanceTx(ctx, db, func(tx *sql.Tx) error {
+		detached, err := partitionDetached(ctx, month, tx)
+		if err != nil {
+			return err
+		}
+		if detached {
+			if restored, err = attachPartitions(ctx, month, tx); err != nil {
+				return err
+			}
+		} else {
+			if err := createPartitions(ctx, month, tx); err != nil {
+				return err
+			}
+			if restored, err = restoreFile(ctx, ArchivePath(dir, month), tx); err != nil {
+				return err
+			}
+		}
+		// Архивы и отключенные секции могли быть сохранены до удаления данных клиента по его запросу
+		if err := reapplyErasures(ctx, tx); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO partition_holds (month) VALUES ($1) ON CONFLICT DO NOTHING", month)
+		if err != nil {
+			return fmt.Errorf("ошибка защиты месяца от политики хранения: %v", err)
+		}
+		return nil
+	})
+	return restored, err
+}
+
+// partitionDetached сообщает, есть ли в базе отключенная секция orders за месяц.
+func partitionDetached(ctx context.Context, month time.Time, tx *sql.Tx) (bool, error) {
+	var detached bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT to_regclass($1) IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM pg_inherits WHERE inhrelid = to_regclass($1))`,
+		partitionName("orders", month)).Scan(&detached)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки секции: %v", err)
+	}
+	return detached, nil
+}
+
+// attachPartitions подключает отключенные секции за месяц и возвращает количество заказов в них.
+func attachPartitions(ctx context.Context, month time.Time, tx *sql.Tx) (int, error) {
+	for _, table := range partitionedTables {
+		name := partitionName(table, month)
+		query := fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s FOR VALUES %s", table, name, partitionBounds(month))
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return 0, fmt.Errorf("ошибка подключения секции %s: %v", name, err)
+		}
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+partitionName("orders", month)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("ошибка подсчета заказов: %v", err)
+	}
+	return count, nil
+}
+
+// restoreFile вставляет заказы из архива NDJSON и возвращает количество добавленных.
+func restoreFile(ctx context.Context, path string, tx *sql.Tx) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка открытия архива: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения архива %s: %v", path, err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64<<10), maxArchiveLine)
+	restored := 0
+	for line := 1; scanner.Scan(); line++ {
+		document := scanner.Bytes()
+		if len(document) == 0 {
+			continue
+		}
+		order, err := decodeDocument(document)
+		if err != nil {
+			return 0, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		err = insertOrderRows(ctx, order, document, tx)
+		if errors.Is(err, ErrOrderExists) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("заказ %s: %v", order.OrderUID, err)
+		}
+		restored++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка чтения архива %s: %v", path, err)
+	}
+	return restored, nil
+}
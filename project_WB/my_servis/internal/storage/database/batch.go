@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"main.go/internal/pii"
+)
+
+// BatchOrder заказ пакетной вставки вместе с исходным JSON-документом.
+type BatchOrder struct {
+	Order    model.Order
+	Document []byte
+}
+
+// createStagingTables создает временные таблицы пакета, которые удаляются при завершении транзакции.
+const createStagingTables = `
+	CREATE TEMP TABLE staging_orders (LIKE orders) ON COMMIT DROP;
+	CREATE TEMP TABLE staging_deliveries (LIKE deliveries) ON COMMIT DROP;
+	CREATE TEMP TABLE staging_payments (LIKE payments) ON COMMIT DROP;
+	CREATE TEMP TABLE staging_items (LIKE items) ON COMMIT DROP;
+	CREATE TEMP TABLE staging_outbox (order_uid VARCHAR(255), msg_id VARCHAR(255), payload JSONB) ON COMMIT DROP;
+	CREATE TEMP TABLE staging_new (order_uid VARCHAR(255) PRIMARY KEY) ON COMMIT DROP;`
+
+// lockStagingOrders блокирует идентификаторы заказов пакета, как insertOrder, до переноса в основные таблицы.
+// Блокировки берутся в порядке идентификаторов, чтобы параллельные пакеты не ждали друг друга по кругу.
+const lockStagingOrders = `
+	SELECT pg_advisory_xact_lock(` + orderUIDLockClass + `, hashtext(order_uid))
+	FROM (SELECT order_uid FROM staging_orders ORDER BY order_uid) s`
+
+// mergeStagingTables переносит в основные таблицы только заказы, которых еще нет в базе
+// и данные которых не были удалены по запросу клиента.
+const mergeStagingTables = `
+	INSERT INTO staging_new (order_uid)
+	SELECT s.order_uid FROM staging_orders s
+	WHERE NOT EXISTS (SELECT 1 FROM orders o WHERE o.order_uid = s.order_uid)
+	  AND NOT EXISTS (SELECT 1 FROM erased_orders e WHERE e.order_uid = s.order_uid);
+
+	INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, document)
+	SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, document
+	FROM staging_orders WHERE order_uid IN (SELECT order_uid FROM staging_new);
+
+	INSERT INTO deliveries (order_uid, date_created, name, phone, zip, city, address, region, email)
+	SELECT order_uid, date_created, name, phone, zip, city, address, region, email
+	FROM staging_deliveries WHERE order_uid IN (SELECT order_uid FROM staging_new);
+
+	INSERT INTO payments (order_uid, date_created, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+	SELECT order_uid, date_created, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+	FROM staging_payments WHERE order_uid IN (SELECT order_uid FROM staging_new);
+
+	INSERT INTO items (order_uid, date_created, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
+	SELECT order_uid, date_created, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+	FROM staging_items WHERE order_uid IN (SELECT order_uid FROM staging_new);
+
+	INSERT INTO outbox (event_type, msg_id, payload)
+	SELECT '` + OutboxEventOrderAccepted + `', msg_id, payload
+	FROM staging_outbox WHERE order_uid IN (SELECT order_uid FROM staging_new);
+
+	INSERT INTO order_events (event_type, order_uid)
+	SELECT '` + OrderEventStored + `', order_uid
+	FROM staging_new ORDER BY order_uid;`
+
+// InsertOrdersBatch записывает пакет заказов за одну транзакцию: строки копируются командой COPY
+// во временные таблицы и переносятся в основные таблицы несколькими запросами INSERT ... SELECT.
+// Заказы, уже существующие в базе, и повторы внутри пакета пропускаются.
+// Возвращает множество идентификаторов заказов, которые были добавлены.
+func InsertOrdersBatch(ctx context.Context, batch []BatchOrder, db *sql.DB) (map[string]bool, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения соединения: %v", err)
+	}
+	defer conn.Close()
+
+	// COPY доступен только через соединение pgx, поэтому транзакция пакета выполняется на нем напрямую
+	var inserted map[string]bool
+	err = conn.Raw(func(driverConn any) error {
+		var err error
+		inserted, err = insertBatch(ctx, batch, driverConn.(*stdlib.Conn).Conn())
+		return err
+	})
+	return inserted, err
+}
+
+// insertBatch выполняет транзакцию пакетной вставки на соединении pgx.
+func insertBatch(ctx context.Context, batch []BatchOrder, conn *pgx.Conn) (map[string]bool, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, createStagingTables); err != nil {
+		return nil, fmt.Errorf("ошибка создания временных таблиц: %v", err)
+	}
+	if err := copyBatch(ctx, batch, tx); err != nil {
+		return nil, fmt.Errorf("ошибка копирования пакета: %v", err)
+	}
+	if _, err := tx.Exec(ctx, lockStagingOrders); err != nil {
+		return nil, fmt.Errorf("ошибка блокировки идентификаторов пакета: %v", err)
+	}
+	if _, err := tx.Exec(ctx, mergeStagingTables); err != nil {
+		return nil, fmt.Errorf("ошибка переноса пакета: %v", err)
+	}
+
+	rows, err := tx.Query(ctx, "SELECT order_uid FROM staging_new")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения добавленных заказов: %v", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения добавленных заказов: %v", err)
+	}
+	inserted := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		inserted[uid] = true
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return inserted, nil
+}
+
+// stagingTable строки одной временной таблицы пакета.
+type stagingTable struct {
+	name    string
+	columns []string
+	rows    [][]any
+}
+
+// copyBatch копирует строки пакета во временные таблицы.
+func copyBatch(ctx context.Context, batch []BatchOrder, tx pgx.Tx) error {
+	tables, err := stagingRows(batch)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{t.name}, t.columns, pgx.CopyFromRows(t.rows)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stagingRows готовит строки временных таблиц пакета. Из повторов одного заказа остается первый.
+// Персональные данные получателя шифруются и в документе, и в строках доставок, как при вставке одного заказа.
+func stagingRows(batch []BatchOrder) ([]stagingTable, error) {
+	seen := make(map[string]bool, len(batch))
+	var unique []BatchOrder
+	for _, b := range batch {
+		if !seen[b.Order.OrderUID] {
+			seen[b.Order.OrderUID] = true
+			unique = append(unique, b)
+		}
+	}
+
+	tables := []struct {
+		name    string
+		columns []string
+		rows    func(b BatchOrder) ([][]any, error)
+	}{
+		{"staging_orders", []string{"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "document"},
+			func(b BatchOrder) ([][]any, error) {
+				o := b.Order
+				document := b.Document
+				if len(document) == 0 {
+					var err error
+					if document, err = json.Marshal(o); err != nil {
+						return nil, err
+					}
+				}
+				document, err := pii.EncryptDocument(document)
+				if err != nil {
+					return nil, err
+				}
+				return [][]any{{o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID, o.DeliveryService, o.Shardkey, o.SMID, o.DateCreated, o.OOFShard, document}}, nil
+			}},
+		{"staging_deliveries", []string{"order_uid", "date_created", "name", "phone", "zip", "city", "address", "region", "email"},
+			func(b BatchOrder) ([][]any, error) {
+				d := pii.EncryptDelivery(b.Order.Delivery)
+				return [][]any{{b.Order.OrderUID, b.Order.DateCreated, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email}}, nil
+			}},
+		{"staging_payments", []string{"order_uid", "date_created", "transaction", "request_id", "currency", "provider", "amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"},
+			func(b BatchOrder) ([][]any, error) {
+				p := b.Order.Payment
+				return [][]any{{b.Order.OrderUID, b.Order.DateCreated, p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount, p.PaymentDT.Seconds(), p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee}}, nil
+			}},
+		{"staging_items", []string{"order_uid", "date_created", "chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status"},
+			func(b BatchOrder) ([][]any, error) {
+				var rows [][]any
+				for _, i := range b.Order.Items {
+					rows = append(rows, []any{b.Order.OrderUID, b.Order.DateCreated, i.ChrtID, i.TrackNumber, i.Price, i.RID, i.Name, i.Sale, i.Size, i.TotalPrice, i.NMID, i.Brand, i.Status})
+				}
+				return rows, nil
+			}},
+		{"staging_outbox", []string{"order_uid", "msg_id", "payload"},
+			func(b BatchOrder) ([][]any, error) {
+				payload, err := outboxPayload(b.Order)
+				if err != nil {
+					return nil, err
+				}
+				return [][]any{{b.Order.OrderUID, outboxMsgID(b.Order), payload}}, nil
+			}},
+	}
+
+	result := make([]stagingTable, 0, len(tables))
+	for _, t := range tables {
+		st := stagingTable{name: t.name, columns: t.columns}
+		for _, b := range unique {
+			r, err := t.rows(b)
+			if err != nil {
+				return nil, err
+			}
+			st.rows = append(st.rows, r...)
+		}
+		result = append(result, st)
+	}
+	return result, nil
+}
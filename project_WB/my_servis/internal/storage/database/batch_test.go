@@ -0,0 +1,92 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"main.go/internal/pii"
+)
+
+// setTestKeyring включает шифрование персональных данных на время теста.
+func setTestKeyring(t *testing.T) {
+	t.Helper()
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(`{"primary": "k1", "keys": {"k1": "`+key+`"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := pii.LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pii.SetKeyring(k)
+	t.Cleanup(func() { pii.SetKeyring(nil) })
+}
+
+// TestStagingRowsEncryptPII проверяет, что InsertOrdersBatch копирует персональные данные получателя
+// только в зашифрованном виде: в строки доставок, в документ заказа и в событие исходящей очереди.
+func TestStagingRowsEncryptPII(t *testing.T) {
+	setTestKeyring(t)
+	order := model.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: model.Delivery{Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com"},
+		Items: []model.Item{{ChrtID: 9934930, Name: "Mascaras", Brand: "Vivienne Sabo"}},
+	}
+	document, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, err := stagingRows([]BatchOrder{{Order: order, Document: document}, {Order: order}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]stagingTable, len(tables))
+	for _, table := range tables {
+		if len(table.rows) != 1 {
+			t.Errorf("%s: %d rows, want 1 for a repeated order", table.name, len(table.rows))
+		}
+		byName[table.name] = table
+	}
+
+	deliveries := byName["staging_deliveries"]
+	for i, column := range deliveries.columns {
+		value := deliveries.rows[0][i]
+		switch column {
+		case "name", "phone", "address", "email":
+			if s, _ := value.(string); !pii.IsEncrypted(s) {
+				t.Errorf("staging_deliveries.%s = %v, want enc:v1: value", column, value)
+			}
+		case "zip", "city", "region":
+			if s, _ := value.(string); pii.IsEncrypted(s) {
+				t.Errorf("staging_deliveries.%s is encrypted, want plaintext", column)
+			}
+		}
+	}
+
+	orders := byName["staging_orders"]
+	stored := orders.rows[0][len(orders.columns)-1].([]byte)
+	var doc struct {
+		Delivery model.Delivery `json:"delivery"`
+	}
+	if err := json.Unmarshal(stored, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !pii.IsEncrypted(doc.Delivery.Phone) || !pii.IsEncrypted(doc.Delivery.Email) || !pii.IsEncrypted(doc.Delivery.Name) {
+		t.Errorf("document delivery = %+v, want enc:v1: values", doc.Delivery)
+	}
+
+	for _, column := range [][]byte{stored, byName["staging_outbox"].rows[0][2].([]byte)} {
+		for _, plaintext := range []string{order.Delivery.Phone, order.Delivery.Email, order.Delivery.Address} {
+			if strings.Contains(string(column), plaintext) {
+				t.Errorf("plaintext %q found in %s", plaintext, column)
+			}
+		}
+	}
+}
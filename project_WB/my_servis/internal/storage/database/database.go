@@ -1,102 +1,158 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
-	config "main.go/internal"
-	model "main.go/orders_model"
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"github.com/jackc/pgx/v5/pgconn"
+	"main.go/internal/pii"
 )
 
-// Connect устанавливает соединение с базой данных и возвращает объект DB.
-func Connect(cfg config.DatabaseConfig) *sql.DB {
-	// Формируем строку подключения к базе данных
-	dbInfo := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
-		cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port, cfg.SSLMode)
-	// Открываем соединение с базой данных
-	db, err := sql.Open("postgres", dbInfo)
-	if err != nil {
-		log.Fatalf("Ошибка подключения к базе данных: %v", err)
-	}
-	// Создаем необходимые таблицы, если они еще не существуют
-	createTables(db)
-	return db
-}
-
 // OrderExists проверяет, существует ли заказ в базе данных.
-func OrderExists(orderUID string, db *sql.DB) (bool, error) {
+func OrderExists(ctx context.Context, orderUID string, db *sql.DB) (bool, error) {
 	var exists bool
 	// Выполняем запрос к базе данных, чтобы узнать, существует ли заказ с указанным orderUID
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)", orderUID).Scan(&exists)
+	err := db.QueryRowContext(ctx, stmtOrderExists, orderUID).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 	return exists, nil
 }
 
-// InsertOrderToDB вставляет заказ в базу данных
-func InsertOrderToDB(order model.Order, db *sql.DB) error {
+// execer общий интерфейс *sql.DB и *sql.Tx для выполнения запросов без результата.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ErrInvalidPath возвращается, если выражение JSON path некорректно.
+var ErrInvalidPath = errors.New("некорректное выражение JSON path")
+
+// ErrOrderExists возвращается при вставке заказа, идентификатор которого уже есть в базе данных.
+var ErrOrderExists = errors.New("заказ с таким идентификатором уже существует")
+
+// orderUIDLockClass первый ключ рекомендательных блокировок идентификаторов заказов.
+// Первичный ключ секционированной таблицы orders включает date_created и не гарантирует
+// уникальность order_uid, поэтому вставки заказа с одним идентификатором упорядочиваются
+// блокировкой pg_advisory_xact_lock по хешу идентификатора до конца транзакции.
+const orderUIDLockClass = "1869771375"
+
+// InsertOrderToDB вставляет заказ в базу данных.
+// document - исходный JSON-документ заказа от производителя; он сохраняется целиком, включая поля,
+// которых нет в нормализованных таблицах. Если document пуст, сохраняется сериализованный заказ.
+// Все строки заказа, событие order.accepted в исходящей очереди и событие order.stored для вебхуков
+// записываются в одной транзакции.
+func InsertOrderToDB(ctx context.Context, order model.Order, document []byte, db *sql.DB) error {
+	if len(document) == 0 {
+		var err error
+		if document, err = json.Marshal(order); err != nil {
+			return fmt.Errorf("ошибка сериализации заказа: %v", err)
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertOrderRows(ctx, order, document, tx); err != nil {
+		return err
+	}
+
+	// Записываем событие для публикации в исходящую очередь
+	if err := insertOutboxEvent(ctx, order, tx); err != nil {
+		return fmt.Errorf("ошибка записи события в outbox: %v", err)
+	}
+
+	// Записываем событие для вебхуков; оно применяется в основной базе данных после фиксации
+	if err := insertOrderEvent(ctx, order, tx); err != nil {
+		return fmt.Errorf("ошибка записи события заказа: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return nil
+}
+
+// insertOrderRows вставляет строки заказа, доставки, платежа и товаров.
+// Дочерние строки получают date_created заказа, по которому секционированы все таблицы заказа.
+// Выполняется в транзакции: если заказ с тем же идентификатором уже есть, возвращается ErrOrderExists.
+func insertOrderRows(ctx context.Context, order model.Order, document []byte, db execer) error {
 	orderID := order.OrderUID
 
+	// Персональные данные получателя хранятся зашифрованными и в документе, и в таблице доставок
+	document, err := pii.EncryptDocument(document)
+	if err != nil {
+		return err
+	}
+
 	// Вставляем информацию о заказе
-	if err := insertOrder(order, db); err != nil {
-		return fmt.Errorf("ошибка вставки заказа: %v", err)
+	if err := insertOrder(ctx, order, document, db); err != nil {
+		return fmt.Errorf("ошибка вставки заказа: %w", err)
 	}
 
 	// Вставляем информацию о доставке
-	if err := insertDelivery(order.Delivery, orderID, db); err != nil {
+	if err := insertDelivery(ctx, pii.EncryptDelivery(order.Delivery), orderID, order.DateCreated, db); err != nil {
 		return fmt.Errorf("ошибка вставки доставки: %v", err)
 	}
 
 	// Вставляем информацию о платеже
-	if err := insertPayment(order.Payment, orderID, db); err != nil {
+	if err := insertPayment(ctx, order.Payment, orderID, order.DateCreated, db); err != nil {
 		return fmt.Errorf("ошибка вставки платежа: %v", err)
 	}
 
 	// Вставляем информацию о товарах
-	if err := insertItems(order.Items, orderID, db); err != nil {
+	if err := insertItems(ctx, order.Items, orderID, order.DateCreated, db); err != nil {
 		return fmt.Errorf("ошибка вставки товаров: %v", err)
 	}
-
 	return nil
 }
 
-// insertOrder вставляет информацию о заказе в базу данных.
-func insertOrder(order model.Order, db *sql.DB) error {
-	query := `
-		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	_, err := db.Exec(query, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey, order.SMID, order.DateCreated, order.OOFShard)
-	return err
+// insertOrder вставляет информацию о заказе и его JSON-документ в базу данных.
+// Блокировка идентификатора берется отдельным запросом, чтобы проверка существования заказа
+// при вставке видела заказы, зафиксированные конкурирующими транзакциями.
+func insertOrder(ctx context.Context, order model.Order, document []byte, db execer) error {
+	if _, err := db.ExecContext(ctx, stmtLockOrderUID, order.OrderUID); err != nil {
+		return err
+	}
+	res, err := db.ExecContext(ctx, stmtInsertOrder, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey, order.SMID, order.DateCreated, order.OOFShard, document)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrOrderExists
+	}
+	return nil
 }
 
 // insertDelivery вставляет информацию о доставке в базу данных.
-func insertDelivery(delivery model.Delivery, orderID string, db *sql.DB) error {
-	query := `
-		INSERT INTO deliveries (name, phone, zip, city, address, region, email, order_uid)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err := db.Exec(query, delivery.Name, delivery.Phone, delivery.Zip, delivery.City, delivery.Address, delivery.Region, delivery.Email, orderID)
+func insertDelivery(ctx context.Context, delivery model.Delivery, orderID string, dateCreated time.Time, db execer) error {
+	_, err := db.ExecContext(ctx, stmtInsertDelivery, delivery.Name, delivery.Phone, delivery.Zip, delivery.City, delivery.Address, delivery.Region, delivery.Email, orderID, dateCreated)
 	return err
 }
 
 // insertPayment вставляет информацию о платеже в базу данных.
-func insertPayment(payment model.Payment, orderID string, db *sql.DB) error {
-	query := `
-		INSERT INTO payments (transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee, order_uid)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	_, err := db.Exec(query, payment.Transaction, payment.RequestID, payment.Currency, payment.Provider, payment.Amount, payment.PaymentDT, payment.Bank, payment.DeliveryCost, payment.GoodsTotal, payment.CustomFee, orderID)
+func insertPayment(ctx context.Context, payment model.Payment, orderID string, dateCreated time.Time, db execer) error {
+	_, err := db.ExecContext(ctx, stmtInsertPayment, payment.Transaction, payment.RequestID, payment.Currency, payment.Provider, payment.Amount, payment.PaymentDT, payment.Bank, payment.DeliveryCost, payment.GoodsTotal, payment.CustomFee, orderID, dateCreated)
 	return err
 }
 
 // insertItems вставляет информацию о товарах в базу данных.
-func insertItems(items []model.Item, orderID string, db *sql.DB) error {
-	query := `
-		INSERT INTO items (chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, order_uid)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+func insertItems(ctx context.Context, items []model.Item, orderID string, dateCreated time.Time, db execer) error {
 	for _, item := range items {
-		_, err := db.Exec(query, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status, orderID)
+		_, err := db.ExecContext(ctx, stmtInsertItem, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status, orderID, dateCreated)
 		if err != nil {
 			return err
 		}
@@ -104,10 +160,185 @@ func insertItems(items []model.Item, orderID string, db *sql.DB) error {
 	return nil
 }
 
+// OutboxEventOrderAccepted тип события о принятом заказе в исходящей очереди.
+const OutboxEventOrderAccepted = "order.accepted"
+
+// OutboxEvent тело события о принятом заказе.
+type OutboxEvent struct {
+	Type       string      `json:"type"`
+	OrderUID   string      `json:"order_uid"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Order      model.Order `json:"order"`
+}
+
+// insertOutboxEvent записывает событие order.accepted в исходящую очередь.
+// msg_id детерминирован, поэтому повторная публикация одного события отбрасывается JetStream.
+func insertOutboxEvent(ctx context.Context, order model.Order, db execer) error {
+	payload, err := outboxPayload(order)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, stmtInsertOutbox, OutboxEventOrderAccepted, outboxMsgID(order), payload)
+	return err
+}
+
+// outboxPayload сериализует событие order.accepted для заказа.
+// Персональные данные в очереди зашифрованы; ретранслятор расшифровывает их перед публикацией.
+func outboxPayload(order model.Order) ([]byte, error) {
+	order.Delivery = pii.EncryptDelivery(order.Delivery)
+	return json.Marshal(OutboxEvent{
+		Type:       OutboxEventOrderAccepted,
+		OrderUID:   order.OrderUID,
+		OccurredAt: time.Now().UTC(),
+		Order:      order,
+	})
+}
+
+// outboxMsgID возвращает идентификатор сообщения order.accepted для дедупликации в JetStream.
+func outboxMsgID(order model.Order) string {
+	return OutboxEventOrderAccepted + ":" + order.OrderUID
+}
+
 // CacheAllOrdersFromDB кэширует все заказы из базы данных
-func CacheAllOrdersFromDB(db *sql.DB) (map[string]model.Order, error) {
+func CacheAllOrdersFromDB(ctx context.Context, db *sql.DB) (map[string]model.Order, error) {
+	return queryDocuments(ctx, db, "")
+}
+
+// GetOrderFromDB получает один заказ по идентификатору из столбца document.
+// Если заказа нет, возвращается sql.ErrNoRows.
+func GetOrderFromDB(ctx context.Context, orderUID string, db *sql.DB) (model.Order, error) {
+	var document []byte
+	err := db.QueryRowContext(ctx, stmtOrderDocument, orderUID).Scan(&document)
+	if err != nil {
+		return model.Order{}, err
+	}
+	return decodeDocument(document)
+}
+
+// GetOrdersFromDB получает заказы с указанными идентификаторами из базы данных.
+// Отсутствующие в базе идентификаторы не попадают в результат.
+func GetOrdersFromDB(ctx context.Context, orderUIDs []string, db *sql.DB) (map[string]model.Order, error) {
+	if len(orderUIDs) == 0 {
+		return map[string]model.Order{}, nil
+	}
+	return queryDocuments(ctx, db, "AND order_uid = ANY($1)", orderUIDs)
+}
+
+// LookupOrdersFromDB получает заказы, идентификатор или трек-номер которых есть в ids, одним запросом.
+func LookupOrdersFromDB(ctx context.Context, ids []string, db *sql.DB) (map[string]model.Order, error) {
+	if len(ids) == 0 {
+		return map[string]model.Order{}, nil
+	}
+	return queryDocuments(ctx, db, "AND (order_uid = ANY($1) OR track_number = ANY($1))", ids)
+}
+
+// QueryOrdersByPath возвращает страницу заказов, документ которых удовлетворяет выражению
+// JSON path (оператор @?), и общее количество найденных заказов. Например:
+// $.items[*] ? (@.brand == "Vivienne Sabo") или $.payment ? (@.amount > 1000).
+func QueryOrdersByPath(ctx context.Context, path string, offset, limit int, db *sql.DB) ([]model.Order, int, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT document, COUNT(*) OVER ()
+		FROM orders
+		WHERE document @? $1::jsonpath
+		ORDER BY date_created DESC, order_uid
+		OFFSET $2 LIMIT $3`, path, offset, limit)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && (pgErr.Code == "42601" || strings.HasPrefix(pgErr.Code, "22")) {
+			return nil, 0, fmt.Errorf("%w: %s", ErrInvalidPath, pgErr.Message)
+		}
+		return nil, 0, fmt.Errorf("error querying orders by path: %v", err)
+	}
+	defer rows.Close()
+
+	result := []model.Order{}
+	total := 0
+	for rows.Next() {
+		var document []byte
+		if err := rows.Scan(&document, &total); err != nil {
+			return nil, 0, fmt.Errorf("error scanning order document: %v", err)
+		}
+		order, err := decodeDocument(document)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error querying orders by path: %v", err)
+	}
+	if len(result) == 0 && offset > 0 {
+		// Страница за пределами выборки: оконная функция не вернула ни одной строки
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders WHERE document @? $1::jsonpath`, path).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("error counting orders by path: %v", err)
+		}
+	}
+	return result, total, nil
+}
+
+// BackfillDocuments заполняет столбец document для заказов, сохраненных до его появления,
+// собирая документ из нормализованных таблиц.
+func BackfillDocuments(ctx context.Context, db *sql.DB) error {
+	orders, err := queryOrders(ctx, db,
+		"WHERE o.document IS NULL",
+		"WHERE order_uid IN (SELECT order_uid FROM orders WHERE document IS NULL)")
+	if err != nil {
+		return err
+	}
+	for uid, order := range orders {
+		order.Delivery = pii.EncryptDelivery(order.Delivery)
+		document, err := json.Marshal(order)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации заказа %s: %v", uid, err)
+		}
+		if _, err := db.ExecContext(ctx, "UPDATE orders SET document = $1 WHERE order_uid = $2", document, uid); err != nil {
+			return fmt.Errorf("ошибка заполнения документа заказа %s: %v", uid, err)
+		}
+	}
+	return nil
+}
+
+// queryDocuments читает заказы из столбца document; cond дополняет условие отбора через AND.
+func queryDocuments(ctx context.Context, db *sql.DB, cond string, args ...any) (map[string]model.Order, error) {
+	rows, err := db.QueryContext(ctx, "SELECT document FROM orders WHERE document IS NOT NULL "+cond, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching orders from database: %v", err)
+	}
+	defer rows.Close()
+
+	orders := make(map[string]model.Order)
+	for rows.Next() {
+		var document []byte
+		if err := rows.Scan(&document); err != nil {
+			return nil, fmt.Errorf("error scanning order document: %v", err)
+		}
+		order, err := decodeDocument(document)
+		if err != nil {
+			return nil, err
+		}
+		orders[order.OrderUID] = order
+	}
+	return orders, rows.Err()
+}
+
+// decodeDocument разбирает сохраненный документ заказа, приводя его к текущей версии схемы.
+// Неизвестные поля документа сохраняются в базе, но не попадают в модель.
+func decodeDocument(document []byte) (model.Order, error) {
+	order, err := model.DecodeStored(document)
+	if err != nil {
+		return order, fmt.Errorf("ошибка разбора документа заказа: %v", err)
+	}
+	if err := pii.DecryptDelivery(&order.Delivery); err != nil {
+		return order, fmt.Errorf("ошибка расшифровки персональных данных заказа %s: %v", order.OrderUID, err)
+	}
+	return order, nil
+}
+
+// queryOrders собирает заказы вместе с доставкой, платежом и товарами из нормализованных таблиц.
+// orderCond и itemsCond задают условия отбора для таблиц orders и items с общими аргументами args.
+func queryOrders(ctx context.Context, db *sql.DB, orderCond, itemsCond string, args ...any) (map[string]model.Order, error) {
 	orderCache := make(map[string]model.Order)
-	itemsMap, err := fetchItemsMap(db)
+	itemsMap, err := fetchItemsMap(ctx, db, itemsCond, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -118,8 +349,9 @@ func CacheAllOrdersFromDB(db *sql.DB) (map[string]model.Order, error) {
 		       p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
 		FROM orders o
 		JOIN deliveries d ON o.order_uid = d.order_uid
-		JOIN payments p ON o.order_uid = p.order_uid`
-	rows, err := db.Query(query)
+		JOIN payments p ON o.order_uid = p.order_uid
+		` + orderCond
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching orders from database: %v", err)
 	}
@@ -133,6 +365,11 @@ func CacheAllOrdersFromDB(db *sql.DB) (map[string]model.Order, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error scanning order row: %v", err)
 		}
+		if err := pii.DecryptDelivery(&order.Delivery); err != nil {
+			return nil, fmt.Errorf("ошибка расшифровки персональных данных заказа %s: %v", order.OrderUID, err)
+		}
+		order.SchemaVersion = model.CurrentSchemaVersion
+		order.DateCreated = order.DateCreated.UTC()
 		order.Items = itemsMap[order.OrderUID]
 		orderCache[order.OrderUID] = order
 	}
@@ -141,12 +378,13 @@ func CacheAllOrdersFromDB(db *sql.DB) (map[string]model.Order, error) {
 }
 
 // fetchItemsMap извлекает информацию о товарах из базы данных и возвращает ее в виде map
-func fetchItemsMap(db *sql.DB) (map[string][]model.Item, error) {
+func fetchItemsMap(ctx context.Context, db *sql.DB, cond string, args ...any) (map[string][]model.Item, error) {
 	itemsMap := make(map[string][]model.Item)
 	query := `
 		SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-		FROM items`
-	rows, err := db.Query(query)
+		FROM items
+		` + cond
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching items from database: %v", err)
 	}
@@ -164,11 +402,14 @@ func fetchItemsMap(db *sql.DB) (map[string][]model.Item, error) {
 	return itemsMap, nil
 }
 
-// createTables создает необходимые таблицы в базе данных, если они еще не существуют.
-func createTables(db *sql.DB) {
-	createOrdersTable := `
+// createOrderTables создает таблицы заказа, секционированные по месяцу date_created.
+// Первичные ключи включают date_created, как того требует секционирование; внешние ключи
+// между таблицами не создаются, чтобы секции за месяц можно было отключать и удалять независимо.
+// Целостность заказа обеспечивает транзакция вставки, а уникальность order_uid - блокировка
+// идентификатора в ней (см. orderUIDLockClass).
+const createOrderTables = `
 	CREATE TABLE IF NOT EXISTS orders (
-		order_uid VARCHAR(255) PRIMARY KEY,
+		order_uid VARCHAR(255),
 		track_number VARCHAR(255),
 		entry VARCHAR(255),
 		locale VARCHAR(255),
@@ -177,70 +418,169 @@ func createTables(db *sql.DB) {
 		delivery_service VARCHAR(255),
 		shardkey VARCHAR(255),
 		sm_id INT,
-		date_created TIMESTAMP,
-		oof_shard VARCHAR(255)
-	);`
+		date_created TIMESTAMPTZ,
+		oof_shard VARCHAR(255),
+		document JSONB,
+		PRIMARY KEY (order_uid, date_created)
+	) PARTITION BY RANGE (date_created);
 
-	createDeliveriesTable := `
 	CREATE TABLE IF NOT EXISTS deliveries (
-		order_uid VARCHAR(255) PRIMARY KEY REFERENCES orders(order_uid),
-		name VARCHAR(255),
-		phone VARCHAR(255),
+		order_uid VARCHAR(255),
+		date_created TIMESTAMPTZ,
+		name TEXT,
+		phone TEXT,
 		zip VARCHAR(255),
 		city VARCHAR(255),
-		address VARCHAR(255),
+		address TEXT,
 		region VARCHAR(255),
-		email VARCHAR(255)
-	);`
+		email TEXT,
+		PRIMARY KEY (order_uid, date_created)
+	) PARTITION BY RANGE (date_created);
 
-	createPaymentsTable := `
 	CREATE TABLE IF NOT EXISTS payments (
-		order_uid VARCHAR(255) PRIMARY KEY REFERENCES orders(order_uid),
+		order_uid VARCHAR(255),
+		date_created TIMESTAMPTZ,
 		transaction VARCHAR(255),
 		request_id VARCHAR(255),
 		currency VARCHAR(255),
 		provider VARCHAR(255),
-		amount INT,
+		amount BIGINT,
 		payment_dt BIGINT,
 		bank VARCHAR(255),
-		delivery_cost INT,
-		goods_total INT,
-		custom_fee INT
-	);`
+		delivery_cost BIGINT,
+		goods_total BIGINT,
+		custom_fee BIGINT,
+		PRIMARY KEY (order_uid, date_created)
+	) PARTITION BY RANGE (date_created);
 
-	createItemsTable := `
 	CREATE TABLE IF NOT EXISTS items (
-		order_uid VARCHAR(255) REFERENCES orders(order_uid),
+		order_uid VARCHAR(255) NOT NULL,
+		date_created TIMESTAMPTZ NOT NULL,
 		chrt_id INT,
 		track_number VARCHAR(255),
-		price INT,
+		price BIGINT,
 		rid VARCHAR(255),
 		name VARCHAR(255),
 		sale INT,
 		size VARCHAR(255),
-		total_price INT,
+		total_price BIGINT,
 		nm_id INT,
 		brand VARCHAR(255),
 		status INT
-	);`
+	) PARTITION BY RANGE (date_created);`
+
+// createTables создает необходимые таблицы в базе данных, если они еще не существуют.
+func createTables(db *sql.DB) {
+	// Таблицы, созданные до появления столбца document, получают его перед переходом на секции
+	addDocumentColumn := `ALTER TABLE orders ADD COLUMN IF NOT EXISTS document JSONB;`
+
+	createOrderIndexes := `
+	CREATE INDEX IF NOT EXISTS orders_document_path_idx ON orders USING GIN (document jsonb_path_ops);
+	CREATE INDEX IF NOT EXISTS orders_document_keys_idx ON orders USING GIN (document);
+	CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid);
+	CREATE INDEX IF NOT EXISTS orders_track_number_idx ON orders (track_number);`
+
+	// Таблицы, созданные до перехода на денежные типы и время с часовым поясом,
+	// приводятся к новым типам столбцов. Старое время без зоны считается UTC.
+	// Столбцы с персональными данными расширяются до TEXT: зашифрованные значения длиннее 255 символов.
+	migrateColumnTypes := `
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns
+		           WHERE table_name = 'orders' AND column_name = 'date_created' AND data_type = 'timestamp without time zone') THEN
+			ALTER TABLE orders ALTER COLUMN date_created TYPE TIMESTAMPTZ USING date_created AT TIME ZONE 'UTC';
+		END IF;
+		IF EXISTS (SELECT 1 FROM information_schema.columns
+		           WHERE table_name = 'payments' AND column_name = 'amount' AND data_type = 'integer') THEN
+			ALTER TABLE payments
+				ALTER COLUMN amount TYPE BIGINT,
+				ALTER COLUMN delivery_cost TYPE BIGINT,
+				ALTER COLUMN goods_total TYPE BIGINT,
+				ALTER COLUMN custom_fee TYPE BIGINT;
+		END IF;
+		IF EXISTS (SELECT 1 FROM information_schema.columns
+		           WHERE table_name = 'items' AND column_name = 'price' AND data_type = 'integer') THEN
+			ALTER TABLE items
+				ALTER COLUMN price TYPE BIGINT,
+				ALTER COLUMN total_price TYPE BIGINT;
+		END IF;
+		IF EXISTS (SELECT 1 FROM information_schema.columns
+		           WHERE table_name = 'deliveries' AND column_name = 'phone' AND data_type = 'character varying') THEN
+			ALTER TABLE deliveries
+				ALTER COLUMN name TYPE TEXT,
+				ALTER COLUMN phone TYPE TEXT,
+				ALTER COLUMN address TYPE TEXT,
+				ALTER COLUMN email TYPE TEXT;
+		END IF;
+	END $$;`
+
+	createOutboxTable := `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		event_type VARCHAR(255) NOT NULL,
+		msg_id VARCHAR(255) NOT NULL,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		sent_at TIMESTAMPTZ
+	);
+	ALTER TABLE outbox
+		ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS last_error TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (id) WHERE sent_at IS NULL;`
+
+	_, err := db.Exec(createOrderTables)
+	if err != nil {
+		log.Fatalf("Error creating order tables: %v", err)
+	}
+
+	_, err = db.Exec(addDocumentColumn)
+	if err != nil {
+		log.Fatalf("Error adding document column: %v", err)
+	}
+
+	_, err = db.Exec(migrateColumnTypes)
+	if err != nil {
+		log.Fatalf("Error migrating column types: %v", err)
+	}
+
+	err = partitionLegacyTables(context.Background(), db)
+	if err != nil {
+		log.Fatalf("Error partitioning order tables: %v", err)
+	}
+
+	_, err = db.Exec(createDefaultPartitions)
+	if err != nil {
+		log.Fatalf("Error creating default partitions: %v", err)
+	}
+
+	_, err = db.Exec(createOrderIndexes)
+	if err != nil {
+		log.Fatalf("Error creating order indexes: %v", err)
+	}
+
+	_, err = db.Exec(createSearchIndexes)
+	if err != nil {
+		log.Fatalf("Error creating search indexes: %v", err)
+	}
 
-	_, err := db.Exec(createOrdersTable)
+	_, err = db.Exec(createPartitionHolds)
 	if err != nil {
-		log.Fatalf("Error creating orders table: %v", err)
+		log.Fatalf("Error creating partition holds table: %v", err)
 	}
 
-	_, err = db.Exec(createDeliveriesTable)
+	_, err = db.Exec(createOutboxTable)
 	if err != nil {
-		log.Fatalf("Error creating deliveries table: %v", err)
+		log.Fatalf("Error creating outbox table: %v", err)
 	}
 
-	_, err = db.Exec(createPaymentsTable)
+	_, err = db.Exec(createErasedOrders)
 	if err != nil {
-		log.Fatalf("Error creating payments table: %v", err)
+		log.Fatalf("Error creating erased orders table: %v", err)
 	}
 
-	_, err = db.Exec(createItemsTable)
+	_, err = db.Exec(createOrderEvents)
 	if err != nil {
-		log.Fatalf("Error creating items table: %v", err)
+		log.Fatalf("Error creating order events table: %v", err)
 	}
 }
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+// fakeOrders имитирует вставку заказов в таблицу orders: заказ с уже сохраненным
+// идентификатором не вставляется, как при условии NOT EXISTS в stmtInsertOrder.
+type fakeOrders struct {
+	locked map[string]bool
+	stored map[string]time.Time
+}
+
+func (f *fakeOrders) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	switch query {
+	case stmtLockOrderUID:
+		f.locked[args[0].(string)] = true
+	case stmtInsertOrder:
+		uid := args[0].(string)
+		if !f.locked[uid] {
+			return nil, errors.New("order_uid is not locked")
+		}
+		if _, ok := f.stored[uid]; ok {
+			return driverResult(0), nil
+		}
+		f.stored[uid] = args[9].(time.Time)
+	}
+	return driverResult(1), nil
+}
+
+// driverResult результат запроса с заданным количеством измененных строк.
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, nil }
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func TestInsertOrderRowsDuplicate(t *testing.T) {
+	db := &fakeOrders{locked: map[string]bool{}, stored: map[string]time.Time{}}
+	january := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	if err := insertOrderRows(context.Background(), model.Order{OrderUID: "order_1", DateCreated: january}, []byte("{}"), db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Тот же идентификатор с другой датой попал бы в другую секцию, но вставка отклоняется
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	err := insertOrderRows(context.Background(), model.Order{OrderUID: "order_1", DateCreated: march}, []byte("{}"), db)
+	if !errors.Is(err, ErrOrderExists) {
+		t.Fatalf("second insert error = %v, want ErrOrderExists", err)
+	}
+	if !db.stored["order_1"].Equal(january) {
+		t.Errorf("stored order date = %v, want %v", db.stored["order_1"], january)
+	}
+}
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"main.go/internal/deadletter"
+	"main.go/internal/pii"
+)
+
+// createErasedOrders создает таблицу отметок об удалении персональных данных заказов.
+// Отметка не дает повторно записать данные заказа при повторной доставке сообщения из NATS
+// или при восстановлении архива.
+const createErasedOrders = `
+	CREATE TABLE IF NOT EXISTS erased_orders (
+		order_uid VARCHAR(255) PRIMARY KEY,
+		erased_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`
+
+// anonymousDelivery значения полей доставки с персональными данными после удаления.
+// Город и регион сохраняются: по ним ведется статистика доставки.
+const anonymousDelivery = `{"name": "", "phone": "", "zip": "", "address": "", "email": ""}`
+
+// eraseOrderRows обезличивают заказы $1 и их события в исходящей очереди и ставят отметки об удалении.
+// Запросы с параметрами выполняются по одному: расширенный протокол не допускает нескольких команд в запросе.
+var eraseOrderRows = []string{`
+	UPDATE orders SET customer_id = '',
+		document = jsonb_set(document || '{"customer_id": ""}', '{delivery}', COALESCE(document->'delivery', '{}') || '` + anonymousDelivery + `')
+	WHERE order_uid = ANY($1)`, `
+	UPDATE deliveries SET name = '', phone = '', zip = '', address = '', email = ''
+	WHERE order_uid = ANY($1)`, `
+	UPDATE outbox SET
+		payload = jsonb_set(jsonb_set(payload, '{order,customer_id}', '""'), '{order,delivery}', COALESCE(payload#>'{order,delivery}', '{}') || '` + anonymousDelivery + `')
+	WHERE payload->>'order_uid' = ANY($1)`, `
+	INSERT INTO erased_orders (order_uid) SELECT unnest($1::text[]) ON CONFLICT DO NOTHING`,
+}
+
+// ErrEmptySubject возвращается, если субъект данных не задан ни идентификатором клиента, ни email.
+var ErrEmptySubject = errors.New("не задан ни идентификатор клиента, ни email")
+
+// Subject субъект персональных данных: клиент с идентификатором CustomerID или получатель с адресом Email.
+// Если заданы оба поля, в выборку попадают заказы, подходящие под любое из них.
+type Subject struct {
+	CustomerID string `json:"customer_id,omitempty"`
+	Email      string `json:"email,omitempty"`
+}
+
+// SubjectExport выгрузка всех заказов субъекта данных и его сообщений, которые не удалось разобрать.
+type SubjectExport struct {
+	Subject     Subject              `json:"subject"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	Orders      []model.Order        `json:"orders"`
+	DeadLetters []deadletter.Message `json:"dead_letters"`
+}
+
+// OrderErased сообщает, удалены ли персональные данные заказа.
+func OrderErased(ctx context.Context, orderUID string, db *sql.DB) (bool, error) {
+	var erased bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM erased_orders WHERE order_uid = $1)", orderUID).Scan(&erased)
+	return erased, err
+}
+
+// subjectOrders возвращает идентификаторы заказов субъекта в базе данных шарда.
+// Email хранится зашифрованным, поэтому адреса сравниваются после расшифровки при полном просмотре доставок.
+func subjectOrders(ctx context.Context, subject Subject, db *sql.DB) ([]string, error) {
+	var uids []string
+	if subject.CustomerID != "" {
+		rows, err := db.QueryContext(ctx, "SELECT order_uid FROM orders WHERE customer_id = $1", subject.CustomerID)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка поиска заказов клиента: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var uid string
+			if err := rows.Scan(&uid); err != nil {
+				return nil, fmt.Errorf("ошибка поиска заказов клиента: %v", err)
+			}
+			uids = append(uids, uid)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("ошибка поиска заказов клиента: %v", err)
+		}
+	}
+
+	if email := strings.TrimSpace(subject.Email); email != "" {
+		rows, err := db.QueryContext(ctx, "SELECT order_uid, email FROM deliveries WHERE email <> ''")
+		if err != nil {
+			return nil, fmt.Errorf("ошибка поиска заказов по email: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var uid string
+			var d model.Delivery
+			if err := rows.Scan(&uid, &d.Email); err != nil {
+				return nil, fmt.Errorf("ошибка поиска заказов по email: %v", err)
+			}
+			if err := pii.DecryptDelivery(&d); err != nil {
+				return nil, fmt.Errorf("заказ %s: %v", uid, err)
+			}
+			if strings.EqualFold(strings.TrimSpace(d.Email), email) {
+				uids = append(uids, uid)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("ошибка поиска заказов по email: %v", err)
+		}
+	}
+
+	// Заказ может подойти и по идентификатору клиента, и по email
+	slices.Sort(uids)
+	return slices.Compact(uids), nil
+}
+
+// eraseOrders обезличивает заказы одной транзакцией и ставит отметки об их удалении.
+func eraseOrders(ctx context.Context, orderUIDs []string, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+	if err := anonymizeOrders(ctx, orderUIDs, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return nil
+}
+
+// reapplyErasures повторно обезличивает заказы с отметкой об удалении, например после подключения
+// отключенных секций, сохраненных до удаления данных.
+func reapplyErasures(ctx context.Context, tx *sql.Tx) error {
+	var uids []string
+	rows, err := tx.QueryContext(ctx, `
+		SELECT e.order_uid FROM erased_orders e
+		JOIN deliveries d ON d.order_uid = e.order_uid
+		WHERE d.name <> '' OR d.phone <> '' OR d.address <> '' OR d.email <> ''`)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения отметок об удалении: %v", err)
+	}
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка чтения отметок об удалении: %v", err)
+		}
+		uids = append(uids, uid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка чтения отметок об удалении: %v", err)
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+	return anonymizeOrders(ctx, uids, tx)
+}
+
+// anonymizeOrders выполняет запросы eraseOrderRows для заказов в транзакции.
+func anonymizeOrders(ctx context.Context, orderUIDs []string, tx *sql.Tx) error {
+	for _, query := range eraseOrderRows {
+		if _, err := tx.ExecContext(ctx, query, orderUIDs); err != nil {
+			return fmt.Errorf("ошибка обезличивания заказов: %v", err)
+		}
+	}
+	return nil
+}
+
+// EraseSubject обезличивает заказы субъекта во всех шардах: персональные данные доставки
+// и идентификатор клиента стираются в таблицах, документах и событиях исходящей очереди,
+// а заказ получает отметку об удалении, которая не дает записать его данные повторно.
+// Возвращает идентификаторы обезличенных заказов.
+func (s *Shards) EraseSubject(ctx context.Context, subject Subject) ([]string, error) {
+	if subject.CustomerID == "" && strings.TrimSpace(subject.Email) == "" {
+		return nil, ErrEmptySubject
+	}
+	var mu sync.Mutex
+	var erased []string
+	err := s.gather(func(name string) error {
+		uids, err := subjectOrders(ctx, subject, s.dbs[name])
+		if err != nil || len(uids) == 0 {
+			return err
+		}
+		if err := eraseOrders(ctx, uids, s.dbs[name]); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		erased = append(erased, uids...)
+		return nil
+	})
+	sort.Strings(erased)
+	return erased, err
+}
+
+// ExportSubject собирает все заказы субъекта из всех шардов, от новых к старым.
+// Данные выгружаются полностью, без маскирования: выгрузка предназначена самому субъекту.
+func (s *Shards) ExportSubject(ctx context.Context, subject Subject) (SubjectExport, error) {
+	export := SubjectExport{Subject: subject, GeneratedAt: time.Now().UTC(), Orders: []model.Order{}, DeadLetters: []deadletter.Message{}}
+	if subject.CustomerID == "" && strings.TrimSpace(subject.Email) == "" {
+		return export, ErrEmptySubject
+	}
+	var mu sync.Mutex
+	err := s.gather(func(name string) error {
+		uids, err := subjectOrders(ctx, subject, s.dbs[name])
+		if err != nil || len(uids) == 0 {
+			return err
+		}
+		orders, err := GetOrdersFromDB(ctx, uids, s.dbs[name])
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, order := range orders {
+			export.Orders = append(export.Orders, order)
+		}
+		return nil
+	})
+	sort.Slice(export.Orders, func(i, j int) bool {
+		if !export.Orders[i].DateCreated.Equal(export.Orders[j].DateCreated) {
+			return export.Orders[i].DateCreated.After(export.Orders[j].DateCreated)
+		}
+		return export.Orders[i].OrderUID < export.Orders[j].OrderUID
+	})
+	return export, err
+}
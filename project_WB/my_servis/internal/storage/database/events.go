@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+// OrderEventStored тип события о сохранении нового заказа.
+const OrderEventStored = "order.stored"
+
+// createOrderEvents создает очередь событий о сохраненных заказах и отметки об их применении.
+// События записываются в базу данных шарда в транзакции вставки заказа, а применяются
+// (вебхуки, статистика) в основной базе данных, поэтому одна транзакция на обе базы невозможна.
+// Отметка о применении пишется в основной базе в одной транзакции с результатом применения
+// и не дает применить событие повторно, если сервис упадет до его удаления из шарда.
+const createOrderEvents = `
+	CREATE TABLE IF NOT EXISTS order_events (
+		id BIGSERIAL PRIMARY KEY,
+		event_type VARCHAR(255) NOT NULL,
+		order_uid VARCHAR(255) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		failed_at TIMESTAMPTZ,
+		last_error TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS order_events_pending_idx ON order_events (id) WHERE failed_at IS NULL;
+	CREATE INDEX IF NOT EXISTS order_events_order_uid_idx ON order_events (order_uid);
+
+	CREATE TABLE IF NOT EXISTS order_events_applied (
+		event_id VARCHAR(255) PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`
+
+// OrderEvent событие о сохраненном заказе, ожидающее применения в основной базе данных.
+type OrderEvent struct {
+	ID        string // ID идентификатор события: тип и идентификатор заказа
+	Type      string
+	CreatedAt time.Time
+	Order     model.Order
+}
+
+// insertOrderEvent записывает событие о сохранении заказа в очередь шарда.
+func insertOrderEvent(ctx context.Context, order model.Order, db execer) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO order_events (event_type, order_uid) VALUES ($1, $2)`, OrderEventStored, order.OrderUID)
+	return err
+}
+
+// pendingOrderEvents возвращает типы еще не примененных событий заказа в очереди шарда.
+func pendingOrderEvents(ctx context.Context, orderUID string, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT event_type FROM order_events WHERE order_uid = $1 AND failed_at IS NULL ORDER BY id`, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var types []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// ApplyOrderEvents передает apply до limit событий из очереди шарда shardDB вместе с транзакцией
+// основной базы данных db и удаляет их из очереди после ее фиксации. Событие, заказ которого
+// не удалось прочитать, откладывается с текстом ошибки, чтобы не задерживать следующие события.
+// Возвращает количество обработанных событий.
+func ApplyOrderEvents(ctx context.Context, limit int, apply func(ctx context.Context, ev OrderEvent, tx *sql.Tx) error, shardDB, db *sql.DB) (int, error) {
+	shardTx, err := shardDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer shardTx.Rollback()
+
+	// Документ заказа читается из шарда в момент применения: так событие не хранит копию
+	// персональных данных, а удаление данных клиента до применения попадает и в вебхуки
+	rows, err := shardTx.QueryContext(ctx, `
+		SELECT e.id, e.event_type, e.order_uid, e.created_at, o.document
+		FROM order_events e
+		LEFT JOIN orders o ON o.order_uid = e.order_uid
+		WHERE e.failed_at IS NULL
+		ORDER BY e.id
+		LIMIT $1
+		FOR UPDATE OF e SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching order events: %v", err)
+	}
+	type queued struct {
+		id       int64
+		event    OrderEvent
+		document []byte
+	}
+	var events []queued
+	for rows.Next() {
+		var q queued
+		var orderUID string
+		if err := rows.Scan(&q.id, &q.event.Type, &orderUID, &q.event.CreatedAt, &q.document); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning order event row: %v", err)
+		}
+		q.event.ID = q.event.Type + ":" + orderUID
+		q.event.Order.OrderUID = orderUID
+		events = append(events, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error fetching order events: %v", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	done := make([]int64, 0, len(events))
+	for _, q := range events {
+		if q.document == nil {
+			// Заказ удален из шарда раньше, чем событие было применено
+			fmt.Println("Заказ события не найден в шарде, событие пропущено:", q.event.ID)
+			done = append(done, q.id)
+			continue
+		}
+		order, err := decodeDocument(q.document)
+		if err != nil {
+			if _, err := shardTx.ExecContext(ctx, `UPDATE order_events SET failed_at = now(), last_error = $2 WHERE id = $1`, q.id, err.Error()); err != nil {
+				return 0, fmt.Errorf("ошибка откладывания события %s: %v", q.event.ID, err)
+			}
+			fmt.Println("Событие заказа отложено:", q.event.ID, err)
+			continue
+		}
+		q.event.Order = order
+
+		res, err := tx.ExecContext(ctx, `INSERT INTO order_events_applied (event_id) VALUES ($1) ON CONFLICT DO NOTHING`, q.event.ID)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка отметки события %s: %v", q.event.ID, err)
+		}
+		// Событие уже применено, но не было удалено из шарда: повторно оно не применяется
+		if n, _ := res.RowsAffected(); n == 0 {
+			done = append(done, q.id)
+			continue
+		}
+		if err := apply(ctx, q.event, tx); err != nil {
+			return 0, fmt.Errorf("ошибка применения события %s: %v", q.event.ID, err)
+		}
+		done = append(done, q.id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	if _, err := shardTx.ExecContext(ctx, `DELETE FROM order_events WHERE id = ANY($1)`, done); err != nil {
+		return 0, fmt.Errorf("ошибка удаления примененных событий: %v", err)
+	}
+	if err := shardTx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return len(events), nil
+}
+
+// DeleteAppliedEvents удаляет отметки о применении событий старше olderThan.
+func DeleteAppliedEvents(ctx context.Context, olderThan time.Time, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM order_events_applied WHERE applied_at < $1`, olderThan); err != nil {
+		return fmt.Errorf("ошибка удаления отметок о применении событий: %v", err)
+	}
+	return nil
+}
+
+// AppliedOrdersFromDB читает заказы, у которых нет событий в очереди шарда: их события уже
+// применены в основной базе данных, а остальные заказы будут учтены при применении событий.
+func AppliedOrdersFromDB(ctx context.Context, db *sql.DB) (map[string]model.Order, error) {
+	return queryDocuments(ctx, db, "AND order_uid NOT IN (SELECT order_uid FROM order_events)")
+}
@@ -0,0 +1,343 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	config "main.go/internal"
+	"main.go/internal/utils"
+)
+
+// Действия политики хранения с секциями, месяц которых вышел за срок хранения.
+const (
+	RetentionDetach  = "detach"  // RetentionDetach отключает секции; таблицы остаются в базе под прежними именами.
+	RetentionArchive = "archive" // RetentionArchive выгружает заказы в сжатый NDJSON и удаляет секции.
+	RetentionDrop    = "drop"    // RetentionDrop удаляет секции без выгрузки.
+)
+
+// maintenanceTimeout ограничение времени одного прохода обслуживания секций.
+const maintenanceTimeout = 30 * time.Minute
+
+// partitionedTables таблицы заказа, секционированные по месяцу date_created.
+// Секции всех таблиц за один месяц создаются, отключаются и удаляются вместе.
+var partitionedTables = []string{"orders", "deliveries", "payments", "items"}
+
+// createDefaultPartitions создает секции по умолчанию для заказов, месяц которых не имеет своей секции.
+const createDefaultPartitions = `
+	CREATE TABLE IF NOT EXISTS orders_default PARTITION OF orders DEFAULT;
+	CREATE TABLE IF NOT EXISTS deliveries_default PARTITION OF deliveries DEFAULT;
+	CREATE TABLE IF NOT EXISTS payments_default PARTITION OF payments DEFAULT;
+	CREATE TABLE IF NOT EXISTS items_default PARTITION OF items DEFAULT;`
+
+// createPartitionHolds создает таблицу месяцев, восстановленных из архива.
+// Политика хранения не применяется к этим месяцам, пока защита не снята.
+const createPartitionHolds = `
+	CREATE TABLE IF NOT EXISTS partition_holds (
+		month DATE PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`
+
+// ParseMonth разбирает месяц в формате YYYY-MM.
+func ParseMonth(s string) (time.Time, error) {
+	month, err := time.Parse("2006-01", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("некорректный месяц %q, ожидается YYYY-MM", s)
+	}
+	return month, nil
+}
+
+// monthStart возвращает начало месяца t в UTC.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionName возвращает имя секции таблицы за месяц, например orders_y2024m01.
+func partitionName(table string, month time.Time) string {
+	return fmt.Sprintf("%s_y%04dm%02d", table, month.Year(), month.Month())
+}
+
+// partitionBounds возвращает границы секции за месяц для FOR VALUES.
+func partitionBounds(month time.Time) string {
+	return fmt.Sprintf("FROM ('%s') TO ('%s')", month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339))
+}
+
+// createPartitions создает секции всех таблиц заказа за месяц, если их еще нет.
+func createPartitions(ctx context.Context, month time.Time, db execer) error {
+	for _, table := range partitionedTables {
+		name := partitionName(table, month)
+		query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES %s", name, table, partitionBounds(month))
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("ошибка создания секции %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// EnsurePartitions создает секции на текущий месяц и premake следующих месяцев.
+// Секции создаются заранее, чтобы новые заказы не попадали в секцию по умолчанию.
+func EnsurePartitions(ctx context.Context, premake int, db *sql.DB) error {
+	current := monthStart(time.Now())
+	for i := 0; i <= premake; i++ {
+		if err := createPartitions(ctx, current.AddDate(0, i, 0), db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listPartitions возвращает месяцы, за которые к таблице orders подключены секции.
+func listPartitions(ctx context.Context, db *sql.DB) ([]time.Time, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'orders'::regclass
+		ORDER BY c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения списка секций: %v", err)
+	}
+	defer rows.Close()
+
+	var months []time.Time
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("ошибка чтения списка секций: %v", err)
+		}
+		// Секция по умолчанию и секции с посторонними именами не относятся к политике хранения
+		if month, err := time.Parse("y2006m01", strings.TrimPrefix(name, "orders_")); err == nil {
+			months = append(months, month)
+		}
+	}
+	return months, rows.Err()
+}
+
+// heldMonths возвращает месяцы, защищенные от политики хранения, в формате YYYY-MM.
+func heldMonths(ctx context.Context, db *sql.DB) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT to_char(month, 'YYYY-MM') FROM partition_holds")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения защищенных месяцев: %v", err)
+	}
+	defer rows.Close()
+
+	held := make(map[string]bool)
+	for rows.Next() {
+		var month string
+		if err := rows.Scan(&month); err != nil {
+			return nil, fmt.Errorf("ошибка чтения защищенных месяцев: %v", err)
+		}
+		held[month] = true
+	}
+	return held, rows.Err()
+}
+
+// ReleaseHold снимает с месяца защиту от политики хранения, установленную при восстановлении из архива.
+func ReleaseHold(ctx context.Context, month time.Time, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM partition_holds WHERE month = $1", monthStart(month))
+	if err != nil {
+		return fmt.Errorf("ошибка снятия защиты месяца: %v", err)
+	}
+	return nil
+}
+
+// ApplyRetention применяет политику хранения: секции, месяц которых закончился больше
+// cfg.Retention полных месяцев назад, отключаются, архивируются или удаляются.
+func ApplyRetention(ctx context.Context, cfg config.PartitionsConfig, db *sql.DB) error {
+	if cfg.Retention <= 0 {
+		return nil
+	}
+	switch cfg.Action {
+	case RetentionDetach, RetentionArchive, RetentionDrop:
+	default:
+		return fmt.Errorf("неизвестное действие политики хранения: %q", cfg.Action)
+	}
+
+	months, err := listPartitions(ctx, db)
+	if err != nil {
+		return err
+	}
+	held, err := heldMonths(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	cutoff := monthStart(time.Now()).AddDate(0, -cfg.Retention, 0)
+	for _, month := range months {
+		if !month.Before(cutoff) || held[month.Format("2006-01")] {
+			continue
+		}
+		switch cfg.Action {
+		case RetentionDetach:
+			err = detachPartitions(ctx, month, db)
+		case RetentionArchive:
+			err = archivePartitions(ctx, cfg.ArchiveDir, month, db)
+		case RetentionDrop:
+			err = dropPartitions(ctx, month, db)
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("Секции заказов за %s: выполнено действие %s", month.Format("2006-01"), cfg.Action)
+	}
+	return nil
+}
+
+// RunRetention периодически создает секции на будущие месяцы и применяет политику хранения.
+func RunRetention(cfg config.PartitionsConfig, db *sql.DB) {
+	interval := utils.ParseDuration(cfg.CheckInterval)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), maintenanceTimeout)
+		if err := EnsurePartitions(ctx, cfg.Premake, db); err != nil {
+			log.Printf("Ошибка создания секций заказов: %v", err)
+		}
+		if err := ApplyRetention(ctx, cfg, db); err != nil {
+			log.Printf("Ошибка применения политики хранения: %v", err)
+		}
+		cancel()
+		time.Sleep(interval)
+	}
+}
+
+// inMaintenanceTx выполняет fn в транзакции без ограничения времени запроса:
+// выгрузка и перенос секций могут занимать больше statement_timeout пула.
+func inMaintenanceTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SET LOCAL statement_timeout = 0"); err != nil {
+		return fmt.Errorf("ошибка настройки транзакции: %v", err)
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return nil
+}
+
+// detachPartitions отключает секции за месяц от всех таблиц заказа.
+func detachPartitions(ctx context.Context, month time.Time, db *sql.DB) error {
+	return inMaintenanceTx(ctx, db, func(tx *sql.Tx) error {
+		for _, table := range partitionedTables {
+			name := partitionName(table, month)
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", table, name)); err != nil {
+				return fmt.Errorf("ошибка отключения секции %s: %v", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// dropPartitions удаляет секции за месяц.
+func dropPartitions(ctx context.Context, month time.Time, db *sql.DB) error {
+	return inMaintenanceTx(ctx, db, func(tx *sql.Tx) error {
+		return dropPartitionsTx(ctx, month, tx)
+	})
+}
+
+// dropPartitionsTx удаляет секции за месяц в транзакции tx.
+func dropPartitionsTx(ctx context.Context, month time.Time, tx *sql.Tx) error {
+	names := make([]string, len(partitionedTables))
+	for i, table := range partitionedTables {
+		names[i] = partitionName(table, month)
+	}
+	if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+strings.Join(names, ", ")); err != nil {
+		return fmt.Errorf("ошибка удаления секций за %s: %v", month.Format("2006-01"), err)
+	}
+	return nil
+}
+
+// partitionLegacyTables переносит заказы из несекционированных таблиц, созданных прежними версиями
+// сервиса, в секционированные. Старые таблицы переименовываются, для каждого месяца с заказами
+// создаются секции, строки копируются, после чего старые таблицы удаляются. Все выполняется
+// в одной транзакции; для уже секционированной схемы функция ничего не делает.
+func partitionLegacyTables(ctx context.Context, db *sql.DB) error {
+	var partitioned bool
+	err := db.QueryRowContext(ctx, "SELECT relkind = 'p' FROM pg_class WHERE oid = 'orders'::regclass").Scan(&partitioned)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки схемы orders: %v", err)
+	}
+	if partitioned {
+		return nil
+	}
+
+	log.Printf("Перенос заказов в секционированные таблицы")
+	return inMaintenanceTx(ctx, db, func(tx *sql.Tx) error {
+		// Имена индексов общие для схемы, поэтому индексы старых таблиц освобождают имена для новых
+		renames := `
+		DROP INDEX IF EXISTS orders_document_path_idx, orders_document_keys_idx;
+		ALTER TABLE items DROP CONSTRAINT IF EXISTS items_order_uid_fkey;
+		ALTER TABLE deliveries DROP CONSTRAINT IF EXISTS deliveries_order_uid_fkey;
+		ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_order_uid_fkey;
+		ALTER TABLE orders RENAME TO orders_unpartitioned;
+		ALTER TABLE orders_unpartitioned RENAME CONSTRAINT orders_pkey TO orders_unpartitioned_pkey;
+		ALTER TABLE deliveries RENAME TO deliveries_unpartitioned;
+		ALTER TABLE deliveries_unpartitioned RENAME CONSTRAINT deliveries_pkey TO deliveries_unpartitioned_pkey;
+		ALTER TABLE payments RENAME TO payments_unpartitioned;
+		ALTER TABLE payments_unpartitioned RENAME CONSTRAINT payments_pkey TO payments_unpartitioned_pkey;
+		ALTER TABLE items RENAME TO items_unpartitioned;`
+		if _, err := tx.ExecContext(ctx, renames); err != nil {
+			return fmt.Errorf("ошибка переименования старых таблиц: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, createOrderTables+createDefaultPartitions); err != nil {
+			return fmt.Errorf("ошибка создания секционированных таблиц: %v", err)
+		}
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT DISTINCT date_trunc('month', date_created AT TIME ZONE 'UTC')
+			FROM orders_unpartitioned WHERE date_created IS NOT NULL`)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения месяцев заказов: %v", err)
+		}
+		var months []time.Time
+		for rows.Next() {
+			var month time.Time
+			if err := rows.Scan(&month); err != nil {
+				rows.Close()
+				return fmt.Errorf("ошибка чтения месяцев заказов: %v", err)
+			}
+			months = append(months, monthStart(month))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("ошибка чтения месяцев заказов: %v", err)
+		}
+		for _, month := range months {
+			if err := createPartitions(ctx, month, tx); err != nil {
+				return err
+			}
+		}
+
+		// Заказы без даты создания попадают в секцию по умолчанию
+		copyRows := `
+		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, document)
+		SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, COALESCE(date_created, '-infinity'), oof_shard, document
+		FROM orders_unpartitioned;
+
+		INSERT INTO deliveries (order_uid, date_created, name, phone, zip, city, address, region, email)
+		SELECT d.order_uid, COALESCE(o.date_created, '-infinity'), d.name, d.phone, d.zip, d.city, d.address, d.region, d.email
+		FROM deliveries_unpartitioned d JOIN orders_unpartitioned o USING (order_uid);
+
+		INSERT INTO payments (order_uid, date_created, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+		SELECT p.order_uid, COALESCE(o.date_created, '-infinity'), p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+		FROM payments_unpartitioned p JOIN orders_unpartitioned o USING (order_uid);
+
+		INSERT INTO items (order_uid, date_created, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
+		SELECT i.order_uid, COALESCE(o.date_created, '-infinity'), i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+		FROM items_unpartitioned i JOIN orders_unpartitioned o USING (order_uid);
+
+		DROP TABLE items_unpartitioned, deliveries_unpartitioned, payments_unpartitioned, orders_unpartitioned;`
+		if _, err := tx.ExecContext(ctx, copyRows); err != nil {
+			return fmt.Errorf("ошибка переноса заказов в секции: %v", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"main.go/internal/pii"
+)
+
+// rotateBatchSize количество заказов, перешифровываемых одной транзакцией.
+const rotateBatchSize = 500
+
+// RotatePII перешифровывает персональные данные в таблице доставок и в документах заказов основным ключом
+// из файла ключей; данные, сохраненные до включения шифрования, шифруются. Строки обходятся порциями
+// по возрастанию ключа, поэтому прерванную ротацию можно запустить повторно.
+// Возвращает количество измененных строк доставок и документов.
+func RotatePII(ctx context.Context, db *sql.DB) (int, int, error) {
+	if !pii.Enabled() {
+		return 0, 0, pii.ErrNoKeyring
+	}
+	deliveries, err := rotateTable(ctx, db, rotateDeliveries)
+	if err != nil {
+		return deliveries, 0, fmt.Errorf("ошибка ротации ключей доставок: %v", err)
+	}
+	documents, err := rotateTable(ctx, db, rotateDocuments)
+	if err != nil {
+		return deliveries, documents, fmt.Errorf("ошибка ротации ключей документов: %v", err)
+	}
+	return deliveries, documents, nil
+}
+
+// rotateKey ключ строки таблицы заказа, после которого начинается следующая порция.
+// date_created хранится текстом: у заказов без даты из старых таблиц она равна -infinity.
+type rotateKey struct {
+	orderUID    string
+	dateCreated string
+}
+
+// rotateTable вызывает batch для порций строк, пока они не закончатся, и суммирует количество измененных.
+func rotateTable(ctx context.Context, db *sql.DB, batch func(ctx context.Context, after rotateKey, tx *sql.Tx) (rotateKey, int, int, error)) (int, error) {
+	after := rotateKey{dateCreated: "-infinity"}
+	total := 0
+	for {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return total, fmt.Errorf("ошибка начала транзакции: %v", err)
+		}
+		next, read, changed, err := batch(ctx, after, tx)
+		if err != nil {
+			tx.Rollback()
+			return total, err
+		}
+		if err := tx.Commit(); err != nil {
+			return total, fmt.Errorf("ошибка фиксации транзакции: %v", err)
+		}
+		total += changed
+		if read < rotateBatchSize {
+			return total, nil
+		}
+		after = next
+	}
+}
+
+// rotateDeliveries перешифровывает одну порцию строк таблицы доставок.
+func rotateDeliveries(ctx context.Context, after rotateKey, tx *sql.Tx) (rotateKey, int, int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT order_uid, date_created::text, COALESCE(name, ''), COALESCE(phone, ''), COALESCE(address, ''), COALESCE(email, '')
+		FROM deliveries
+		WHERE (order_uid, date_created) > ($1, $2::timestamptz)
+		ORDER BY order_uid, date_created
+		LIMIT $3
+		FOR UPDATE`, after.orderUID, after.dateCreated, rotateBatchSize)
+	if err != nil {
+		return after, 0, 0, fmt.Errorf("ошибка чтения доставок: %v", err)
+	}
+	type row struct {
+		key      rotateKey
+		delivery model.Delivery
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.key.orderUID, &r.key.dateCreated, &r.delivery.Name, &r.delivery.Phone, &r.delivery.Address, &r.delivery.Email); err != nil {
+			rows.Close()
+			return after, 0, 0, fmt.Errorf("ошибка чтения доставок: %v", err)
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return after, 0, 0, fmt.Errorf("ошибка чтения доставок: %v", err)
+	}
+
+	changed := 0
+	for _, r := range batch {
+		after = r.key
+		ok, err := pii.RewrapDelivery(&r.delivery)
+		if err != nil {
+			return after, 0, 0, fmt.Errorf("заказ %s: %v", r.key.orderUID, err)
+		}
+		if !ok {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE deliveries SET name = $1, phone = $2, address = $3, email = $4
+			WHERE order_uid = $5 AND date_created = $6::timestamptz`,
+			r.delivery.Name, r.delivery.Phone, r.delivery.Address, r.delivery.Email, r.key.orderUID, r.key.dateCreated)
+		if err != nil {
+			return after, 0, 0, fmt.Errorf("ошибка обновления доставки заказа %s: %v", r.key.orderUID, err)
+		}
+		changed++
+	}
+	return after, len(batch), changed, nil
+}
+
+// rotateDocuments перешифровывает персональные данные в одной порции документов заказов.
+func rotateDocuments(ctx context.Context, after rotateKey, tx *sql.Tx) (rotateKey, int, int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT order_uid, date_created::text, document
+		FROM orders
+		WHERE document IS NOT NULL AND (order_uid, date_created) > ($1, $2::timestamptz)
+		ORDER BY order_uid, date_created
+		LIMIT $3
+		FOR UPDATE`, after.orderUID, after.dateCreated, rotateBatchSize)
+	if err != nil {
+		return after, 0, 0, fmt.Errorf("ошибка чтения документов: %v", err)
+	}
+	type row struct {
+		key      rotateKey
+		document []byte
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.key.orderUID, &r.key.dateCreated, &r.document); err != nil {
+			rows.Close()
+			return after, 0, 0, fmt.Errorf("ошибка чтения документов: %v", err)
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return after, 0, 0, fmt.Errorf("ошибка чтения документов: %v", err)
+	}
+
+	changed := 0
+	for _, r := range batch {
+		after = r.key
+		document, ok, err := pii.RewrapDocument(r.document)
+		if err != nil {
+			return after, 0, 0, fmt.Errorf("заказ %s: %v", r.key.orderUID, err)
+		}
+		if !ok {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE orders SET document = $1 WHERE order_uid = $2 AND date_created = $3::timestamptz",
+			document, r.key.orderUID, r.key.dateCreated)
+		if err != nil {
+			return after, 0, 0, fmt.Errorf("ошибка обновления документа заказа %s: %v", r.key.orderUID, err)
+		}
+		changed++
+	}
+	return after, len(batch), changed, nil
+}
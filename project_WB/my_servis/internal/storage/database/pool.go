@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	config "main.go/internal"
+	"main.go/internal/utils"
+)
+
+const (
+	connectBackoff    = time.Second      // connectBackoff пауза после первой неудачной попытки подключения.
+	maxConnectBackoff = 30 * time.Second // maxConnectBackoff максимальная пауза между попытками подключения.
+	pingTimeout       = 5 * time.Second  // pingTimeout время ожидания ответа Postgres при проверке соединения.
+)
+
+// Имена подготовленных запросов. Запросы подготавливаются на каждом соединении пула,
+// и их можно выполнять, передавая имя вместо текста запроса.
+const (
+	stmtOrderExists    = "order_exists"
+	stmtOrderDocument  = "order_document"
+	stmtLockOrderUID   = "lock_order_uid"
+	stmtInsertOrder    = "insert_order"
+	stmtInsertDelivery = "insert_delivery"
+	stmtInsertPayment  = "insert_payment"
+	stmtInsertItem     = "insert_item"
+	stmtInsertOutbox   = "insert_outbox"
+)
+
+// preparedStatements тексты подготовленных запросов вставки и поиска заказа.
+var preparedStatements = map[string]string{
+	stmtOrderExists:   `SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)`,
+	stmtOrderDocument: `SELECT document FROM orders WHERE order_uid = $1`,
+	stmtLockOrderUID:  `SELECT pg_advisory_xact_lock(` + orderUIDLockClass + `, hashtext($1))`,
+	stmtInsertOrder: `
+		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, document)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+		WHERE NOT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`,
+	stmtInsertDelivery: `
+		INSERT INTO deliveries (name, phone, zip, city, address, region, email, order_uid, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+	stmtInsertPayment: `
+		INSERT INTO payments (transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee, order_uid, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+	stmtInsertItem: `
+		INSERT INTO items (chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, order_uid, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+	stmtInsertOutbox: `
+		INSERT INTO outbox (event_type, msg_id, payload)
+		VALUES ($1, $2, $3)`,
+}
+
+// Connect подключается к основному серверу и репликам из конфигурации.
+// Пока основной сервер недоступен, подключение повторяется с экспоненциальной задержкой;
+// после исчерпания попыток программа завершается с ошибкой.
+func Connect(cfg config.DatabaseConfig) *Cluster {
+	cluster, err := newCluster(connectPrimary(cfg), cfg)
+	if err != nil {
+		log.Fatalf("Ошибка подключения к репликам: %v", err)
+	}
+	return cluster
+}
+
+// connectPrimary создает пул соединений pgx к основному серверу и возвращает его в виде *sql.DB.
+func connectPrimary(cfg config.DatabaseConfig) *sql.DB {
+	return connectDSN(databaseDSN(cfg), cfg)
+}
+
+// connectDSN подключается к базе данных dsn с настройками пула из cfg, создает таблицы
+// и секции и возвращает пул соединений в виде *sql.DB.
+func connectDSN(dsn string, cfg config.DatabaseConfig) *sql.DB {
+	poolConfig, err := poolConfig(dsn, cfg)
+	if err != nil {
+		log.Fatalf("Ошибка настройки пула соединений: %v", err)
+	}
+
+	// Таблицы создаются через отдельное соединение до запуска пула, так как подготовленные запросы
+	// пула ссылаются на эти таблицы. Ограничение времени запроса снято, чтобы миграции успели выполниться
+	connConfig := poolConfig.ConnConfig.Copy()
+	delete(connConfig.RuntimeParams, "statement_timeout")
+	bootstrap := stdlib.OpenDB(*connConfig)
+	if err := waitForDB(bootstrap, cfg.ConnectAttempts); err != nil {
+		log.Fatalf("Ошибка подключения к базе данных: %v", err)
+	}
+	createTables(bootstrap)
+	if err := EnsurePartitions(context.Background(), cfg.Partitions.Premake, bootstrap); err != nil {
+		log.Fatalf("Ошибка создания секций заказов: %v", err)
+	}
+	bootstrap.Close()
+
+	poolConfig.AfterConnect = prepareStatements
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		log.Fatalf("Ошибка создания пула соединений: %v", err)
+	}
+	db := stdlib.OpenDBFromPool(pool)
+	if err := waitForDB(db, cfg.ConnectAttempts); err != nil {
+		log.Fatalf("Ошибка подключения к базе данных: %v", err)
+	}
+	return db
+}
+
+// databaseDSN формирует строку подключения из конфигурации приложения.
+func databaseDSN(cfg config.DatabaseConfig) string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port, cfg.SSLMode)
+}
+
+// poolConfig формирует настройки пула для базы данных dsn из конфигурации приложения.
+func poolConfig(dsn string, cfg config.DatabaseConfig) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	poolConfig.MinConns = cfg.MinConns
+	if cfg.MaxConnLifetime != "" {
+		poolConfig.MaxConnLifetime = utils.ParseDuration(cfg.MaxConnLifetime)
+	}
+	if cfg.MaxConnIdleTime != "" {
+		poolConfig.MaxConnIdleTime = utils.ParseDuration(cfg.MaxConnIdleTime)
+	}
+	if cfg.StatementTimeout != "" {
+		timeout := utils.ParseDuration(cfg.StatementTimeout)
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+	return poolConfig, nil
+}
+
+// prepareStatements подготавливает запросы вставки и поиска на новом соединении пула.
+func prepareStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, query := range preparedStatements {
+		if _, err := conn.Prepare(ctx, name, query); err != nil {
+			return fmt.Errorf("ошибка подготовки запроса %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// waitForDB проверяет соединение с базой, повторяя попытки с экспоненциальной задержкой.
+func waitForDB(db *sql.DB, attempts int) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := connectBackoff
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		log.Printf("База данных недоступна (попытка %d из %d), повтор через %s: %v", attempt, attempts, backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	config "main.go/internal"
+	"main.go/internal/utils"
+)
+
+// replicaLagQuery возвращает отставание реплики в секундах. Если реплика применила все полученные
+// изменения, отставание считается нулевым, иначе простаивающий основной сервер выглядел бы как задержка.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END::float8`
+
+// Cluster объединяет основной сервер и реплики только для чтения.
+// Запись и чтения, которым нужны самые свежие данные, выполняются через Primary;
+// аналитика, выгрузки и прогрев кэша - через Replica.
+type Cluster struct {
+	primary  *sql.DB
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint64
+	stop     chan struct{}
+}
+
+// replica пул соединений реплики и результат последней проверки.
+type replica struct {
+	addr    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// Primary возвращает основной сервер.
+func (c *Cluster) Primary() *sql.DB {
+	return c.primary
+}
+
+// Replica возвращает доступную реплику с допустимым отставанием, перебирая их по кругу.
+// Если таких реплик нет, возвращается основной сервер.
+func (c *Cluster) Replica() *sql.DB {
+	n := len(c.replicas)
+	start := c.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := c.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return c.primary
+}
+
+// Close останавливает проверку реплик и закрывает все пулы соединений.
+func (c *Cluster) Close() error {
+	close(c.stop)
+	for _, r := range c.replicas {
+		r.db.Close()
+	}
+	return c.primary.Close()
+}
+
+// newCluster подключает реплики из конфигурации и запускает их периодическую проверку.
+// Недоступная при запуске реплика не мешает старту: запросы идут на основной сервер, пока она не появится.
+func newCluster(primary *sql.DB, cfg config.DatabaseConfig) (*Cluster, error) {
+	c := &Cluster{
+		primary: primary,
+		maxLag:  utils.ParseDuration(cfg.MaxReplicaLag),
+		stop:    make(chan struct{}),
+	}
+	for _, rc := range cfg.Replicas {
+		replicaCfg := cfg
+		replicaCfg.Host, replicaCfg.Port = rc.Host, rc.Port
+		poolConfig, err := poolConfig(databaseDSN(replicaCfg), replicaCfg)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка настройки пула реплики %s:%d: %v", rc.Host, rc.Port, err)
+		}
+		poolConfig.AfterConnect = prepareStatements
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка создания пула реплики %s:%d: %v", rc.Host, rc.Port, err)
+		}
+		c.replicas = append(c.replicas, &replica{
+			addr: fmt.Sprintf("%s:%d", rc.Host, rc.Port),
+			db:   stdlib.OpenDBFromPool(pool),
+		})
+	}
+	if len(c.replicas) > 0 {
+		c.checkReplicas()
+		go c.monitor(utils.ParseDuration(cfg.ReplicaCheckInterval))
+	}
+	return c, nil
+}
+
+// monitor периодически проверяет реплики до вызова Close.
+func (c *Cluster) monitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.checkReplicas()
+		}
+	}
+}
+
+// checkReplicas обновляет состояние реплик и пишет в лог, когда реплика выводится из работы или возвращается.
+func (c *Cluster) checkReplicas() {
+	for _, r := range c.replicas {
+		lag, err := replicaLag(r.db)
+		healthy := err == nil && (c.maxLag <= 0 || lag <= c.maxLag)
+		if r.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		switch {
+		case healthy:
+			log.Printf("Реплика %s доступна, отставание %s", r.addr, lag)
+		case err != nil:
+			log.Printf("Реплика %s недоступна, чтение переключено на основной сервер: %v", r.addr, err)
+		default:
+			log.Printf("Реплика %s отстает на %s, чтение переключено на основной сервер", r.addr, lag)
+		}
+	}
+}
+
+// replicaLag возвращает отставание реплики от основного сервера.
+func replicaLag(db *sql.DB) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	var seconds float64
+	if err := db.QueryRowContext(ctx, replicaLagQuery).Scan(&seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
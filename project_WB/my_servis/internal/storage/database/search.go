@@ -0,0 +1,317 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"main.go/internal/pii"
+	"main.go/internal/search"
+)
+
+// deliveryVector возвращает выражение полнотекстового поиска по доставке; alias - псевдоним таблицы
+// с точкой или пустая строка. Выражение совпадает с выражением индекса deliveries_search_idx.
+func deliveryVector(alias string) string {
+	return fmt.Sprintf("to_tsvector('simple'::regconfig, coalesce(%[1]sname, '') || ' ' || coalesce(%[1]scity, '') || ' ' || coalesce(%[1]sregion, '') || ' ' || coalesce(%[1]saddress, ''))", alias)
+}
+
+// locationVector возвращает выражение полнотекстового поиска по городу и региону доставки, как в индексе
+// deliveries_location_search_idx. Используется вместо deliveryVector, если персональные данные шифруются:
+// имя и адрес получателя тогда хранятся шифротекстом.
+func locationVector(alias string) string {
+	return fmt.Sprintf("to_tsvector('simple'::regconfig, coalesce(%[1]scity, '') || ' ' || coalesce(%[1]sregion, ''))", alias)
+}
+
+// itemVector возвращает выражение полнотекстового поиска по товару, как в индексе items_search_idx.
+func itemVector(alias string) string {
+	return fmt.Sprintf("to_tsvector('simple'::regconfig, coalesce(%[1]sname, '') || ' ' || coalesce(%[1]sbrand, ''))", alias)
+}
+
+// phoneDigits выражение с цифрами телефона, как в индексе deliveries_phone_trgm_idx.
+const phoneDigits = `regexp_replace(d.phone, '\D', '', 'g')`
+
+// createSearchIndexes создает индексы поиска заказов: полнотекстовые по доставке и товарам
+// и триграммные для поиска подстрок. Индексы секционированных таблиц создаются и в их секциях.
+var createSearchIndexes = `
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS deliveries_search_idx ON deliveries USING GIN ((` + deliveryVector("") + `));
+	CREATE INDEX IF NOT EXISTS deliveries_location_search_idx ON deliveries USING GIN ((` + locationVector("") + `));
+	CREATE INDEX IF NOT EXISTS items_search_idx ON items USING GIN ((` + itemVector("") + `));
+	CREATE INDEX IF NOT EXISTS deliveries_name_trgm_idx ON deliveries USING GIN (name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS deliveries_phone_trgm_idx ON deliveries USING GIN ((regexp_replace(phone, '\D', '', 'g')) gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS deliveries_email_trgm_idx ON deliveries USING GIN (email gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS deliveries_address_trgm_idx ON deliveries USING GIN (address gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS deliveries_city_trgm_idx ON deliveries USING GIN (city gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS deliveries_region_trgm_idx ON deliveries USING GIN (region gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS items_brand_trgm_idx ON items USING GIN (brand gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS items_name_trgm_idx ON items USING GIN (name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS payments_amount_idx ON payments (amount);
+	CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);`
+
+// searchColumns столбцы полей запроса. Поля товаров проверяются подзапросом по таблице items (i).
+var searchColumns = map[string]string{
+	"name":     "d.name",
+	"phone":    phoneDigits,
+	"email":    "d.email",
+	"address":  "d.address",
+	"city":     "d.city",
+	"region":   "d.region",
+	"zip":      "d.zip",
+	"brand":    "i.brand",
+	"item":     "i.name",
+	"uid":      "o.order_uid",
+	"track":    "o.track_number",
+	"customer": "o.customer_id",
+	"service":  "o.delivery_service",
+	"currency": "p.currency",
+	"provider": "p.provider",
+	"bank":     "p.bank",
+	"amount":   "p.amount",
+	"status":   "i.status",
+	"nm":       "i.nm_id",
+	"date":     "o.date_created",
+}
+
+// caseSensitive поля с идентификаторами, которые сравниваются точно, чтобы использовать индексы.
+var caseSensitive = map[string]bool{"uid": true, "track": true, "customer": true}
+
+// itemsJoin условие связи товара с заказом в подзапросах.
+const itemsJoin = "i.order_uid = o.order_uid AND i.date_created = o.date_created"
+
+// ErrEncryptedPII возвращается для поиска по персональным данным, которые хранятся зашифрованными.
+var ErrEncryptedPII = errors.New("поиск по зашифрованным персональным данным недоступен")
+
+// searchSQL условие отбора и выражение ранга запроса с параметрами.
+type searchSQL struct {
+	conds     []string
+	ranks     []string
+	args      []any
+	encrypted bool // encrypted персональные данные шифруются, слова не ищутся по имени и адресу получателя.
+}
+
+// arg добавляет параметр запроса и возвращает его обозначение.
+func (s *searchSQL) arg(v any) string {
+	s.args = append(s.args, v)
+	return "$" + strconv.Itoa(len(s.args))
+}
+
+// escapeLike экранирует символы шаблона LIKE.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// word добавляет слово полнотекстового поиска: совпадение с началом слов доставки или товаров
+// либо точное совпадение с идентификатором заказа, трек-номером или идентификатором клиента.
+func (s *searchSQL) word(w string) {
+	parts := search.Words(w)
+	raw := s.arg(w)
+	cond := "o.order_uid = " + raw + " OR o.track_number = " + raw + " OR o.customer_id = " + raw
+	rank := "CASE WHEN o.order_uid = " + raw + " OR o.track_number = " + raw + " THEN 1 ELSE 0 END"
+	if len(parts) > 0 {
+		for i, part := range parts {
+			parts[i] = part + ":*"
+		}
+		tsq := "to_tsquery('simple'::regconfig, " + s.arg(strings.Join(parts, " & ")) + ")"
+		vector := deliveryVector("d.")
+		if s.encrypted {
+			vector = locationVector("d.")
+		}
+		cond = vector + " @@ " + tsq +
+			" OR EXISTS (SELECT 1 FROM items i WHERE " + itemsJoin + " AND " + itemVector("i.") + " @@ " + tsq + ") OR " + cond
+		rank = "ts_rank(" + vector + ", " + tsq + ")" +
+			" + COALESCE((SELECT max(ts_rank(" + itemVector("i.") + ", " + tsq + ")) FROM items i WHERE " + itemsJoin + "), 0) + " + rank
+	}
+	s.conds = append(s.conds, "("+cond+")")
+	s.ranks = append(s.ranks, rank)
+}
+
+// term добавляет условие по полю.
+func (s *searchSQL) term(t search.Term) {
+	col := searchColumns[t.Field]
+	var cond, rank string
+	switch t.Kind() {
+	case search.KindText, search.KindPhone:
+		cond = col + " ILIKE " + s.arg("%"+escapeLike(t.Value)+"%")
+		rank = "similarity(" + col + ", " + s.arg(t.Value) + ")"
+	case search.KindExact:
+		if caseSensitive[t.Field] {
+			cond = col + " = " + s.arg(t.Value)
+		} else {
+			cond = "lower(" + col + ") = lower(" + s.arg(t.Value) + ")"
+		}
+	case search.KindNumber:
+		op := t.Op
+		if op == search.OpMatch {
+			op = "="
+		}
+		cond = col + " " + op + " " + s.arg(t.Number)
+	case search.KindDate:
+		cond = dateCondition(col, t, s)
+	}
+	if strings.HasPrefix(col, "i.") {
+		cond = "EXISTS (SELECT 1 FROM items i WHERE " + itemsJoin + " AND " + cond + ")"
+		if rank != "" {
+			rank = "COALESCE((SELECT max(" + rank + ") FROM items i WHERE " + itemsJoin + "), 0)"
+		}
+	}
+	if t.Negate {
+		// Отрицание не должно отбрасывать заказы с пустым значением поля
+		cond = "NOT COALESCE(" + cond + ", false)"
+		rank = ""
+	}
+	s.conds = append(s.conds, cond)
+	if rank != "" {
+		s.ranks = append(s.ranks, rank)
+	}
+}
+
+// dateCondition возвращает условие по дате. Дата, заданная днем, означает весь день:
+// date:2024-01-05 - в этот день, date>2024-01-05 - после него, date<2024-01-05 - до него.
+func dateCondition(col string, t search.Term, s *searchSQL) string {
+	day := !t.From.Equal(t.To)
+	switch {
+	case t.Op == search.OpMatch && day:
+		return col + " >= " + s.arg(t.From) + " AND " + col + " < " + s.arg(t.To)
+	case t.Op == search.OpGT && day:
+		return col + " >= " + s.arg(t.To)
+	case t.Op == search.OpLE && day:
+		return col + " < " + s.arg(t.To)
+	case t.Op == search.OpMatch:
+		return col + " = " + s.arg(t.From)
+	}
+	return col + " " + t.Op + " " + s.arg(t.From)
+}
+
+// buildSearch строит условие отбора и ранг запроса. Условия по персональным данным, которые хранятся
+// зашифрованными, в базе проверить нельзя, поэтому для них возвращается ErrEncryptedPII.
+func buildSearch(q search.Query, encrypted bool) (searchSQL, error) {
+	s := searchSQL{encrypted: encrypted}
+	for _, t := range q.Terms {
+		if encrypted && t.PII() {
+			return s, fmt.Errorf("%w: поле %s", ErrEncryptedPII, t.Field)
+		}
+	}
+	for _, w := range q.Words {
+		s.word(w)
+	}
+	for _, t := range q.Terms {
+		s.term(t)
+	}
+	return s, nil
+}
+
+// where возвращает условие WHERE запроса поиска.
+func (s searchSQL) where() string {
+	return strings.Join(append([]string{"o.document IS NOT NULL"}, s.conds...), " AND ")
+}
+
+// rank возвращает выражение ранга; без слов и текстовых условий все заказы равны.
+func (s searchSQL) rank() string {
+	if len(s.ranks) == 0 {
+		return "0"
+	}
+	return strings.Join(s.ranks, " + ")
+}
+
+// searchFrom таблицы запроса поиска.
+const searchFrom = `
+		FROM orders o
+		JOIN deliveries d ON d.order_uid = o.order_uid AND d.date_created = o.date_created
+		JOIN payments p ON p.order_uid = o.order_uid AND p.date_created = o.date_created`
+
+// SearchHit найденный заказ и его ранг: чем больше, тем лучше заказ соответствует запросу.
+type SearchHit struct {
+	Order model.Order
+	Rank  float64
+}
+
+// SearchOrders возвращает страницу заказов, подходящих под поисковый запрос, в порядке убывания ранга,
+// а при равном ранге - от новых к старым, и общее количество найденных заказов.
+// Если персональные данные шифруются, слова ищутся только по городу, региону, товарам и идентификаторам,
+// а условия по персональным данным отклоняются с ErrEncryptedPII.
+func SearchOrders(ctx context.Context, q search.Query, offset, limit int, db *sql.DB) ([]SearchHit, int, error) {
+	s, err := buildSearch(q, pii.Enabled())
+	if err != nil {
+		return nil, 0, err
+	}
+	args := s.args
+	query := "SELECT o.document, " + s.rank() + " AS rank, COUNT(*) OVER ()" + searchFrom +
+		" WHERE " + s.where() + " ORDER BY rank DESC, o.date_created DESC, o.order_uid" +
+		" OFFSET " + s.arg(offset) + " LIMIT " + s.arg(limit)
+	rows, err := db.QueryContext(ctx, query, s.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка поиска заказов: %v", err)
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	total := 0
+	for rows.Next() {
+		var document []byte
+		var hit SearchHit
+		if err := rows.Scan(&document, &hit.Rank, &total); err != nil {
+			return nil, 0, fmt.Errorf("ошибка чтения документа заказа: %v", err)
+		}
+		if hit.Order, err = decodeDocument(document); err != nil {
+			return nil, 0, err
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("ошибка поиска заказов: %v", err)
+	}
+
+	if len(hits) == 0 && offset > 0 {
+		// Страница за пределами выборки: оконная функция не вернула ни одной строки.
+		// Ранг оставлен в подзапросе, чтобы использовались все параметры запроса
+		count := "SELECT COUNT(*) FROM (SELECT " + s.rank() + " AS rank" + searchFrom + " WHERE " + s.where() + ") found"
+		if err := db.QueryRowContext(ctx, count, args...).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("ошибка подсчета найденных заказов: %v", err)
+		}
+	}
+	return hits, total, nil
+}
+
+// SearchOrders выполняет SearchOrders на всех шардах и объединяет страницы по рангу:
+// каждый шард возвращает первые offset+limit заказов, после сортировки берется нужная страница.
+func (s *Shards) SearchOrders(ctx context.Context, q search.Query, offset, limit int) ([]model.Order, int, error) {
+	var mu sync.Mutex
+	var merged []SearchHit
+	total := 0
+	err := s.gather(func(name string) error {
+		hits, n, err := SearchOrders(ctx, q, 0, offset+limit, s.readDB(name))
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		merged = append(merged, hits...)
+		total += n
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if !a.Order.DateCreated.Equal(b.Order.DateCreated) {
+			return a.Order.DateCreated.After(b.Order.DateCreated)
+		}
+		return a.Order.OrderUID < b.Order.OrderUID
+	})
+	orders := []model.Order{}
+	for i := offset; i < min(offset+limit, len(merged)); i++ {
+		orders = append(orders, merged[i].Order)
+	}
+	return orders, total, nil
+}
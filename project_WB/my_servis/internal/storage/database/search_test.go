@@ -0,0 +1,37 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"main.go/internal/search"
+)
+
+func TestBuildSearchEncryptedPII(t *testing.T) {
+	q, err := search.Parse(`Vivienne city:Moscow`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := buildSearch(q, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// При шифровании слова не ищутся по шифротексту имени и адреса получателя
+	if where := s.where(); strings.Contains(where, deliveryVector("d.")) || !strings.Contains(where, locationVector("d.")) {
+		t.Errorf("where = %s, want location vector only", where)
+	}
+
+	for _, raw := range []string{`phone:2000`, `-email:gmail`, `Vivienne name:"Test Testov"`} {
+		q, err := search.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := buildSearch(q, true); !errors.Is(err, ErrEncryptedPII) {
+			t.Errorf("buildSearch(%q, encrypted) error = %v, want ErrEncryptedPII", raw, err)
+		}
+		if _, err := buildSearch(q, false); err != nil {
+			t.Errorf("buildSearch(%q) error = %v", raw, err)
+		}
+	}
+}
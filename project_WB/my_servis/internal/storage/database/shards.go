@@ -0,0 +1,363 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	config "main.go/internal"
+)
+
+// DefaultShard имя шарда основной базы данных, в которой хранятся заказы с незнакомым ключом шарда.
+const DefaultShard = "default"
+
+// Shards выбирает базу данных заказа по значению его ключа шарда (shardkey или oof_shard).
+// Запись и чтение одного заказа идут в его шард; списки и поиск опрашивают все шарды и объединяют результат.
+type Shards struct {
+	key     string
+	cluster *Cluster
+	byKey   map[string]string
+	dbs     map[string]*sql.DB
+	names   []string
+}
+
+// ConnectShards подключается к шардам из конфигурации. Основная база данных cluster служит шардом
+// по умолчанию; без настроенных шардов все заказы хранятся в ней.
+func ConnectShards(cfg config.ShardingConfig, dbCfg config.DatabaseConfig, cluster *Cluster) *Shards {
+	s, err := newShards(cfg, cluster)
+	if err != nil {
+		log.Fatalf("Ошибка настройки шардов: %v", err)
+	}
+	for _, shard := range cfg.Shards {
+		s.dbs[shard.Name] = connectDSN(shard.DSN, dbCfg)
+	}
+	return s
+}
+
+// newShards проверяет конфигурацию шардов и строит таблицу маршрутизации без подключения к базам.
+func newShards(cfg config.ShardingConfig, cluster *Cluster) (*Shards, error) {
+	if cfg.Key != "shardkey" && cfg.Key != "oof_shard" {
+		return nil, fmt.Errorf("неизвестный ключ шарда %q: используйте shardkey или oof_shard", cfg.Key)
+	}
+	s := &Shards{
+		key:     cfg.Key,
+		cluster: cluster,
+		byKey:   make(map[string]string),
+		dbs:     map[string]*sql.DB{DefaultShard: cluster.Primary()},
+		names:   []string{DefaultShard},
+	}
+	for _, shard := range cfg.Shards {
+		if _, dup := s.dbs[shard.Name]; shard.Name == "" || dup {
+			return nil, fmt.Errorf("некорректное или повторяющееся имя шарда %q", shard.Name)
+		}
+		for _, key := range shard.Keys {
+			if other, ok := s.byKey[key]; ok {
+				return nil, fmt.Errorf("ключ %q указан для шардов %s и %s", key, other, shard.Name)
+			}
+			s.byKey[key] = shard.Name
+		}
+		s.dbs[shard.Name] = nil
+		s.names = append(s.names, shard.Name)
+	}
+	return s, nil
+}
+
+// Names возвращает имена всех шардов; шард по умолчанию идет первым.
+func (s *Shards) Names() []string {
+	return s.names
+}
+
+// DB возвращает основную базу данных шарда.
+func (s *Shards) DB(name string) *sql.DB {
+	return s.dbs[name]
+}
+
+// Name возвращает имя шарда, в котором хранится заказ.
+func (s *Shards) Name(order model.Order) string {
+	key := order.Shardkey
+	if s.key == "oof_shard" {
+		key = order.OOFShard
+	}
+	if name, ok := s.byKey[key]; ok {
+		return name
+	}
+	return DefaultShard
+}
+
+// For возвращает базу данных шарда заказа.
+func (s *Shards) For(order model.Order) *sql.DB {
+	return s.dbs[s.Name(order)]
+}
+
+// readDB возвращает базу данных для чтения из шарда: у шарда по умолчанию это реплика.
+func (s *Shards) readDB(name string) *sql.DB {
+	if name == DefaultShard {
+		return s.cluster.Replica()
+	}
+	return s.dbs[name]
+}
+
+// Close закрывает соединения со всеми шардами, кроме основной базы данных.
+func (s *Shards) Close() {
+	for _, name := range s.names[1:] {
+		s.dbs[name].Close()
+	}
+}
+
+// gather выполняет fn для каждого шарда параллельно и возвращает ошибки всех шардов, завершившихся неудачно.
+func (s *Shards) gather(fn func(name string) error) error {
+	errs := make([]error, len(s.names))
+	var wg sync.WaitGroup
+	for i, name := range s.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(name); err != nil {
+				errs[i] = fmt.Errorf("шард %s: %w", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// GetOrder ищет заказ по идентификатору во всех шардах. Если заказа нет, возвращается sql.ErrNoRows.
+func (s *Shards) GetOrder(ctx context.Context, orderUID string) (model.Order, error) {
+	orders, err := s.GetOrders(ctx, []string{orderUID})
+	if err != nil {
+		return model.Order{}, err
+	}
+	order, ok := orders[orderUID]
+	if !ok {
+		return model.Order{}, sql.ErrNoRows
+	}
+	return order, nil
+}
+
+// GetOrders ищет заказы с указанными идентификаторами во всех шардах.
+func (s *Shards) GetOrders(ctx context.Context, orderUIDs []string) (map[string]model.Order, error) {
+	var mu sync.Mutex
+	result := make(map[string]model.Order, len(orderUIDs))
+	err := s.gather(func(name string) error {
+		orders, err := GetOrdersFromDB(ctx, orderUIDs, s.dbs[name])
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for uid, order := range orders {
+			result[uid] = order
+		}
+		return nil
+	})
+	return result, err
+}
+
+// LookupOrders ищет заказы по идентификаторам или трек-номерам во всех шардах, по одному запросу на шард.
+func (s *Shards) LookupOrders(ctx context.Context, ids []string) (map[string]model.Order, error) {
+	var mu sync.Mutex
+	result := make(map[string]model.Order, len(ids))
+	err := s.gather(func(name string) error {
+		orders, err := LookupOrdersFromDB(ctx, ids, s.readDB(name))
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for uid, order := range orders {
+			result[uid] = order
+		}
+		return nil
+	})
+	return result, err
+}
+
+// CacheAll читает заказы всех шардов для прогрева кэша, по разделу на шард.
+func (s *Shards) CacheAll(ctx context.Context) (map[string]map[string]model.Order, error) {
+	var mu sync.Mutex
+	result := make(map[string]map[string]model.Order, len(s.names))
+	err := s.gather(func(name string) error {
+		orders, err := CacheAllOrdersFromDB(ctx, s.readDB(name))
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		result[name] = orders
+		return nil
+	})
+	return result, err
+}
+
+// AppliedOrders читает с основного сервера каждого шарда заказы, события которых уже применены
+// (см. AppliedOrdersFromDB). Реплики не используются, чтобы выборка согласовалась с очередью событий.
+func (s *Shards) AppliedOrders(ctx context.Context) ([]model.Order, error) {
+	var mu sync.Mutex
+	var result []model.Order
+	err := s.gather(func(name string) error {
+		orders, err := AppliedOrdersFromDB(ctx, s.DB(name))
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, order := range orders {
+			result = append(result, order)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// QueryOrdersByPath выполняет QueryOrdersByPath на всех шардах и объединяет страницы:
+// каждый шард возвращает первые offset+limit заказов, после сортировки берется нужная страница.
+func (s *Shards) QueryOrdersByPath(ctx context.Context, path string, offset, limit int) ([]model.Order, int, error) {
+	var mu sync.Mutex
+	var merged []model.Order
+	total := 0
+	err := s.gather(func(name string) error {
+		orders, n, err := QueryOrdersByPath(ctx, path, 0, offset+limit, s.readDB(name))
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		merged = append(merged, orders...)
+		total += n
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if !merged[i].DateCreated.Equal(merged[j].DateCreated) {
+			return merged[i].DateCreated.After(merged[j].DateCreated)
+		}
+		return merged[i].OrderUID < merged[j].OrderUID
+	})
+	if offset >= len(merged) {
+		return []model.Order{}, total, nil
+	}
+	return merged[offset:min(offset+limit, len(merged))], total, nil
+}
+
+// Rebalance переносит заказы, ключ которых по текущей конфигурации относится к другому шарду.
+// Заказ сначала записывается в новый шард, затем удаляется из старого, поэтому прерванный перенос
+// можно безопасно повторить. При dryRun заказы только подсчитываются. Возвращает количество
+// перенесенных заказов по парам "откуда -> куда".
+func (s *Shards) Rebalance(ctx context.Context, dryRun bool) (map[string]int, error) {
+	moved := make(map[string]int)
+	for _, from := range s.names {
+		misplaced, err := s.misplacedOrders(ctx, from)
+		if err != nil {
+			return moved, fmt.Errorf("шард %s: %v", from, err)
+		}
+		for uid, to := range misplaced {
+			if !dryRun {
+				if err := moveOrder(ctx, uid, s.dbs[from], s.dbs[to]); err != nil {
+					return moved, fmt.Errorf("перенос заказа %s из %s в %s: %v", uid, from, to, err)
+				}
+			}
+			moved[from+" -> "+to]++
+		}
+	}
+	return moved, nil
+}
+
+// misplacedOrders возвращает заказы шарда from, которые должны храниться в другом шарде, с именем этого шарда.
+func (s *Shards) misplacedOrders(ctx context.Context, from string) (map[string]string, error) {
+	rows, err := s.dbs[from].QueryContext(ctx, "SELECT order_uid, COALESCE("+s.key+", '') FROM orders")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ключей шарда: %v", err)
+	}
+	defer rows.Close()
+
+	misplaced := make(map[string]string)
+	for rows.Next() {
+		var order model.Order
+		var key string
+		if err := rows.Scan(&order.OrderUID, &key); err != nil {
+			return nil, fmt.Errorf("ошибка чтения ключей шарда: %v", err)
+		}
+		order.Shardkey, order.OOFShard = key, key
+		if to := s.Name(order); to != from {
+			misplaced[order.OrderUID] = to
+		}
+	}
+	return misplaced, rows.Err()
+}
+
+// moveOrder копирует заказ из базы from в базу to, если его там еще нет, и удаляет его из from.
+// Событие order.accepted повторно не публикуется, а еще не примененные события заказа переносятся вместе с ним.
+func moveOrder(ctx context.Context, orderUID string, from, to *sql.DB) error {
+	var document []byte
+	if err := from.QueryRowContext(ctx, stmtOrderDocument, orderUID).Scan(&document); err != nil {
+		return fmt.Errorf("ошибка чтения документа: %v", err)
+	}
+	order, err := decodeDocument(document)
+	if err != nil {
+		return err
+	}
+
+	exists, err := OrderExists(ctx, orderUID, to)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки заказа: %v", err)
+	}
+	erased, err := OrderErased(ctx, orderUID, from)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки отметки об удалении: %v", err)
+	}
+	events, err := pendingOrderEvents(ctx, orderUID, from)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения событий заказа: %v", err)
+	}
+	if !exists {
+		tx, err := to.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("ошибка начала транзакции: %v", err)
+		}
+		defer tx.Rollback()
+		if err := insertOrderRows(ctx, order, document, tx); err != nil {
+			return err
+		}
+		// Отметка об удалении данных переносится вместе с заказом
+		if erased {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO erased_orders (order_uid) VALUES ($1) ON CONFLICT DO NOTHING", orderUID); err != nil {
+				return fmt.Errorf("ошибка переноса отметки об удалении: %v", err)
+			}
+		}
+		for _, eventType := range events {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO order_events (event_type, order_uid) VALUES ($1, $2)", eventType, orderUID); err != nil {
+				return fmt.Errorf("ошибка переноса событий заказа: %v", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("ошибка фиксации транзакции: %v", err)
+		}
+	}
+	return deleteOrderRows(ctx, orderUID, from)
+}
+
+// deleteOrderRows удаляет заказ из всех таблиц заказа и его события из очереди одной транзакцией.
+func deleteOrderRows(ctx context.Context, orderUID string, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"items", "deliveries", "payments", "orders", "order_events"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE order_uid = $1", orderUID); err != nil {
+			return fmt.Errorf("ошибка удаления из %s: %v", table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return nil
+}
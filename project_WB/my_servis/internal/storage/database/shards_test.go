@@ -0,0 +1,61 @@
+package database
+
+import (
+	"testing"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	config "main.go/internal"
+)
+
+func TestShardsName(t *testing.T) {
+	cfg := config.ShardingConfig{
+		Key: "shardkey",
+		Shards: []config.ShardConfig{
+			{Name: "eu", Keys: []string{"1", "2"}},
+			{Name: "asia", Keys: []string{"9"}},
+		},
+	}
+	s, err := newShards(cfg, &Cluster{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		shardkey string
+		want     string
+	}{
+		{"1", "eu"},
+		{"2", "eu"},
+		{"9", "asia"},
+		{"5", DefaultShard},
+		{"", DefaultShard},
+	}
+	for _, tt := range tests {
+		if got := s.Name(model.Order{Shardkey: tt.shardkey, OOFShard: "9"}); got != tt.want {
+			t.Errorf("Name(shardkey=%q) = %q, want %q", tt.shardkey, got, tt.want)
+		}
+	}
+
+	cfg.Key = "oof_shard"
+	s, err = newShards(cfg, &Cluster{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Name(model.Order{Shardkey: "1", OOFShard: "9"}); got != "asia" {
+		t.Errorf("Name by oof_shard = %q, want asia", got)
+	}
+}
+
+func TestNewShardsRejectsInvalidConfig(t *testing.T) {
+	tests := []config.ShardingConfig{
+		{Key: "customer_id"},
+		{Key: "shardkey", Shards: []config.ShardConfig{{Name: DefaultShard}}},
+		{Key: "shardkey", Shards: []config.ShardConfig{{Name: "a"}, {Name: "a"}}},
+		{Key: "shardkey", Shards: []config.ShardConfig{{Name: "a", Keys: []string{"1"}}, {Name: "b", Keys: []string{"1"}}}},
+	}
+	for _, cfg := range tests {
+		if _, err := newShards(cfg, &Cluster{}); err == nil {
+			t.Errorf("newShards(%+v) returned no error", cfg)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+"use strict";
+
+const pageSize = 20;
+let page = 1;
+let query = "";
+
+const $ = (id) => document.getElementById(id);
+
+// askKey запрашивает API-ключ и сохраняет его в браузере.
+function askKey() {
+	const key = prompt("API-ключ", localStorage.getItem("apiKey") || "");
+	if (key !== null) {
+		localStorage.setItem("apiKey", key.trim());
+	}
+	return key !== null;
+}
+
+// getJSON выполняет GET-запрос с API-ключом и возвращает разобранный JSON ответа.
+// Если сервер не принял ключ, запрашивает новый и повторяет запрос.
+async function getJSON(url, retry = true) {
+	const headers = {};
+	const key = localStorage.getItem("apiKey");
+	if (key) {
+		headers["X-API-Key"] = key;
+	}
+	const resp = await fetch(url, { headers: headers });
+	if (resp.status === 401 && retry && askKey()) {
+		return getJSON(url, false);
+	}
+	if (!resp.ok) {
+		throw new Error(resp.status + " " + (await resp.text()).trim());
+	}
+	return resp.json();
+}
+
+// cell создает ячейку таблицы с текстом.
+function cell(text) {
+	const td = document.createElement("td");
+	td.textContent = text;
+	return td;
+}
+
+// loadOrders загружает текущую страницу списка заказов; при заданном запросе - страницу результатов поиска.
+async function loadOrders() {
+	const params = new URLSearchParams({ page: page, size: pageSize, view: "summary" });
+	let path = "/api/v1/orders?";
+	if (query) {
+		params.set("q", query);
+		path = "/api/v1/orders/search?";
+	}
+	const tbody = $("orders");
+	try {
+		const data = await getJSON(path + params);
+		tbody.replaceChildren();
+		for (const order of data.orders) {
+			const tr = document.createElement("tr");
+			tr.append(
+				cell(order.order_uid),
+				cell(order.track_number),
+				cell(order.customer_id),
+				cell(order.delivery_service),
+				cell(order.payment.amount + " " + order.payment.currency),
+				cell(order.date_created),
+			);
+			tr.addEventListener("click", () => showOrder(order.order_uid));
+			tbody.append(tr);
+		}
+		if (data.orders.length === 0) {
+			const tr = document.createElement("tr");
+			const td = cell("Заказы не найдены");
+			td.colSpan = 6;
+			td.className = "empty";
+			tr.append(td);
+			tbody.append(tr);
+		}
+		const pages = Math.max(1, Math.ceil(data.total / data.size));
+		$("page-info").textContent = "Страница " + data.page + " из " + pages + " (всего " + data.total + ")";
+		$("prev").disabled = data.page <= 1;
+		$("next").disabled = data.page >= pages;
+		if (query && data.total === 1) {
+			showOrder(data.orders[0].order_uid);
+		}
+	} catch (err) {
+		tbody.replaceChildren();
+		$("page-info").textContent = "Ошибка загрузки: " + err.message;
+	}
+}
+
+// fillFields заполняет список определений парами «название - значение».
+function fillFields(dl, fields) {
+	dl.replaceChildren();
+	for (const [name, value] of fields) {
+		const dt = document.createElement("dt");
+		dt.textContent = name;
+		const dd = document.createElement("dd");
+		dd.textContent = value;
+		dl.append(dt, dd);
+	}
+}
+
+// showOrder показывает подробности заказа.
+async function showOrder(uid) {
+	let order;
+	try {
+		order = await getJSON("/order?id=" + encodeURIComponent(uid));
+	} catch (err) {
+		alert("Не удалось загрузить заказ: " + err.message);
+		return;
+	}
+
+	$("detail-title").textContent = "Заказ " + order.order_uid;
+	fillFields($("detail-order"), [
+		["Трек-номер", order.track_number],
+		["Entry", order.entry],
+		["Локаль", order.locale],
+		["ID клиента", order.customer_id],
+		["Служба доставки", order.delivery_service],
+		["Shardkey", order.shardkey],
+		["SM ID", order.sm_id],
+		["Создан", order.date_created],
+		["OOF shard", order.oof_shard],
+	]);
+	const d = order.delivery;
+	fillFields($("detail-delivery"), [
+		["Получатель", d.name],
+		["Телефон", d.phone],
+		["Email", d.email],
+		["Индекс", d.zip],
+		["Город", d.city],
+		["Адрес", d.address],
+		["Регион", d.region],
+	]);
+	const p = order.payment;
+	fillFields($("detail-payment"), [
+		["Транзакция", p.transaction],
+		["Request ID", p.request_id],
+		["Сумма", p.amount + " " + p.currency],
+		["Провайдер", p.provider],
+		["Банк", p.bank],
+		["Дата оплаты", p.payment_dt],
+		["Доставка", p.delivery_cost],
+		["Товары", p.goods_total],
+		["Пошлина", p.custom_fee],
+	]);
+
+	const tbody = $("detail-items");
+	tbody.replaceChildren();
+	for (const item of order.items || []) {
+		const tr = document.createElement("tr");
+		tr.append(
+			cell(item.chrt_id),
+			cell(item.nm_id),
+			cell(item.name),
+			cell(item.brand),
+			cell(item.size),
+			cell(item.price),
+			cell(item.sale + "%"),
+			cell(item.total_price),
+			cell(item.status),
+		);
+		tbody.append(tr);
+	}
+
+	$("list").hidden = true;
+	$("detail").hidden = false;
+}
+
+// loadCounters обновляет счетчики принятых заказов.
+async function loadCounters() {
+	try {
+		const c = await getJSON("/api/v1/counters", false);
+		$("ingested").textContent = c.ingested;
+		$("cached").textContent = c.cached;
+	} catch (err) {
+		// счетчики не критичны, повторим при следующем опросе
+	}
+}
+
+$("search").addEventListener("submit", (e) => {
+	e.preventDefault();
+	query = $("query").value.trim();
+	page = 1;
+	loadOrders();
+});
+
+$("reset").addEventListener("click", () => {
+	$("query").value = "";
+	query = "";
+	page = 1;
+	loadOrders();
+});
+
+$("prev").addEventListener("click", () => {
+	page--;
+	loadOrders();
+});
+
+$("next").addEventListener("click", () => {
+	page++;
+	loadOrders();
+});
+
+$("api-key").addEventListener("click", () => {
+	if (askKey()) {
+		loadOrders();
+	}
+});
+
+$("back").addEventListener("click", () => {
+	$("detail").hidden = true;
+	$("list").hidden = false;
+});
+
+loadOrders();
+loadCounters();
+setInterval(loadCounters, 2000);
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// Handler возвращает обработчик, раздающий встроенный веб-интерфейс просмотра заказов.
+// Все ресурсы встроены в бинарный файл, внешние CDN не используются.
+func Handler() http.Handler {
+	root, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err) // каталог static встроен при компиляции, ошибка здесь невозможна
+	}
+	return http.FileServer(http.FS(root))
+}
@@ -0,0 +1,239 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"main.go/internal/pii"
+)
+
+const (
+	HeaderSignature = "X-Webhook-Signature" // HeaderSignature заголовок с HMAC-подписью тела запроса.
+	HeaderTimestamp = "X-Webhook-Timestamp" // HeaderTimestamp заголовок с временем отправки (Unix, секунды).
+	HeaderEvent     = "X-Webhook-Event"     // HeaderEvent заголовок с типом события.
+	HeaderID        = "X-Webhook-Id"        // HeaderID заголовок с идентификатором события для дедупликации.
+
+	maxAttempts  = 10                                  // maxAttempts количество попыток доставки до перевода события в failed.
+	baseBackoff  = 5 * time.Second                     // baseBackoff задержка перед второй попыткой.
+	maxBackoff   = time.Hour                           // maxBackoff максимальная задержка между попытками.
+	batchSize    = 20                                  // batchSize количество событий, обрабатываемых за один проход.
+	pollInterval = 2 * time.Second                     // pollInterval период опроса исходящей очереди.
+	sendTimeout  = 10 * time.Second                    // sendTimeout таймаут одного запроса к подписчику.
+	leaseTimeout = batchSize*sendTimeout + time.Minute // leaseTimeout время, на которое захваченная порция скрыта от других экземпляров.
+)
+
+// Sign вычисляет подпись тела запроса: sha256=hex(HMAC-SHA256(secret, "<timestamp>.<body>")).
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись запроса на стороне получателя.
+func Verify(secret, signature string, timestamp int64, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+// Backoff возвращает задержку перед следующей попыткой после attempt неудачных попыток.
+func Backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// Send отправляет подписанное событие на url. Ответ со статусом вне диапазона 2xx считается ошибкой.
+func Send(ctx context.Context, client *http.Client, url, secret, eventType, eventID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderID, eventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// RunDispatcher периодически доставляет события из исходящей очереди. Не возвращает управление.
+func RunDispatcher(db *sql.DB) {
+	client := &http.Client{Timeout: sendTimeout}
+	for {
+		n, err := dispatchBatch(context.Background(), db, client)
+		if err != nil {
+			fmt.Println("Ошибка доставки вебхуков:", err)
+		}
+		if n < batchSize {
+			time.Sleep(pollInterval)
+		}
+	}
+}
+
+// pending событие исходящей очереди, захваченное для отправки.
+type pending struct {
+	id, subscriptionID int64
+	eventID, eventType string
+	payload            []byte
+	attempt            int
+	url, secret        string
+}
+
+// result итог одной попытки доставки.
+type result struct {
+	code     int
+	err      error
+	duration time.Duration
+}
+
+// dispatchBatch доставляет одну порцию готовых к отправке событий и возвращает их количество.
+// События захватываются короткой транзакцией, отправляются вне ее, а итоги записываются второй транзакцией,
+// поэтому медленные подписчики не держат блокировки строк очереди.
+func dispatchBatch(ctx context.Context, db *sql.DB, client *http.Client) (int, error) {
+	batch, err := claimBatch(ctx, db)
+	if err != nil || len(batch) == 0 {
+		return 0, err
+	}
+
+	results := make([]result, len(batch))
+	for i, p := range batch {
+		start := time.Now()
+		body, err := pii.DecryptEnvelope(p.payload, "data")
+		if err == nil {
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			results[i].code, err = Send(sendCtx, client, p.url, p.secret, p.eventType, p.eventID, body)
+			cancel()
+		}
+		results[i].err = err
+		results[i].duration = time.Since(start)
+	}
+
+	return len(batch), recordResults(ctx, batch, results, db)
+}
+
+// claimBatch захватывает порцию готовых к отправке событий. Строки выбираются с SKIP LOCKED,
+// а время следующей попытки сдвигается на leaseTimeout, поэтому после фиксации транзакции
+// другие экземпляры сервиса не возьмут событие, пока идет отправка. Если экземпляр упадет,
+// не записав итог, событие снова станет доступно по истечении аренды. Счетчик попыток
+// увеличивается при захвате и служит признаком владения строкой.
+func claimBatch(ctx context.Context, db *sql.DB) ([]pending, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH claimed AS (
+			UPDATE webhook_outbox SET attempts = attempts + 1, next_attempt_at = now() + $2 * interval '1 second'
+			WHERE id IN (
+				SELECT o.id
+				FROM webhook_outbox o
+				JOIN webhook_subscriptions s ON s.id = o.subscription_id
+				WHERE o.status = 'pending' AND o.next_attempt_at <= now() AND s.active
+				ORDER BY o.id
+				LIMIT $1
+				FOR UPDATE OF o SKIP LOCKED)
+			RETURNING id, subscription_id, event_id, event_type, payload, attempts
+		)
+		SELECT c.id, c.event_id, c.event_type, c.payload, c.attempts, s.id, s.url, s.secret
+		FROM claimed c
+		JOIN webhook_subscriptions s ON s.id = c.subscription_id
+		ORDER BY c.id`, batchSize, leaseTimeout.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook outbox: %v", err)
+	}
+	defer rows.Close()
+
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.eventID, &p.eventType, &p.payload, &p.attempt, &p.subscriptionID, &p.url, &p.secret); err != nil {
+			return nil, fmt.Errorf("error scanning webhook outbox row: %v", err)
+		}
+		batch = append(batch, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return batch, nil
+}
+
+// recordResults записывает итоги отправки в журнал доставки и обновляет состояние событий одной транзакцией.
+// Событие, которое после истечения аренды захватил другой экземпляр, не обновляется.
+func recordResults(ctx context.Context, batch []pending, results []result, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	for i, p := range batch {
+		r := results[i]
+		status := "delivered"
+		next := time.Now()
+		if r.err != nil {
+			status = "pending"
+			if p.attempt >= maxAttempts {
+				status = "failed"
+			}
+			next = next.Add(Backoff(p.attempt))
+		}
+		res, err := tx.ExecContext(ctx, `
+			UPDATE webhook_outbox SET status = $1, next_attempt_at = $2
+			WHERE id = $3 AND attempts = $4 AND status = 'pending'`, status, next, p.id, p.attempt)
+		if err != nil {
+			return fmt.Errorf("ошибка обновления исходящей очереди: %v", err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			continue
+		}
+
+		errText := ""
+		if r.err != nil {
+			errText = r.err.Error()
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries (outbox_id, subscription_id, event_type, attempt, status_code, error, duration_ms)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			p.id, p.subscriptionID, p.eventType, p.attempt, r.code, errText, r.duration.Milliseconds())
+		if err != nil {
+			return fmt.Errorf("ошибка записи журнала доставки: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return nil
+}
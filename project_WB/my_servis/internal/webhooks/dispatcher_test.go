@@ -0,0 +1,78 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSendSignsRequest(t *testing.T) {
+	const secret = "s3cr3t"
+	body := []byte(`{"id":"evt_1","type":"order.stored","data":{"order_uid":"order_1"}}`)
+
+	var verified bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("bad timestamp header: %v", err)
+		}
+		if r.Header.Get(HeaderEvent) != EventOrderStored || r.Header.Get(HeaderID) != "evt_1" {
+			t.Errorf("unexpected event headers: %v", r.Header)
+		}
+		verified = Verify(secret, r.Header.Get(HeaderSignature), ts, got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	code, err := Send(context.Background(), receiver.Client(), receiver.URL, secret, EventOrderStored, "evt_1", body)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("Send() = %d, %v", code, err)
+	}
+	if !verified {
+		t.Error("receiver could not verify signature")
+	}
+}
+
+func TestSendFailsOnErrorStatus(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	code, err := Send(context.Background(), receiver.Client(), receiver.URL, "secret", EventOrderStored, "evt_1", []byte("{}"))
+	if err == nil || code != http.StatusServiceUnavailable {
+		t.Errorf("Send() = %d, %v; want 503 and error", code, err)
+	}
+}
+
+func TestVerifyRejectsTamperedBody(t *testing.T) {
+	sig := Sign("secret", 1700000000, []byte(`{"amount":100}`))
+	if Verify("secret", sig, 1700000000, []byte(`{"amount":999}`)) {
+		t.Error("tampered body passed verification")
+	}
+	if Verify("other", sig, 1700000000, []byte(`{"amount":100}`)) {
+		t.Error("wrong secret passed verification")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{5, 80 * time.Second},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
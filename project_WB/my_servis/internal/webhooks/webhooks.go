@@ -0,0 +1,258 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	"github.com/jackc/pgx/v5/pgtype"
+	"main.go/internal/pii"
+)
+
+const (
+	// EventOrderStored событие о сохранении нового заказа.
+	EventOrderStored = "order.stored"
+	// EventAll фильтр подписки на все события.
+	EventAll = "*"
+)
+
+// ErrNotFound возвращается, если подписка не найдена.
+var ErrNotFound = errors.New("подписка не найдена")
+
+// typeMap используется для чтения массивов Postgres через database/sql.
+var typeMap = pgtype.NewMap()
+
+// knownEvents события, на которые можно подписаться.
+var knownEvents = map[string]bool{
+	EventOrderStored: true,
+	EventAll:         true,
+}
+
+// Subscription представляет подписку партнера на события заказов.
+type Subscription struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery представляет запись журнала доставки вебхука.
+type Delivery struct {
+	ID         int64     `json:"id"`
+	OutboxID   int64     `json:"outbox_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Envelope тело запроса, отправляемого подписчику.
+type Envelope struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      model.Order `json:"data"`
+}
+
+// CreateTables создает таблицы подписок, исходящей очереди и журнала доставки.
+func CreateTables(db *sql.DB) {
+	queries := []string{`
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id BIGSERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		events TEXT[] NOT NULL,
+		secret VARCHAR(255) NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`, `
+	CREATE TABLE IF NOT EXISTS webhook_outbox (
+		id BIGSERIAL PRIMARY KEY,
+		subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event_id VARCHAR(255) NOT NULL,
+		event_type VARCHAR(255) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(16) NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`, `
+	CREATE INDEX IF NOT EXISTS webhook_outbox_pending_idx
+		ON webhook_outbox (next_attempt_at) WHERE status = 'pending';`, `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		outbox_id BIGINT NOT NULL REFERENCES webhook_outbox(id) ON DELETE CASCADE,
+		subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event_type VARCHAR(255) NOT NULL,
+		attempt INT NOT NULL,
+		status_code INT NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`, `
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx
+		ON webhook_deliveries (subscription_id, id DESC);`}
+
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			log.Fatalf("Error creating webhook tables: %v", err)
+		}
+	}
+}
+
+// ValidateEvents проверяет, что фильтр событий не пуст и содержит только известные события.
+func ValidateEvents(events []string) error {
+	if len(events) == 0 {
+		return fmt.Errorf("список событий пуст")
+	}
+	for _, e := range events {
+		if !knownEvents[e] {
+			return fmt.Errorf("неизвестное событие: %s", e)
+		}
+	}
+	return nil
+}
+
+// CreateSubscription сохраняет новую подписку. Если секрет не задан, он генерируется.
+func CreateSubscription(sub Subscription, db *sql.DB) (Subscription, error) {
+	if sub.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			return sub, err
+		}
+		sub.Secret = secret
+	}
+	err := db.QueryRow(`
+		INSERT INTO webhook_subscriptions (url, events, secret)
+		VALUES ($1, $2, $3)
+		RETURNING id, active, created_at`,
+		sub.URL, sub.Events, sub.Secret).Scan(&sub.ID, &sub.Active, &sub.CreatedAt)
+	if err != nil {
+		return sub, fmt.Errorf("ошибка создания подписки: %v", err)
+	}
+	return sub, nil
+}
+
+// ListSubscriptions возвращает все подписки без секретов.
+func ListSubscriptions(db *sql.DB) ([]Subscription, error) {
+	rows, err := db.Query(`SELECT id, url, events, active, created_at FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	result := []Subscription{}
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.ID, &sub.URL, typeMap.SQLScanner(&sub.Events), &sub.Active, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning webhook subscription row: %v", err)
+		}
+		result = append(result, sub)
+	}
+	return result, rows.Err()
+}
+
+// DeleteSubscription удаляет подписку вместе с ее очередью и журналом доставки.
+func DeleteSubscription(id int64, db *sql.DB) error {
+	res, err := db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления подписки: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListDeliveries возвращает последние записи журнала доставки по подписке.
+func ListDeliveries(subscriptionID int64, limit int, db *sql.DB) ([]Delivery, error) {
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM webhook_subscriptions WHERE id = $1)`, subscriptionID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error checking webhook subscription: %v", err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := db.Query(`
+		SELECT id, outbox_id, event_type, attempt, status_code, error, duration_ms, created_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY id DESC
+		LIMIT $2`, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	result := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.OutboxID, &d.EventType, &d.Attempt, &d.StatusCode, &d.Error, &d.DurationMS, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery row: %v", err)
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+// Enqueue ставит событие eventID в исходящую очередь для всех активных подписок с подходящим фильтром.
+// Вызывается в транзакции tx, в которой событие заказа отмечается примененным, поэтому строки очереди
+// появляются ровно один раз, даже если сервис упадет во время обработки события.
+func Enqueue(ctx context.Context, eventID, eventType string, createdAt time.Time, order model.Order, tx *sql.Tx) error {
+	// В очереди персональные данные хранятся зашифрованными, расшифровываются они при отправке
+	order.Delivery = pii.EncryptDelivery(order.Delivery)
+	payload, err := json.Marshal(Envelope{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: createdAt.UTC(),
+		Data:      order,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации события: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_outbox (subscription_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3
+		FROM webhook_subscriptions
+		WHERE active AND ($2 = ANY(events) OR '*' = ANY(events))`,
+		eventID, eventType, payload)
+	if err != nil {
+		return fmt.Errorf("ошибка постановки вебхука в очередь: %v", err)
+	}
+	return nil
+}
+
+// generateSecret генерирует случайную hex-строку длиной 32 байта.
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации секрета: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// EraseOrders стирает персональные данные заказов в событиях исходящей очереди вебхуков,
+// включая уже доставленные.
+func EraseOrders(orderUIDs []string, db *sql.DB) error {
+	_, err := db.Exec(`
+		UPDATE webhook_outbox SET
+			payload = jsonb_set(jsonb_set(payload, '{data,customer_id}', '""'), '{data,delivery}',
+				COALESCE(payload#>'{data,delivery}', '{}') || '{"name": "", "phone": "", "zip": "", "address": "", "email": ""}')
+		WHERE payload#>>'{data,order_uid}' = ANY($1)`, orderUIDs)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления данных из очереди вебхуков: %v", err)
+	}
+	return nil
+}
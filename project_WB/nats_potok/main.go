@@ -37,4 +37,21 @@ func main() {
 	}
 
 	log.Printf("Created stream '%s' with subject '%s'\n", streamName, subject)
+
+	// Создание потока для событий order.accepted, публикуемых сервисом из outbox
+	eventsSubject := "orders.accepted"
+	eventsStream := "Orders-events"
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:       eventsStream,
+		Subjects:   []string{eventsSubject},
+		Retention:  nats.LimitsPolicy,
+		MaxAge:     24 * time.Hour,
+		Duplicates: 10 * time.Minute, // окно дедупликации по заголовку Nats-Msg-Id
+		Storage:    nats.FileStorage,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Created stream '%s' with subject '%s'\n", eventsStream, eventsSubject)
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+	pb "github.com/Selandro/my_servis_order/project_WB/orderspb"
+	"github.com/hamba/avro/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// encoder кодирует заказ в формат сообщения.
+type encoder struct {
+	contentType string
+	encode      func(model.Order) ([]byte, error)
+}
+
+// encoders поддерживаемые форматы сообщений по имени флага -format.
+var encoders = map[string]encoder{
+	"json":     {pb.ContentTypeJSON, func(o model.Order) ([]byte, error) { return json.Marshal(o) }},
+	"protobuf": {pb.ContentTypeProtobuf, func(o model.Order) ([]byte, error) { return proto.Marshal(pb.FromModel(o)) }},
+	"avro":     {pb.ContentTypeAvro, encodeAvro},
+	"msgpack":  {pb.ContentTypeMsgpack, encodeMsgpack},
+}
+
+// formatOrder порядок форматов в режиме -format=all.
+var formatOrder = []string{"json", "protobuf", "avro", "msgpack"}
+
+var (
+	avroSchema = avro.MustParse(pb.AvroSchema)
+	avroAPI    = avro.Config{TagKey: "json"}.Freeze()
+)
+
+// selectEncoders возвращает кодировщики для значения флага -format.
+func selectEncoders(format string) ([]encoder, error) {
+	if format == "all" {
+		var result []encoder
+		for _, name := range formatOrder {
+			result = append(result, encoders[name])
+		}
+		return result, nil
+	}
+	e, ok := encoders[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q: use json, protobuf, avro, msgpack or all", format)
+	}
+	return []encoder{e}, nil
+}
+
+func encodeAvro(o model.Order) ([]byte, error) {
+	return avroAPI.Marshal(avroSchema, o)
+}
+
+func encodeMsgpack(o model.Order) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
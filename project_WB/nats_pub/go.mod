@@ -2,12 +2,35 @@ module main.go
 
 go 1.22.0
 
-require github.com/nats-io/nats.go v1.34.1
+require (
+	github.com/Selandro/my_servis_order/project_WB/ordermodel v0.0.0
+	github.com/Selandro/my_servis_order/project_WB/orderspb v0.0.0
+	github.com/hamba/avro/v2 v2.27.0
+	github.com/nats-io/nats.go v1.34.1
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	google.golang.org/protobuf v1.34.2
+)
 
 require (
-	github.com/klauspost/compress v1.17.7 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+	google.golang.org/grpc v1.64.1 // indirect
+)
+
+require (
+	github.com/klauspost/compress v1.17.10 // indirect
 	github.com/nats-io/nkeys v0.4.7 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
-	golang.org/x/crypto v0.23.0 // indirect
-	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 )
+
+replace github.com/Selandro/my_servis_order/project_WB/orderspb => ../orderspb
+
+replace github.com/Selandro/my_servis_order/project_WB/ordermodel => ../ordermodel
@@ -0,0 +1,152 @@
+// Package orderclient типизированный Go-клиент HTTP API сервиса заказов.
+//
+// Типы и методы в client_gen.go сгенерированы по спецификации OpenAPI сервиса
+// (my_servis/internal/openapi/openapi.json); здесь реализован только транспорт.
+//
+//	c := orderclient.New("http://localhost:8080", orderclient.WithAPIKey(key))
+//	order, err := c.GetOrder(ctx, orderclient.GetOrderParams{ID: "b563feb7b2b84b6test"})
+package orderclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody максимальный размер текста ошибки, читаемого из ответа.
+const maxErrorBody = 4 << 10
+
+// Client клиент API сервиса заказов. Безопасен для одновременного использования.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	apiKey     string
+	token      string
+	userAgent  string
+}
+
+// Option настройка клиента.
+type Option func(*Client)
+
+// WithHTTPClient задает HTTP-клиент, например с таймаутом или своим транспортом.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.httpClient = h }
+}
+
+// WithAPIKey передает API-ключ в заголовке X-API-Key.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithBearerToken передает JWT в заголовке Authorization: Bearer.
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithUserAgent задает заголовок User-Agent вместо orderclient/<Version>.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New создает клиент сервиса по адресу baseURL, например http://localhost:8080.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		userAgent:  "orderclient/" + Version,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error ответ сервиса с кодом ошибки.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter время до следующей попытки для ответов 429.
+	RetryAfter time.Duration
+}
+
+// Error возвращает код и текст ошибки.
+func (e *Error) Error() string {
+	return fmt.Sprintf("orderclient: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound сообщает, что сервис ответил 404.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsRateLimited сообщает, что запрос отклонен из-за ограничения частоты или дневной квоты.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// hasStatus сообщает, является ли err ошибкой сервиса с кодом status.
+func hasStatus(err error, status int) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == status
+}
+
+// do выполняет запрос: кодирует body в JSON, а успешный ответ декодирует в out.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("orderclient: ошибка кодирования запроса: %v", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		e := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			e.RetryAfter = time.Duration(sec) * time.Second
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("orderclient: ошибка декодирования ответа: %v", err)
+	}
+	return nil
+}
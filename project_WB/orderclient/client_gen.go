@@ -0,0 +1,858 @@
+// Code generated by genclient from openapi.json. DO NOT EDIT.
+
+package orderclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+// Version is the API version (info.version) the client was generated from.
+const Version = "1.0.0"
+
+// Basket is the Basket schema.
+type Basket struct {
+	Currency  string  `json:"currency"`
+	Orders    int64   `json:"orders"`
+	Revenue   int64   `json:"revenue"`
+	AvgAmount float64 `json:"avg_amount"`
+	AvgItems  float64 `json:"avg_items"`
+}
+
+// BatchGetRequest is the BatchGetRequest schema.
+type BatchGetRequest struct {
+	// Order UIDs or track numbers, at most 500.
+	IDs []string `json:"ids"`
+	// Order fields in the response; cannot be combined with view.
+	Fields []string `json:"fields,omitempty"`
+	View   string   `json:"view,omitempty"`
+}
+
+// BatchGetResult is the BatchGetResult schema.
+type BatchGetResult struct {
+	Orders  []Order  `json:"orders"`
+	Missing []string `json:"missing"`
+}
+
+// Breakdown is the Breakdown schema.
+type Breakdown struct {
+	Key      string `json:"key"`
+	Currency string `json:"currency"`
+	Orders   int64  `json:"orders"`
+	Revenue  int64  `json:"revenue"`
+}
+
+// ClientUsage is the ClientUsage schema.
+type ClientUsage struct {
+	Client   string `json:"client"`
+	Requests int64  `json:"requests"`
+	Rejected int64  `json:"rejected"`
+	Quota    int64  `json:"quota,omitempty"`
+	// Share of the daily quota used.
+	QuotaUsed float64      `json:"quota_used,omitempty"`
+	Routes    []RouteUsage `json:"routes"`
+}
+
+// Counters is the Counters schema.
+type Counters struct {
+	// Orders received from NATS by this instance.
+	Ingested int64 `json:"ingested"`
+	// Orders in the cache.
+	Cached int `json:"cached"`
+}
+
+// CreateWebhookRequest is the CreateWebhookRequest schema.
+type CreateWebhookRequest struct {
+	// http or https URL.
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Signing secret; generated when empty.
+	Secret string `json:"secret,omitempty"`
+}
+
+// DeadLetter is the DeadLetter schema.
+type DeadLetter struct {
+	ID      int64  `json:"id"`
+	Subject string `json:"subject"`
+	// Content-Type header of the message, empty for JSON without the header.
+	ContentType string `json:"content_type"`
+	// Message body size in bytes.
+	Size int `json:"size"`
+	// Why the message could not be processed.
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeadLetterMessage is the DeadLetterMessage schema.
+type DeadLetterMessage struct {
+	ID          int64     `json:"id"`
+	Subject     string    `json:"subject"`
+	ContentType string    `json:"content_type"`
+	Error       string    `json:"error"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Message body in base64.
+	Data string `json:"data"`
+}
+
+// Delivery is model.Delivery from the shared order model.
+type Delivery = model.Delivery
+
+// ErasureResult is the ErasureResult schema.
+type ErasureResult struct {
+	Orders []string `json:"orders"`
+	// Number of deleted dead letters of the subject.
+	DeadLetters int `json:"dead_letters"`
+}
+
+// Event is the Event schema.
+type Event struct {
+	ID    int64     `json:"id"`
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	Order Order     `json:"order"`
+}
+
+// EvictRequest is the EvictRequest schema.
+type EvictRequest struct {
+	// Order UIDs, at most 500.
+	IDs []string `json:"ids"`
+}
+
+// EvictResult is the EvictResult schema.
+type EvictResult struct {
+	// Orders that were in the cache.
+	Evicted []string `json:"evicted"`
+	// Orders loaded from the database again.
+	Reloaded []string `json:"reloaded"`
+}
+
+// Item is model.Item from the shared order model.
+type Item = model.Item
+
+// Order is model.Order from the shared order model.
+type Order = model.Order
+
+// OrderPage is the OrderPage schema.
+type OrderPage struct {
+	Orders []Order `json:"orders"`
+	Page   int     `json:"page"`
+	Size   int     `json:"size"`
+	// Number of matching orders on all pages.
+	Total int `json:"total"`
+}
+
+// OrdersBucket is the OrdersBucket schema.
+type OrdersBucket struct {
+	Bucket   time.Time `json:"bucket"`
+	Currency string    `json:"currency"`
+	Orders   int64     `json:"orders"`
+	Revenue  int64     `json:"revenue"`
+	Items    int64     `json:"items"`
+}
+
+// OutboxMetrics is the OutboxMetrics schema.
+type OutboxMetrics struct {
+	// Events waiting to be published.
+	Pending int64 `json:"pending"`
+	// Age of the oldest waiting event.
+	OldestPendingAgeSeconds float64 `json:"oldest_pending_age_seconds"`
+	// Events parked because they could not be prepared for publishing.
+	Failed             int64 `json:"failed"`
+	PublishedTotal     int64 `json:"published_total"`
+	PublishErrorsTotal int64 `json:"publish_errors_total"`
+}
+
+// Payment is model.Payment from the shared order model.
+type Payment = model.Payment
+
+// RouteUsage is the RouteUsage schema.
+type RouteUsage struct {
+	Route    string `json:"route"`
+	Requests int64  `json:"requests"`
+	Rejected int64  `json:"rejected"`
+}
+
+// ShardStatus is the ShardStatus schema.
+type ShardStatus struct {
+	Name string `json:"name"`
+	// Orders of the shard in the cache.
+	Cached int `json:"cached"`
+	// Whether the shard database answers a ping.
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status is the Status schema.
+type Status struct {
+	StartedAt time.Time `json:"started_at"`
+	// Orders received from NATS by this instance.
+	Ingested int64 `json:"ingested"`
+	// Orders in the cache.
+	Cached int `json:"cached"`
+	// NATS messages waiting in the dead letter queue.
+	DeadLetters int           `json:"dead_letters"`
+	Shards      []ShardStatus `json:"shards"`
+}
+
+// Subject is the Subject schema.
+//
+// Data subject; customer_id or email is required.
+type Subject struct {
+	CustomerID string `json:"customer_id,omitempty"`
+	Email      string `json:"email,omitempty"`
+}
+
+// SubjectExport is the SubjectExport schema.
+type SubjectExport struct {
+	Subject     Subject             `json:"subject"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Orders      []Order             `json:"orders"`
+	DeadLetters []DeadLetterMessage `json:"dead_letters"`
+}
+
+// Subscription is the Subscription schema.
+type Subscription struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Returned only when the subscription is created.
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TopEntry is the TopEntry schema.
+type TopEntry struct {
+	Key      string `json:"key"`
+	Currency string `json:"currency"`
+	Items    int64  `json:"items"`
+	Revenue  int64  `json:"revenue"`
+}
+
+// UsageReport is the UsageReport schema.
+type UsageReport struct {
+	Day     string        `json:"day"`
+	Clients []ClientUsage `json:"clients"`
+}
+
+// WebhookDelivery is the WebhookDelivery schema.
+type WebhookDelivery struct {
+	ID         int64     `json:"id"`
+	OutboxID   int64     `json:"outbox_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// EvictCache calls POST /api/v1/admin/cache:evict: Evict orders from the cache.
+//
+// Removes the orders from the cache and loads the ones still present in the database again, for example after a manual fix in the database.
+//
+// Required scope: admin.
+func (c *Client) EvictCache(ctx context.Context, body EvictRequest) (*EvictResult, error) {
+	var result EvictResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/admin/cache:evict", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListDeadLettersParams are the parameters of ListDeadLetters.
+type ListDeadLettersParams struct {
+	// Maximum number of messages, 50 by default.
+	Limit int
+}
+
+// ListDeadLetters calls GET /api/v1/admin/dlq: List dead letters.
+//
+// NATS messages that could not be decoded, oldest first. Message bodies are not returned.
+//
+// Required scope: admin.
+func (c *Client) ListDeadLetters(ctx context.Context, params ListDeadLettersParams) ([]DeadLetter, error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var result []DeadLetter
+	if err := c.do(ctx, http.MethodGet, "/api/v1/admin/dlq", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteDeadLetterParams are the parameters of DeleteDeadLetter.
+type DeleteDeadLetterParams struct {
+	// Required. Dead letter ID.
+	ID int64
+}
+
+// DeleteDeadLetter calls DELETE /api/v1/admin/dlq/{id}: Delete a dead letter.
+//
+// Required scope: admin.
+func (c *Client) DeleteDeadLetter(ctx context.Context, params DeleteDeadLetterParams) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/admin/dlq/"+url.PathEscape(strconv.FormatInt(params.ID, 10)), nil, nil, nil)
+}
+
+// ReplayDeadLetterParams are the parameters of ReplayDeadLetter.
+type ReplayDeadLetterParams struct {
+	// Required. Dead letter ID.
+	ID int64
+}
+
+// ReplayDeadLetter calls POST /api/v1/admin/dlq/{id}/replay: Replay a dead letter.
+//
+// Publishes the message to its subject again with the original Content-Type and removes it from the queue. If it still cannot be decoded, it returns to the queue under a new ID.
+//
+// Required scope: admin.
+func (c *Client) ReplayDeadLetter(ctx context.Context, params ReplayDeadLetterParams) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/admin/dlq/"+url.PathEscape(strconv.FormatInt(params.ID, 10))+"/replay", nil, nil, nil)
+}
+
+// GetOutboxMetrics calls GET /api/v1/admin/outbox: Outbox metrics.
+//
+// Size and age of the order.accepted outbox of all shards, events parked after repeated failures and publish counters since start.
+//
+// Required scope: admin.
+func (c *Client) GetOutboxMetrics(ctx context.Context) (*OutboxMetrics, error) {
+	var result OutboxMetrics
+	if err := c.do(ctx, http.MethodGet, "/api/v1/admin/outbox", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetStatus calls GET /api/v1/admin/status: Service status.
+//
+// Start time, order counters, the number of dead letters and the health of every shard database.
+//
+// Required scope: admin.
+func (c *Client) GetStatus(ctx context.Context) (*Status, error) {
+	var result Status
+	if err := c.do(ctx, http.MethodGet, "/api/v1/admin/status", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetCounters calls GET /api/v1/counters: Get ingestion and cache counters.
+//
+// Required scope: orders:read.
+func (c *Client) GetCounters(ctx context.Context) (*Counters, error) {
+	var result Counters
+	if err := c.do(ctx, http.MethodGet, "/api/v1/counters", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListOrdersParams are the parameters of ListOrders.
+type ListOrdersParams struct {
+	// Order UID, track number or customer ID.
+	Q string
+	// Page number starting from 1.
+	Page int
+	// Page size.
+	Size int
+	// Comma-separated order fields, for example order_uid,delivery.city,items.brand. Cannot be combined with view.
+	Fields string
+	// Named order view. Cannot be combined with fields.
+	View string
+}
+
+// ListOrders calls GET /api/v1/orders: List cached orders.
+//
+// Returns a page of cached orders, optionally filtered by order UID, track number or customer ID.
+//
+// Required scope: orders:read.
+func (c *Client) ListOrders(ctx context.Context, params ListOrdersParams) (*OrderPage, error) {
+	query := url.Values{}
+	if params.Q != "" {
+		query.Set("q", params.Q)
+	}
+	if params.Page != 0 {
+		query.Set("page", strconv.Itoa(params.Page))
+	}
+	if params.Size != 0 {
+		query.Set("size", strconv.Itoa(params.Size))
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+	if params.View != "" {
+		query.Set("view", params.View)
+	}
+	var result OrderPage
+	if err := c.do(ctx, http.MethodGet, "/api/v1/orders", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QueryOrdersParams are the parameters of QueryOrders.
+type QueryOrdersParams struct {
+	// Required. SQL/JSON path expression over the order document.
+	Path string
+	// Page number starting from 1.
+	Page int
+	// Page size.
+	Size int
+	// Comma-separated order fields, for example order_uid,delivery.city,items.brand. Cannot be combined with view.
+	Fields string
+	// Named order view. Cannot be combined with fields.
+	View string
+}
+
+// QueryOrders calls GET /api/v1/orders/query: Find orders by a JSON path expression.
+//
+// Returns a page of orders whose document matches the JSON path expression, for example $.items[*] ? (@.brand == "Vivienne Sabo"). The query runs on every shard.
+//
+// Required scope: orders:read.
+func (c *Client) QueryOrders(ctx context.Context, params QueryOrdersParams) (*OrderPage, error) {
+	query := url.Values{}
+	query.Set("path", params.Path)
+	if params.Page != 0 {
+		query.Set("page", strconv.Itoa(params.Page))
+	}
+	if params.Size != 0 {
+		query.Set("size", strconv.Itoa(params.Size))
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+	if params.View != "" {
+		query.Set("view", params.View)
+	}
+	var result OrderPage
+	if err := c.do(ctx, http.MethodGet, "/api/v1/orders/query", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SearchOrdersParams are the parameters of SearchOrders.
+type SearchOrdersParams struct {
+	// Required. Search query.
+	Q string
+	// Page number starting from 1.
+	Page int
+	// Page size.
+	Size int
+	// Comma-separated order fields, for example order_uid,delivery.city,items.brand. Cannot be combined with view.
+	Fields string
+	// Named order view. Cannot be combined with fields.
+	View string
+}
+
+// SearchOrders calls GET /api/v1/orders/search: Search orders.
+//
+// Returns a page of orders matching the search query, most relevant first. Free words are matched by full-text search over the recipient, address and items and exactly against the order UID, track number and customer ID. Field terms narrow the search: name, phone, email, address, city, region, zip, brand and item match a substring; uid, track, customer, service, currency, provider and bank match exactly; amount, status, nm and date support the operators =, >, >=, < and <=. Quote values containing spaces and prefix a term with - to negate it, for example brand:Vivienne city:Moscow amount>1000 -status:202. The search runs on every shard. When PII encryption is enabled, free words are not matched against the recipient name and address, and name, phone, email and address terms are rejected with 400.
+//
+// Required scope: orders:read.
+func (c *Client) SearchOrders(ctx context.Context, params SearchOrdersParams) (*OrderPage, error) {
+	query := url.Values{}
+	query.Set("q", params.Q)
+	if params.Page != 0 {
+		query.Set("page", strconv.Itoa(params.Page))
+	}
+	if params.Size != 0 {
+		query.Set("size", strconv.Itoa(params.Size))
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+	if params.View != "" {
+		query.Set("view", params.View)
+	}
+	var result OrderPage
+	if err := c.do(ctx, http.MethodGet, "/api/v1/orders/search", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BatchGetOrders calls POST /api/v1/orders:batchGet: Get several orders by UID or track number.
+//
+// Returns up to 500 orders in the order of the requested IDs. IDs that match nothing are listed in missing.
+//
+// Required scope: orders:read.
+func (c *Client) BatchGetOrders(ctx context.Context, body BatchGetRequest) (*BatchGetResult, error) {
+	var result BatchGetResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/orders:batchGet", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// EraseSubject calls POST /api/v1/privacy/erasure: Erase personal data of a customer.
+//
+// Anonymizes the customer's orders in every shard and the webhook queue, removes them from the cache and the event history, and deletes the customer's dead letters.
+//
+// Required scope: admin.
+func (c *Client) EraseSubject(ctx context.Context, body Subject) (*ErasureResult, error) {
+	var result ErasureResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/privacy/erasure", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExportSubjectParams are the parameters of ExportSubject.
+type ExportSubjectParams struct {
+	// Customer ID; customer_id or email is required.
+	CustomerID string
+	// Recipient email; customer_id or email is required.
+	Email string
+}
+
+// ExportSubject calls GET /api/v1/privacy/export: Export all orders and dead letters of a customer.
+//
+// Required scope: orders:export.
+func (c *Client) ExportSubject(ctx context.Context, params ExportSubjectParams) (*SubjectExport, error) {
+	query := url.Values{}
+	if params.CustomerID != "" {
+		query.Set("customer_id", params.CustomerID)
+	}
+	if params.Email != "" {
+		query.Set("email", params.Email)
+	}
+	var result SubjectExport
+	if err := c.do(ctx, http.MethodGet, "/api/v1/privacy/export", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StatsBasketParams are the parameters of StatsBasket.
+type StatsBasketParams struct {
+	// Start of the period, RFC 3339 or YYYY-MM-DD.
+	From string
+	// End of the period, RFC 3339 or YYYY-MM-DD.
+	To string
+	// Only orders paid in this currency, for example USD. Amounts in different currencies are never summed.
+	Currency string
+}
+
+// StatsBasket calls GET /api/v1/stats/basket: Average order amount and item count per currency.
+//
+// Required scope: orders:read.
+func (c *Client) StatsBasket(ctx context.Context, params StatsBasketParams) ([]Basket, error) {
+	query := url.Values{}
+	if params.From != "" {
+		query.Set("from", params.From)
+	}
+	if params.To != "" {
+		query.Set("to", params.To)
+	}
+	if params.Currency != "" {
+		query.Set("currency", params.Currency)
+	}
+	var result []Basket
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/basket", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StatsDeliveryParams are the parameters of StatsDelivery.
+type StatsDeliveryParams struct {
+	// Start of the period, RFC 3339 or YYYY-MM-DD.
+	From string
+	// End of the period, RFC 3339 or YYYY-MM-DD.
+	To string
+	// Only orders paid in this currency, for example USD. Amounts in different currencies are never summed.
+	Currency string
+	// Grouping key.
+	Group string
+}
+
+// StatsDelivery calls GET /api/v1/stats/delivery: Orders by delivery service or region.
+//
+// Required scope: orders:read.
+func (c *Client) StatsDelivery(ctx context.Context, params StatsDeliveryParams) ([]Breakdown, error) {
+	query := url.Values{}
+	if params.From != "" {
+		query.Set("from", params.From)
+	}
+	if params.To != "" {
+		query.Set("to", params.To)
+	}
+	if params.Currency != "" {
+		query.Set("currency", params.Currency)
+	}
+	if params.Group != "" {
+		query.Set("group", params.Group)
+	}
+	var result []Breakdown
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/delivery", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StatsOrdersParams are the parameters of StatsOrders.
+type StatsOrdersParams struct {
+	// Start of the period, RFC 3339 or YYYY-MM-DD.
+	From string
+	// End of the period, RFC 3339 or YYYY-MM-DD.
+	To string
+	// Only orders paid in this currency, for example USD. Amounts in different currencies are never summed.
+	Currency string
+	// Bucket size.
+	Interval string
+}
+
+// StatsOrders calls GET /api/v1/stats/orders: Orders and revenue by UTC day or hour per currency.
+//
+// Required scope: orders:read.
+func (c *Client) StatsOrders(ctx context.Context, params StatsOrdersParams) ([]OrdersBucket, error) {
+	query := url.Values{}
+	if params.From != "" {
+		query.Set("from", params.From)
+	}
+	if params.To != "" {
+		query.Set("to", params.To)
+	}
+	if params.Currency != "" {
+		query.Set("currency", params.Currency)
+	}
+	if params.Interval != "" {
+		query.Set("interval", params.Interval)
+	}
+	var result []OrdersBucket
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/orders", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StatsPaymentsParams are the parameters of StatsPayments.
+type StatsPaymentsParams struct {
+	// Start of the period, RFC 3339 or YYYY-MM-DD.
+	From string
+	// End of the period, RFC 3339 or YYYY-MM-DD.
+	To string
+	// Only orders paid in this currency, for example USD. Amounts in different currencies are never summed.
+	Currency string
+	// Grouping key.
+	Group string
+}
+
+// StatsPayments calls GET /api/v1/stats/payments: Revenue by payment provider, bank or currency.
+//
+// Required scope: orders:read.
+func (c *Client) StatsPayments(ctx context.Context, params StatsPaymentsParams) ([]Breakdown, error) {
+	query := url.Values{}
+	if params.From != "" {
+		query.Set("from", params.From)
+	}
+	if params.To != "" {
+		query.Set("to", params.To)
+	}
+	if params.Currency != "" {
+		query.Set("currency", params.Currency)
+	}
+	if params.Group != "" {
+		query.Set("group", params.Group)
+	}
+	var result []Breakdown
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/payments", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StatsTopBrandsParams are the parameters of StatsTopBrands.
+type StatsTopBrandsParams struct {
+	// Start of the period, RFC 3339 or YYYY-MM-DD.
+	From string
+	// End of the period, RFC 3339 or YYYY-MM-DD.
+	To string
+	// Only orders paid in this currency, for example USD. Amounts in different currencies are never summed.
+	Currency string
+	// Number of entries.
+	Limit int
+}
+
+// StatsTopBrands calls GET /api/v1/stats/top/brands: Best-selling brands.
+//
+// Required scope: orders:read.
+func (c *Client) StatsTopBrands(ctx context.Context, params StatsTopBrandsParams) ([]TopEntry, error) {
+	query := url.Values{}
+	if params.From != "" {
+		query.Set("from", params.From)
+	}
+	if params.To != "" {
+		query.Set("to", params.To)
+	}
+	if params.Currency != "" {
+		query.Set("currency", params.Currency)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var result []TopEntry
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/top/brands", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StatsTopProductsParams are the parameters of StatsTopProducts.
+type StatsTopProductsParams struct {
+	// Start of the period, RFC 3339 or YYYY-MM-DD.
+	From string
+	// End of the period, RFC 3339 or YYYY-MM-DD.
+	To string
+	// Only orders paid in this currency, for example USD. Amounts in different currencies are never summed.
+	Currency string
+	// Number of entries.
+	Limit int
+}
+
+// StatsTopProducts calls GET /api/v1/stats/top/products: Best-selling products by nm_id.
+//
+// Required scope: orders:read.
+func (c *Client) StatsTopProducts(ctx context.Context, params StatsTopProductsParams) ([]TopEntry, error) {
+	query := url.Values{}
+	if params.From != "" {
+		query.Set("from", params.From)
+	}
+	if params.To != "" {
+		query.Set("to", params.To)
+	}
+	if params.Currency != "" {
+		query.Set("currency", params.Currency)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var result []TopEntry
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/top/products", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetUsageParams are the parameters of GetUsage.
+type GetUsageParams struct {
+	// Day in UTC, today by default.
+	Day string
+}
+
+// GetUsage calls GET /api/v1/usage: API usage report for a day.
+//
+// Accepted and rejected requests by client and route and the share of the daily quota used.
+//
+// Required scope: admin.
+func (c *Client) GetUsage(ctx context.Context, params GetUsageParams) (*UsageReport, error) {
+	query := url.Values{}
+	if params.Day != "" {
+		query.Set("day", params.Day)
+	}
+	var result UsageReport
+	if err := c.do(ctx, http.MethodGet, "/api/v1/usage", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListWebhooks calls GET /api/v1/webhooks: List webhook subscriptions.
+//
+// Required scope: admin.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Subscription, error) {
+	var result []Subscription
+	if err := c.do(ctx, http.MethodGet, "/api/v1/webhooks", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateWebhook calls POST /api/v1/webhooks: Create a webhook subscription.
+//
+// The signing secret is returned only in the response to this request.
+//
+// Required scope: admin.
+func (c *Client) CreateWebhook(ctx context.Context, body CreateWebhookRequest) (*Subscription, error) {
+	var result Subscription
+	if err := c.do(ctx, http.MethodPost, "/api/v1/webhooks", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteWebhookParams are the parameters of DeleteWebhook.
+type DeleteWebhookParams struct {
+	// Required. Subscription ID.
+	ID int64
+}
+
+// DeleteWebhook calls DELETE /api/v1/webhooks/{id}: Delete a webhook subscription.
+//
+// Required scope: admin.
+func (c *Client) DeleteWebhook(ctx context.Context, params DeleteWebhookParams) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/webhooks/"+url.PathEscape(strconv.FormatInt(params.ID, 10)), nil, nil, nil)
+}
+
+// ListWebhookDeliveriesParams are the parameters of ListWebhookDeliveries.
+type ListWebhookDeliveriesParams struct {
+	// Required. Subscription ID.
+	ID int64
+	// Maximum number of attempts.
+	Limit int
+}
+
+// ListWebhookDeliveries calls GET /api/v1/webhooks/{id}/deliveries: Delivery log of a subscription.
+//
+// Latest delivery attempts first.
+//
+// Required scope: admin.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, params ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var result []WebhookDelivery
+	if err := c.do(ctx, http.MethodGet, "/api/v1/webhooks/"+url.PathEscape(strconv.FormatInt(params.ID, 10))+"/deliveries", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetOrderParams are the parameters of GetOrder.
+type GetOrderParams struct {
+	// Required. Order UID.
+	ID string
+	// Comma-separated order fields, for example order_uid,delivery.city,items.brand. Cannot be combined with view.
+	Fields string
+	// Named order view. Cannot be combined with fields.
+	View string
+}
+
+// GetOrder calls GET /order: Get an order from the cache.
+//
+// Returns the order in JSON or protobuf depending on the Accept header. Phone and email of the recipient are masked unless the client has the pii:read scope.
+//
+// Required scope: orders:read.
+func (c *Client) GetOrder(ctx context.Context, params GetOrderParams) (*Order, error) {
+	query := url.Values{}
+	query.Set("id", params.ID)
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+	if params.View != "" {
+		query.Set("view", params.View)
+	}
+	var result Order
+	if err := c.do(ctx, http.MethodGet, "/order", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,91 @@
+package orderclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientRequests(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /order", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" || r.Header.Get("Accept") != "application/json" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("id") != "order_1" {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"order_uid":"order_1","payment":{"amount":1817,"payment_dt":1637907727},"date_created":"2021-11-26T06:22:19Z"}`))
+	})
+	mux.HandleFunc("POST /api/v1/orders:batchGet", func(w http.ResponseWriter, r *http.Request) {
+		var req BatchGetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(BatchGetResult{Orders: []Order{}, Missing: req.IDs})
+	})
+	mux.HandleFunc("DELETE /api/v1/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "42" {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /api/v1/counters", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(srv.URL+"/", WithAPIKey("secret"))
+
+	order, err := c.GetOrder(ctx, GetOrderParams{ID: "order_1"})
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if order.OrderUID != "order_1" || order.Payment.Amount != 1817 || order.Payment.PaymentDT.Unix() != 1637907727 {
+		t.Errorf("GetOrder = %+v", order)
+	}
+	if _, err := c.GetOrder(ctx, GetOrderParams{ID: "missing"}); !IsNotFound(err) {
+		t.Errorf("GetOrder(missing): err = %v, want 404", err)
+	}
+
+	result, err := c.BatchGetOrders(ctx, BatchGetRequest{IDs: []string{"a", "b"}})
+	if err != nil || len(result.Missing) != 2 {
+		t.Errorf("BatchGetOrders = %+v, %v", result, err)
+	}
+
+	if err := c.DeleteWebhook(ctx, DeleteWebhookParams{ID: 42}); err != nil {
+		t.Errorf("DeleteWebhook: %v", err)
+	}
+
+	_, err = c.GetCounters(ctx)
+	var e *Error
+	if !IsRateLimited(err) || !errors.As(err, &e) || e.RetryAfter != 3*time.Second || e.Message != "Too Many Requests" {
+		t.Errorf("GetCounters: err = %v, want 429 with Retry-After", err)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var ua string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ua = r.Header.Get("User-Agent")
+		io.WriteString(w, `{"ingested":1,"cached":2}`)
+	}))
+	defer srv.Close()
+
+	counters, err := New(srv.URL).GetCounters(context.Background())
+	if err != nil || counters.Cached != 2 {
+		t.Fatalf("GetCounters = %+v, %v", counters, err)
+	}
+	if ua != "orderclient/"+Version {
+		t.Errorf("User-Agent = %q, want orderclient/%s", ua, Version)
+	}
+}
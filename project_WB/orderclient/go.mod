@@ -0,0 +1,7 @@
+module github.com/Selandro/my_servis_order/project_WB/orderclient
+
+go 1.22.0
+
+require github.com/Selandro/my_servis_order/project_WB/ordermodel v0.0.0
+
+replace github.com/Selandro/my_servis_order/project_WB/ordermodel => ../ordermodel
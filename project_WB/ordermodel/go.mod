@@ -0,0 +1,3 @@
+module github.com/Selandro/my_servis_order/project_WB/ordermodel
+
+go 1.22.0
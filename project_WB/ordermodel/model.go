@@ -0,0 +1,63 @@
+// Package ordermodel содержит общую модель заказа, используемую сервисом заказов и генератором сообщений.
+package ordermodel
+
+import "time"
+
+// Структура Delivery представляет информацию о доставке.
+type Delivery struct {
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Zip     string `json:"zip"`
+	City    string `json:"city"`
+	Address string `json:"address"`
+	Region  string `json:"region"`
+	Email   string `json:"email"`
+}
+
+// Структура Payment представляет информацию о платеже.
+type Payment struct {
+	Transaction  string   `json:"transaction"`
+	RequestID    string   `json:"request_id"`
+	Currency     Currency `json:"currency"`
+	Provider     string   `json:"provider"`
+	Amount       Amount   `json:"amount"`
+	PaymentDT    UnixTime `json:"payment_dt"`
+	Bank         string   `json:"bank"`
+	DeliveryCost Amount   `json:"delivery_cost"`
+	GoodsTotal   Amount   `json:"goods_total"`
+	CustomFee    Amount   `json:"custom_fee"`
+}
+
+// Структура Item представляет информацию о товаре.
+type Item struct {
+	ChrtID      int    `json:"chrt_id"`
+	TrackNumber string `json:"track_number"`
+	Price       Amount `json:"price"`
+	RID         string `json:"rid"`
+	Name        string `json:"name"`
+	Sale        int    `json:"sale"`
+	Size        string `json:"size"`
+	TotalPrice  Amount `json:"total_price"`
+	NMID        int    `json:"nm_id"`
+	Brand       string `json:"brand"`
+	Status      int    `json:"status"`
+}
+
+// Структура Order представляет информацию о заказе.
+type Order struct {
+	SchemaVersion     int       `json:"schema_version"`
+	OrderUID          string    `json:"order_uid"`
+	TrackNumber       string    `json:"track_number"`
+	Entry             string    `json:"entry"`
+	Delivery          Delivery  `json:"delivery"`
+	Payment           Payment   `json:"payment"`
+	Items             []Item    `json:"items"`
+	Locale            string    `json:"locale"`
+	InternalSignature string    `json:"internal_signature"`
+	CustomerID        string    `json:"customer_id"`
+	DeliveryService   string    `json:"delivery_service"`
+	Shardkey          string    `json:"shardkey"`
+	SMID              int       `json:"sm_id"`
+	DateCreated       time.Time `json:"date_created"`
+	OOFShard          string    `json:"oof_shard"`
+}
@@ -0,0 +1,130 @@
+package ordermodel
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	// ErrCurrencyMismatch возвращается при операциях над суммами в разных валютах.
+	ErrCurrencyMismatch = errors.New("суммы в разных валютах")
+	// ErrOverflow возвращается, если результат операции не помещается в int64.
+	ErrOverflow = errors.New("переполнение денежной суммы")
+	// ErrUnknownCurrency возвращается для кода валюты вне ISO 4217.
+	ErrUnknownCurrency = errors.New("неизвестная валюта")
+)
+
+// Amount денежная сумма в минимальных единицах валюты (копейках, центах).
+// В JSON передается целым числом, как и раньше.
+type Amount int64
+
+// Currency код валюты ISO 4217.
+type Currency string
+
+// currencyExponents количество знаков дробной части для поддерживаемых валют ISO 4217.
+var currencyExponents = map[Currency]int{
+	"AMD": 2, "AZN": 2, "BYN": 2, "CNY": 2, "EUR": 2, "GBP": 2, "GEL": 2,
+	"ILS": 2, "INR": 2, "JPY": 0, "KGS": 2, "KRW": 0, "KZT": 2, "MDL": 2,
+	"RUB": 2, "TJS": 2, "TRY": 2, "UAH": 2, "USD": 2, "UZS": 2, "KWD": 3,
+}
+
+// ParseCurrency приводит код валюты к верхнему регистру и проверяет, что он известен.
+func ParseCurrency(s string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(s)))
+	if !c.Valid() {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, s)
+	}
+	return c, nil
+}
+
+// Valid сообщает, является ли код известной валютой ISO 4217.
+func (c Currency) Valid() bool {
+	_, ok := currencyExponents[c]
+	return ok
+}
+
+// Exponent возвращает количество знаков дробной части валюты. Для неизвестных валют считается 2.
+func (c Currency) Exponent() int {
+	if e, ok := currencyExponents[c]; ok {
+		return e
+	}
+	return 2
+}
+
+// Money денежная сумма вместе с валютой.
+type Money struct {
+	Amount   Amount   `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// Add возвращает сумму двух значений. Валюты должны совпадать.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s и %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) || (o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub возвращает разность двух значений. Валюты должны совпадать.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Mul умножает сумму на целое число, например цену на количество товаров.
+func (m Money) Mul(n int64) (Money, error) {
+	if m.Amount == 0 || n == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	r := int64(m.Amount) * n
+	if r/n != int64(m.Amount) || (n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: Amount(r), Currency: m.Currency}, nil
+}
+
+// String форматирует сумму в основных единицах валюты, например "18.17 USD".
+func (m Money) String() string {
+	exp := m.Currency.Exponent()
+	if exp == 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	div := int64(math.Pow10(exp))
+	sign := ""
+	v := int64(m.Amount)
+	if v < 0 {
+		sign = "-"
+	}
+	whole, frac := v/div, v%div
+	if whole < 0 {
+		whole = -whole
+	}
+	if frac < 0 {
+		frac = -frac
+	}
+	return fmt.Sprintf("%s%d.%0*d %s", sign, whole, exp, frac, m.Currency)
+}
+
+// Money возвращает сумму платежа в его валюте, например p.Money(p.DeliveryCost).
+func (p Payment) Money(a Amount) Money {
+	return Money{Amount: a, Currency: p.Currency}
+}
+
+// ItemsTotal возвращает стоимость всех товаров заказа в валюте платежа.
+func (o Order) ItemsTotal() (Money, error) {
+	total := Money{Currency: o.Payment.Currency}
+	for _, item := range o.Items {
+		var err error
+		if total, err = total.Add(o.Payment.Money(item.TotalPrice)); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,60 @@
+package ordermodel
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMoneyArithmetic(t *testing.T) {
+	price := Money{Amount: 317, Currency: "USD"}
+
+	sum, err := price.Add(Money{Amount: 1500, Currency: "USD"})
+	if err != nil || sum.Amount != 1817 {
+		t.Errorf("Add = %v, %v; want 1817", sum, err)
+	}
+	diff, err := price.Sub(Money{Amount: 400, Currency: "USD"})
+	if err != nil || diff.Amount != -83 {
+		t.Errorf("Sub = %v, %v; want -83", diff, err)
+	}
+	total, err := price.Mul(3)
+	if err != nil || total.Amount != 951 {
+		t.Errorf("Mul = %v, %v; want 951", total, err)
+	}
+
+	if _, err := price.Add(Money{Amount: 1, Currency: "RUB"}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add with other currency: err = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := (Money{Amount: math.MaxInt64, Currency: "USD"}).Add(Money{Amount: 1, Currency: "USD"}); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Add overflow: err = %v, want ErrOverflow", err)
+	}
+	if _, err := (Money{Amount: math.MaxInt64 / 2, Currency: "USD"}).Mul(3); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Mul overflow: err = %v, want ErrOverflow", err)
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{Money{Amount: 1817, Currency: "USD"}, "18.17 USD"},
+		{Money{Amount: -5, Currency: "RUB"}, "-0.05 RUB"},
+		{Money{Amount: 1500, Currency: "JPY"}, "1500 JPY"},
+		{Money{Amount: 1234, Currency: "KWD"}, "1.234 KWD"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	if c, err := ParseCurrency(" usd "); err != nil || c != "USD" {
+		t.Errorf("ParseCurrency = %q, %v; want USD", c, err)
+	}
+	if _, err := ParseCurrency("Currency_1"); err == nil {
+		t.Error("ParseCurrency accepted unknown currency")
+	}
+}
@@ -0,0 +1,60 @@
+package ordermodel
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// UnixTime момент времени, который в JSON и в базе данных хранится как Unix-время в секундах.
+// Используется для payment_dt, чтобы сохранить совместимость с существующими сообщениями.
+type UnixTime struct {
+	time.Time
+}
+
+// NewUnixTime создает UnixTime из количества секунд с начала эпохи.
+func NewUnixTime(sec int64) UnixTime {
+	return UnixTime{time.Unix(sec, 0).UTC()}
+}
+
+// Seconds возвращает Unix-время в секундах. Нулевое значение соответствует 0, как в старом формате.
+func (t UnixTime) Seconds() int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// MarshalJSON кодирует время целым числом секунд.
+func (t UnixTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Seconds())
+}
+
+// UnmarshalJSON разбирает целое число секунд.
+func (t *UnixTime) UnmarshalJSON(data []byte) error {
+	var sec int64
+	if err := json.Unmarshal(data, &sec); err != nil {
+		return fmt.Errorf("payment_dt должно быть Unix-временем в секундах: %v", err)
+	}
+	*t = NewUnixTime(sec)
+	return nil
+}
+
+// Value сохраняет время в столбец BIGINT.
+func (t UnixTime) Value() (driver.Value, error) {
+	return t.Seconds(), nil
+}
+
+// Scan читает время из столбца BIGINT.
+func (t *UnixTime) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*t = NewUnixTime(v)
+	case nil:
+		*t = UnixTime{}
+	default:
+		return fmt.Errorf("неподдерживаемый тип для UnixTime: %T", src)
+	}
+	return nil
+}
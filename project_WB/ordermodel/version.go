@@ -0,0 +1,122 @@
+package ordermodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// История версий схемы заказа:
+//
+//	1 - исходный формат без поля schema_version (сообщения, отправленные до введения версий);
+//	2 - добавлено поле schema_version.
+const (
+	// LegacySchemaVersion версия сообщений без поля schema_version.
+	LegacySchemaVersion = 1
+	// CurrentSchemaVersion текущая версия схемы заказа.
+	CurrentSchemaVersion = 2
+)
+
+// ErrUnsupportedVersion возвращается для сообщений с версией схемы новее текущей.
+var ErrUnsupportedVersion = errors.New("неподдерживаемая версия схемы заказа")
+
+// Upcaster преобразует документ заказа версии N в версию N+1.
+// Документ передается в виде разобранного JSON-объекта и изменяется на месте.
+type Upcaster func(doc map[string]any) error
+
+// upcasters преобразования по исходной версии.
+var upcasters = map[int]Upcaster{
+	1: func(doc map[string]any) error {
+		// Версия 2 отличается только наличием поля schema_version, которое проставляется ниже.
+		return nil
+	},
+}
+
+// Decode разбирает JSON-документ заказа любой поддерживаемой версии и приводит его к текущей схеме.
+// Код валюты платежа приводится к верхнему регистру; валюта вне ISO 4217 считается ошибкой.
+// В строгом режиме ошибкой считаются и неизвестные поля, что позволяет заметить переименованные
+// или добавленные производителем поля вместо молчаливой потери данных.
+func Decode(data []byte, strict bool) (Order, error) {
+	order, err := decode(data, strict)
+	if err != nil {
+		return order, err
+	}
+	if err := order.NormalizeCurrency(); err != nil {
+		return order, err
+	}
+	return order, nil
+}
+
+// DecodeStored разбирает сохраненный документ заказа, как Decode в нестрогом режиме, но без проверки
+// валюты: документы, принятые до введения проверки, должны оставаться доступными для чтения.
+func DecodeStored(data []byte) (Order, error) {
+	return decode(data, false)
+}
+
+// NormalizeCurrency приводит код валюты платежа к виду ParseCurrency и проверяет, что валюта известна.
+func (o *Order) NormalizeCurrency() error {
+	c, err := ParseCurrency(string(o.Payment.Currency))
+	if err != nil {
+		return fmt.Errorf("платеж: %w", err)
+	}
+	o.Payment.Currency = c
+	return nil
+}
+
+// decode разбирает документ заказа и приводит его к текущей версии схемы.
+func decode(data []byte, strict bool) (Order, error) {
+	var order Order
+
+	var header struct {
+		SchemaVersion *int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return order, err
+	}
+	version := LegacySchemaVersion
+	if header.SchemaVersion != nil {
+		version = *header.SchemaVersion
+	}
+	if version < LegacySchemaVersion || version > CurrentSchemaVersion {
+		return order, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	if version < CurrentSchemaVersion {
+		var err error
+		if data, err = upcast(data, version); err != nil {
+			return order, err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&order); err != nil {
+		return order, err
+	}
+	order.SchemaVersion = CurrentSchemaVersion
+	return order, nil
+}
+
+// upcast последовательно применяет преобразования от версии from до текущей.
+func upcast(data []byte, from int) ([]byte, error) {
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // сохраняем точность чисел при повторной сериализации
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	for v := from; v < CurrentSchemaVersion; v++ {
+		up, ok := upcasters[v]
+		if !ok {
+			return nil, fmt.Errorf("нет преобразования схемы заказа из версии %d", v)
+		}
+		if err := up(doc); err != nil {
+			return nil, fmt.Errorf("ошибка преобразования схемы заказа из версии %d: %v", v, err)
+		}
+		doc["schema_version"] = v + 1
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,111 @@
+package ordermodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCompatibility проверяет, что эталонные сообщения каждой версии схемы
+// в строгом режиме приводятся к одному и тому же каноническому заказу (testdata/canonical.json).
+func TestCompatibility(t *testing.T) {
+	want, err := os.ReadFile("testdata/canonical.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures, err := filepath.Glob("testdata/v*/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	versions := map[string]bool{}
+	for _, path := range fixtures {
+		versions[filepath.Base(filepath.Dir(path))] = true
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order, err := Decode(data, true)
+		if err != nil {
+			t.Errorf("%s: Decode: %v", path, err)
+			continue
+		}
+		got, err := json.MarshalIndent(order, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, '\n')
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: decoded order differs from canonical:\n%s", path, got)
+		}
+	}
+
+	// Для каждой поддерживаемой версии должен быть хотя бы один эталон.
+	for v := LegacySchemaVersion; v <= CurrentSchemaVersion; v++ {
+		if dir := fmt.Sprintf("v%d", v); !versions[dir] {
+			t.Errorf("no fixtures for schema version %d (testdata/%s)", v, dir)
+		}
+	}
+}
+
+func TestDecodeStrictRejectsUnknownFields(t *testing.T) {
+	data, err := os.ReadFile("testdata/invalid/unknown_field.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(data, true); err == nil {
+		t.Error("strict Decode accepted unknown field")
+	}
+	order, err := Decode(data, false)
+	if err != nil {
+		t.Fatalf("lenient Decode: %v", err)
+	}
+	if order.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", order.SchemaVersion, CurrentSchemaVersion)
+	}
+}
+
+func TestDecodeRejectsFutureVersion(t *testing.T) {
+	data, err := os.ReadFile("testdata/invalid/future_version.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(data, false); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Decode error = %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func TestDecodeCurrency(t *testing.T) {
+	data, err := os.ReadFile("testdata/invalid/unknown_currency.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Неизвестная валюта отклоняется и вне строгого режима
+	if _, err := Decode(data, false); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("Decode error = %v, want ErrUnknownCurrency", err)
+	}
+	if _, err := DecodeStored(data); err != nil {
+		t.Errorf("DecodeStored: %v", err)
+	}
+
+	data, err = os.ReadFile("testdata/v2/order.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := Decode(bytes.Replace(data, []byte(`"USD"`), []byte(`" usd"`), 1), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Payment.Currency != "USD" {
+		t.Errorf("Currency = %q, want USD", order.Payment.Currency)
+	}
+}
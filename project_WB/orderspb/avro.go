@@ -0,0 +1,16 @@
+package orderspb
+
+import _ "embed"
+
+// AvroSchema Avro-схема заказа (orders.avsc) для форматов сообщений NATS.
+//
+//go:embed orders.avsc
+var AvroSchema string
+
+// Типы содержимого сообщений с заказами, передаваемые в заголовке Content-Type.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/avro"
+	ContentTypeMsgpack  = "application/msgpack"
+)
@@ -0,0 +1,138 @@
+package orderspb
+
+import (
+	"time"
+
+	model "github.com/Selandro/my_servis_order/project_WB/ordermodel"
+)
+
+// FromModel преобразует заказ общей модели в protobuf-сообщение.
+func FromModel(order model.Order) *Order {
+	items := make([]*Item, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, &Item{
+			ChrtId:      int64(item.ChrtID),
+			TrackNumber: item.TrackNumber,
+			Price:       int64(item.Price),
+			Rid:         item.RID,
+			Name:        item.Name,
+			Sale:        int64(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  int64(item.TotalPrice),
+			NmId:        int64(item.NMID),
+			Brand:       item.Brand,
+			Status:      int64(item.Status),
+		})
+	}
+
+	return &Order{
+		OrderUid:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		Entry:       order.Entry,
+		Delivery: &Delivery{
+			Name:    order.Delivery.Name,
+			Phone:   order.Delivery.Phone,
+			Zip:     order.Delivery.Zip,
+			City:    order.Delivery.City,
+			Address: order.Delivery.Address,
+			Region:  order.Delivery.Region,
+			Email:   order.Delivery.Email,
+		},
+		Payment: &Payment{
+			Transaction:  order.Payment.Transaction,
+			RequestId:    order.Payment.RequestID,
+			Currency:     string(order.Payment.Currency),
+			Provider:     order.Payment.Provider,
+			Amount:       int64(order.Payment.Amount),
+			PaymentDt:    order.Payment.PaymentDT.Seconds(),
+			Bank:         order.Payment.Bank,
+			DeliveryCost: int64(order.Payment.DeliveryCost),
+			GoodsTotal:   int64(order.Payment.GoodsTotal),
+			CustomFee:    int64(order.Payment.CustomFee),
+		},
+		Items:             items,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerId:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmId:              int64(order.SMID),
+		DateCreated:       formatTime(order.DateCreated),
+		OofShard:          order.OOFShard,
+	}
+}
+
+// ToModel преобразует protobuf-сообщение в заказ общей модели текущей версии схемы.
+func ToModel(p *Order) model.Order {
+	items := make([]model.Item, 0, len(p.GetItems()))
+	for _, item := range p.GetItems() {
+		items = append(items, model.Item{
+			ChrtID:      int(item.GetChrtId()),
+			TrackNumber: item.GetTrackNumber(),
+			Price:       model.Amount(item.GetPrice()),
+			RID:         item.GetRid(),
+			Name:        item.GetName(),
+			Sale:        int(item.GetSale()),
+			Size:        item.GetSize(),
+			TotalPrice:  model.Amount(item.GetTotalPrice()),
+			NMID:        int(item.GetNmId()),
+			Brand:       item.GetBrand(),
+			Status:      int(item.GetStatus()),
+		})
+	}
+
+	d, pay := p.GetDelivery(), p.GetPayment()
+	return model.Order{
+		SchemaVersion: model.CurrentSchemaVersion,
+		OrderUID:      p.GetOrderUid(),
+		TrackNumber:   p.GetTrackNumber(),
+		Entry:         p.GetEntry(),
+		Delivery: model.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		},
+		Payment: model.Payment{
+			Transaction:  pay.GetTransaction(),
+			RequestID:    pay.GetRequestId(),
+			Currency:     model.Currency(pay.GetCurrency()),
+			Provider:     pay.GetProvider(),
+			Amount:       model.Amount(pay.GetAmount()),
+			PaymentDT:    model.NewUnixTime(pay.GetPaymentDt()),
+			Bank:         pay.GetBank(),
+			DeliveryCost: model.Amount(pay.GetDeliveryCost()),
+			GoodsTotal:   model.Amount(pay.GetGoodsTotal()),
+			CustomFee:    model.Amount(pay.GetCustomFee()),
+		},
+		Items:             items,
+		Locale:            p.GetLocale(),
+		InternalSignature: p.GetInternalSignature(),
+		CustomerID:        p.GetCustomerId(),
+		DeliveryService:   p.GetDeliveryService(),
+		Shardkey:          p.GetShardkey(),
+		SMID:              int(p.GetSmId()),
+		DateCreated:       parseTime(p.GetDateCreated()),
+		OOFShard:          p.GetOofShard(),
+	}
+}
+
+// formatTime форматирует время в RFC 3339. Нулевое время передается пустой строкой.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseTime разбирает время в формате RFC 3339. Пустая или некорректная строка дает нулевое время.
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}